@@ -0,0 +1,115 @@
+// Command calibrate runs the current analysis model and prompt against a
+// reference set of photos and fits the per-category score calibration the
+// server loads from CALIBRATION_FILE.
+//
+//	go run ./cmd/calibrate -set reference.yaml -out calibration.json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/calibration"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/prompts"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+)
+
+func main() {
+	setPath := flag.String("set", "", "reference set file (JSON or YAML)")
+	outPath := flag.String("out", "calibration.json", "where to write the fitted calibration")
+	id := flag.String("id", "", "calibration ID (default: reference set ID and date)")
+	flag.Parse()
+	if *setPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load("../.env"); err != nil {
+		log.Printf("warning: failed to load ../.env: %v", err)
+	}
+	if err := prompts.Init(); err != nil {
+		log.Fatalf("failed to load prompt templates: %v", err)
+	}
+	if err := rubric.Init(); err != nil {
+		log.Fatalf("failed to load rubrics: %v", err)
+	}
+	set, err := calibration.LoadReferenceSet(*setPath, rubric.Default())
+	if err != nil {
+		log.Fatal(err)
+	}
+	setRubric, _ := rubric.Default().Get(set.Rubric)
+
+	// Calibration is never loaded here, and the cache is off, so every
+	// score is a fresh raw answer of the current model.
+	client := services.NewGeminiClient()
+	client.SetCache(nil)
+	ctx := rubric.WithID(context.Background(), set.Rubric)
+
+	// Only the primary model's answers are fitted; the server leaves the
+	// scores of fallback models raw.
+	primary := services.ModelChain(services.CapabilityAnalysis)[0]
+	observations := map[string][]calibration.Observation{}
+	promptVersion := prompts.Default().VersionContext(ctx, prompts.Analysis)
+	for i, photo := range set.Photos {
+		log.Printf("Analyzing %s (%d/%d)", photo.ID, i+1, len(set.Photos))
+		result, err := client.AnalyzeImage(ctx, photo.Image)
+		if err != nil {
+			log.Printf("WARN: Skipping %s: %v", photo.ID, err)
+			continue
+		}
+		if result.Model != primary {
+			log.Printf("WARN: Skipping %s: served by %q instead of %s", photo.ID, result.Model, primary)
+			continue
+		}
+		for key, target := range photo.Targets {
+			if score, ok := result.Scores[key]; ok {
+				observations[key] = append(observations[key], calibration.Observation{Raw: score.Score, Target: target})
+			}
+		}
+	}
+
+	mappings, err := calibration.Fit(observations)
+	if err != nil {
+		log.Fatalf("failed to fit calibration: %v", err)
+	}
+	fittedAt := time.Now().UTC()
+	if *id == "" {
+		*id = fmt.Sprintf("%s-%s", set.ID, fittedAt.Format("20060102"))
+	}
+	fitted := &calibration.Calibration{
+		ID:             *id,
+		ReferenceSetID: set.ID,
+		RubricID:       set.Rubric,
+		Scale:          setRubric.Scale,
+		Models:         []string{primary},
+		PromptVersion:  promptVersion,
+		FittedAt:       fittedAt,
+		Categories:     mappings,
+	}
+	if err := fitted.Save(*outPath); err != nil {
+		log.Fatal(err)
+	}
+
+	keys := make([]string, 0, len(mappings))
+	for key := range mappings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "category\tsamples\tslope\tintercept\traw error\tfitted error")
+	for _, key := range keys {
+		m := mappings[key]
+		fmt.Fprintf(table, "%s\t%d\t%.3f\t%.3f\t%.3f\t%.3f\n", key, m.Samples, m.Slope, m.Intercept, m.RawError, m.FittedError)
+	}
+	table.Flush()
+	log.Printf("Wrote calibration %s to %s", fitted.ID, *outPath)
+}
@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"log"
+
+	"google.golang.org/adk/model"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+)
+
+// fallbackModel is a model.LLM that tries each model in order until one
+// starts answering. Once a model has produced a response, its stream is
+// committed; failures after that point are passed through. The serving model
+// is recorded on the context's CallTrace.
+type fallbackModel struct {
+	models []model.LLM
+}
+
+func newFallbackModel(models []model.LLM) (model.LLM, error) {
+	if len(models) == 0 {
+		return nil, fmt.Errorf("at least one agent model is required")
+	}
+	return &fallbackModel{models: models}, nil
+}
+
+func (f *fallbackModel) Name() string {
+	return f.models[0].Name()
+}
+
+func (f *fallbackModel) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		var lastErr error
+		for i, candidate := range f.models {
+			started := false
+			failed := false
+			for response, err := range candidate.GenerateContent(ctx, req, stream) {
+				if err != nil && !started {
+					lastErr = err
+					failed = true
+					break
+				}
+				if !started {
+					started = true
+					if i > 0 {
+						log.Printf("INFO: Agent served by fallback model %s", candidate.Name())
+					}
+					services.CallTraceFrom(ctx).RecordModel(services.StepChat, candidate.Name())
+				}
+				if !yield(response, err) {
+					return
+				}
+			}
+			if !failed {
+				return
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			if i < len(f.models)-1 {
+				log.Printf("WARN: Agent model %s failed, falling back to %s: %v", candidate.Name(), f.models[i+1].Name(), lastErr)
+			}
+		}
+		yield(nil, lastErr)
+	}
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+type stubModel struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (s *stubModel) Name() string { return s.name }
+
+func (s *stubModel) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		s.calls++
+		if s.err != nil {
+			yield(nil, s.err)
+			return
+		}
+		yield(&model.LLMResponse{Content: genai.NewContentFromText(s.name, genai.RoleModel)}, nil)
+	}
+}
+
+func TestFallbackModelUsesNextModelOnFailure(t *testing.T) {
+	primary := &stubModel{name: "primary", err: errors.New("unavailable")}
+	secondary := &stubModel{name: "secondary"}
+	llm, err := newFallbackModel([]model.LLM{primary, secondary})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var texts []string
+	for response, err := range llm.GenerateContent(context.Background(), &model.LLMRequest{}, false) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		texts = append(texts, response.Content.Parts[0].Text)
+	}
+	if len(texts) != 1 || texts[0] != "secondary" {
+		t.Errorf("responses = %v, want [secondary]", texts)
+	}
+	if primary.calls != 1 || secondary.calls != 1 {
+		t.Errorf("calls = %d/%d, want 1/1", primary.calls, secondary.calls)
+	}
+}
+
+func TestFallbackModelReturnsLastErrorWhenAllFail(t *testing.T) {
+	last := errors.New("second failure")
+	llm, err := newFallbackModel([]model.LLM{
+		&stubModel{name: "primary", err: errors.New("first failure")},
+		&stubModel{name: "secondary", err: last},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, err := range llm.GenerateContent(context.Background(), &model.LLMRequest{}, false) {
+		if !errors.Is(err, last) {
+			t.Errorf("err = %v, want %v", err, last)
+		}
+	}
+}
@@ -3,75 +3,55 @@ package agent
 import (
 	"context"
 	"errors"
+	"log"
 	"os"
 
 	"google.golang.org/adk/agent"
 	"google.golang.org/adk/agent/llmagent"
+	"google.golang.org/adk/model"
 	"google.golang.org/adk/model/gemini"
 	"google.golang.org/adk/tool"
 	"google.golang.org/genai"
 
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/prompts"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/skill"
 	"github.com/matsuvr/photo_levelup_agent/backend/internal/tools"
 )
 
-const systemInstruction = `あなたは写真指導の専門家AIアシスタント「フォトコーチ」です。
-ユーザーの写真スキル向上をサポートします。
-
-## あなたの役割
-1. ユーザーがアップロードした写真を分析し、構図、露出、色彩、ライティング、ピント、現像、距離感、意図の明確さ、の8項目を評価
-2. 受賞レベルの写真に変換するための改善ポイントを提示
-3. 元の写真と改善案を比較し、具体的な改善アドバイスを提供
-4. ユーザーの追加質問に対して、文脈を理解した上で詳細に回答
-
-## 利用可能なツール
-- analyze_photo: 写真を分析して評価を返す（必須：写真分析時は必ずこのツールを使用すること）
-
-## 重要なルール
-- 写真のURLが提供されたら、自分で直接分析せず、必ず analyze_photo ツールを呼び出してください
-- analyze_photo ツールを使わずに写真を分析することは禁止です
-- ツールの結果を元にアドバイスを提供してください
-
-## 応答ルール
-1. 具体的で実践的なアドバイスを提供
-2. 専門用語は必要に応じて説明を加える
-3. Lightroom/Photoshopの具体的な操作手順を含める
-4. カメラ設定は具体的な数値で示す
-5. ユーザーの質問には、過去の分析結果を参照しながら回答
-
-## セッション状態の活用
-- original_image_url: アップロードされた元画像のURL
-- analysis_result: 分析結果のJSON
-これらの状態を参照して、一貫性のあるアドバイスを提供してください。
-
-## フォローアップ対応
-- 既にユーザーの写真を分析済みで会話が始まっている場合、自己紹介は不要です
-- 分析結果や過去の会話を参照して、質問に直接回答してください
-- 元画像と添削結果を踏まえた具体的なアドバイスを提供してください
-`
-
-func NewPhotoCoachAgent(ctx context.Context) (agent.Agent, error) {
-	modelName := os.Getenv("GEMINI_MODEL")
-	if modelName == "" {
-		modelName = "gemini-3-flash-preview"
-	}
+func NewPhotoCoachAgent(ctx context.Context, analyzer services.Analyzer, comparer services.Comparer, storage services.ObjectStore) (agent.Agent, error) {
 	apiKey := os.Getenv("GOOGLE_API_KEY")
 	if apiKey == "" {
 		return nil, errors.New("GOOGLE_API_KEY is required")
 	}
 
-	model, err := gemini.NewModel(ctx, modelName, &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
-	})
+	var models []model.LLM
+	for _, modelName := range services.ModelChain(services.CapabilityAgent) {
+		llm, err := gemini.NewModel(ctx, modelName, &genai.ClientConfig{
+			APIKey:  apiKey,
+			Backend: genai.BackendGeminiAPI,
+		})
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, llm)
+	}
+	agentModel, err := newFallbackModel(models)
 	if err != nil {
 		return nil, err
 	}
 
-	analyzePhotoTool, err := tools.NewAnalyzePhotoTool()
+	_, version, err := prompts.Default().Render(prompts.AgentSystem, prompts.AgentSystemData{})
 	if err != nil {
 		return nil, err
 	}
-	compareAndAdviseTool, err := tools.NewCompareAndAdviseTool()
+	log.Printf("INFO: Photo coach agent using system prompt %s", version)
+
+	analyzePhotoTool, err := tools.NewAnalyzePhotoTool(analyzer, storage)
+	if err != nil {
+		return nil, err
+	}
+	compareAndAdviseTool, err := tools.NewCompareAndAdviseTool(comparer)
 	if err != nil {
 		return nil, err
 	}
@@ -79,8 +59,14 @@ func NewPhotoCoachAgent(ctx context.Context) (agent.Agent, error) {
 	photoAgent, err := llmagent.New(llmagent.Config{
 		Name:        "photo_coach",
 		Description: "写真スキル向上をサポートするAIコーチ。写真分析と改善アドバイスを行う。",
-		Instruction: systemInstruction,
-		Model:       model,
+		// Rendered per invocation so the request's locale, skill level and
+		// prompt experiments apply to the system prompt too.
+		InstructionProvider: func(ctx agent.ReadonlyContext) (string, error) {
+			data := prompts.AgentSystemData{SkillLevel: string(skill.FromContext(ctx))}
+			instruction, _, err := prompts.Default().RenderContext(ctx, prompts.AgentSystem, data)
+			return instruction, err
+		},
+		Model: agentModel,
 		Tools: []tool.Tool{
 			analyzePhotoTool,
 			compareAndAdviseTool,
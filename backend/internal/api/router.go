@@ -11,11 +11,19 @@ func newRouter(deps *handlers.Dependencies) http.Handler {
 
 	mux.Handle("POST /photo/analyze", handlers.NewAnalyzeHandler(deps))
 	mux.Handle("GET /photo/analyze/status", handlers.NewAnalyzeStatusHandler())
-	mux.Handle("GET /photo/image", handlers.NewImageHandler())
+	mux.Handle("GET /photo/image", handlers.NewImageHandler(deps))
 	mux.Handle("POST /photo/chat", handlers.NewChatHandler(deps))
 	mux.Handle("GET /photo/sessions", handlers.NewSessionsHandler(deps))
 	mux.Handle("GET /photo/sessions/", handlers.NewSessionDetailHandler(deps))
-	mux.Handle("POST /test/gemini", handlers.NewTestGeminiHandler())
+	mux.Handle("GET /photo/sessions/{sessionId}/lut", handlers.NewLUTHandler(deps))
+	mux.Handle("GET /photo/usage", handlers.NewUsageHandler(deps))
+	mux.Handle("GET /photo/usage/users", handlers.NewUsageUsersHandler(deps))
+	mux.Handle("POST /photo/feedback", handlers.NewFeedbackHandler(deps))
+	mux.Handle("GET /photo/profile", handlers.NewProfileHandler(deps))
+	mux.Handle("PUT /photo/profile", handlers.NewProfileHandler(deps))
+	mux.Handle("GET /photo/experiments", handlers.NewExperimentsHandler(deps))
+	mux.Handle("GET /photo/rubrics", handlers.NewRubricsHandler())
+	mux.Handle("POST /test/gemini", handlers.NewTestGeminiHandler(deps))
 
 	return mux
 }
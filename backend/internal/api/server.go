@@ -5,12 +5,22 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
 
 	"google.golang.org/adk/session"
 
 	"github.com/matsuvr/photo_levelup_agent/backend/internal/agent"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/calibration"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/experiments"
 	"github.com/matsuvr/photo_levelup_agent/backend/internal/handlers"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/profile"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/prompts"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/quota"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
 	firestoreSession "github.com/matsuvr/photo_levelup_agent/backend/internal/session"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/usage"
 )
 
 type Server struct {
@@ -18,7 +28,32 @@ type Server struct {
 }
 
 func NewServer(ctx context.Context) (*Server, error) {
-	photoAgent, err := agent.NewPhotoCoachAgent(ctx)
+	if err := prompts.Init(); err != nil {
+		return nil, err
+	}
+	if err := rubric.Init(); err != nil {
+		return nil, err
+	}
+	if err := calibration.Init(); err != nil {
+		return nil, err
+	}
+	if current := calibration.Default(); current != nil {
+		log.Printf("Score calibration %s loaded (fitted on %s).", current.ID, strings.Join(current.Models, ","))
+		if !slices.Contains(current.Models, services.ModelChain(services.CapabilityAnalysis)[0]) {
+			log.Printf("Warning: Score calibration %s was fitted for models %v; scores of the current chain stay raw until it is refit.", current.ID, current.Models)
+		}
+		if version := prompts.Default().Version(prompts.Analysis); current.PromptVersion != version {
+			log.Printf("Warning: Score calibration %s was fitted on prompt %s; scores of %s stay raw until it is refit.", current.ID, current.PromptVersion, version)
+		}
+	}
+
+	models := newModelProvider(ctx)
+	storage := newObjectStore(ctx)
+	if fake, ok := models.(*services.FakeModelProvider); ok {
+		fake.Store = storage
+	}
+
+	photoAgent, err := agent.NewPhotoCoachAgent(ctx, models, models, storage)
 	if err != nil {
 		return nil, err
 	}
@@ -43,12 +78,68 @@ func NewServer(ctx context.Context) (*Server, error) {
 		sessionService = session.InMemoryService()
 	}
 
-	deps := handlers.NewDependencies(photoAgent, sessionService)
+	deps := handlers.NewDependencies(photoAgent, sessionService, models, storage)
+	if projectID != "" {
+		if ledger, err := usage.NewFirestoreLedger(ctx, projectID); err != nil {
+			log.Printf("Warning: Failed to create Firestore usage ledger: %v. Falling back to in-memory.", err)
+		} else {
+			deps.Usage = ledger
+		}
+		if store, err := profile.NewFirestoreStore(ctx, projectID); err != nil {
+			log.Printf("Warning: Failed to create Firestore profile store: %v. Falling back to in-memory.", err)
+		} else {
+			deps.Profiles = store
+		}
+		if store, err := quota.NewFirestoreStore(ctx, projectID); err != nil {
+			log.Printf("Warning: Failed to create Firestore quota store: %v. Falling back to in-memory.", err)
+		} else {
+			deps.Quota = quota.NewEnforcer(store, quota.LimitsFromEnv())
+		}
+	}
+	experimentConfig, err := experiments.LoadFromEnv(prompts.Default())
+	if err != nil {
+		return nil, err
+	}
+	var outcomes experiments.OutcomeStore = experiments.NewMemoryOutcomeStore()
+	if projectID != "" && len(experimentConfig) > 0 {
+		if store, err := experiments.NewFirestoreOutcomeStore(ctx, projectID); err != nil {
+			log.Printf("Warning: Failed to create Firestore experiment outcome store: %v. Falling back to in-memory.", err)
+		} else {
+			outcomes = store
+		}
+	}
+	deps.Experiments = experiments.NewManager(experimentConfig, outcomes)
 	router := newRouter(deps)
 
 	return &Server{router: router}, nil
 }
 
+// newModelProvider selects the model backend (MODEL_PROVIDER=fake for offline use).
+func newModelProvider(ctx context.Context) services.ModelProvider {
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("MODEL_PROVIDER")), "fake") {
+		log.Println("MODEL_PROVIDER=fake. Using the deterministic fake model provider.")
+		return services.NewFakeModelProvider(nil)
+	}
+	client := services.NewGeminiClient()
+	client.SetCache(services.NewResultCache(ctx))
+	return client
+}
+
+// newObjectStore selects the image store (STORAGE_BACKEND=memory for offline use).
+// A nil store is returned when Cloud Storage cannot be initialized.
+func newObjectStore(ctx context.Context) services.ObjectStore {
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("STORAGE_BACKEND")), "memory") {
+		log.Println("STORAGE_BACKEND=memory. Using the in-memory object store.")
+		return services.NewMemoryObjectStore()
+	}
+	storageClient, err := services.NewStorageClient(ctx)
+	if err != nil {
+		log.Printf("Warning: Failed to create storage client: %v", err)
+		return nil
+	}
+	return storageClient
+}
+
 func (s *Server) Handler() http.Handler {
 	return s.router
 }
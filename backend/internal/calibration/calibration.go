@@ -0,0 +1,288 @@
+// Package calibration keeps scores comparable across model upgrades. A
+// reference set of photos with agreed target scores is analyzed by the
+// current model and prompt, and a linear mapping per category is fitted
+// from the raw scores to the targets. Live analyses store both the raw and
+// the calibrated scores.
+package calibration
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+)
+
+// ReferencePhoto is one photo of the reference set with its agreed scores.
+type ReferencePhoto struct {
+	ID string `json:"id"`
+	// Image is a gs:// or http(s) URL the analyzer can fetch.
+	Image string `json:"image"`
+	// Targets are the agreed scores keyed by category key.
+	Targets map[string]float64 `json:"targets"`
+}
+
+// ReferenceSet is the stored set of photos a calibration is fitted on.
+type ReferenceSet struct {
+	ID string `json:"id"`
+	// Rubric is the ID of the rubric the targets are given in.
+	Rubric string           `json:"rubric"`
+	Photos []ReferencePhoto `json:"photos"`
+}
+
+// LoadReferenceSet reads a reference set from a JSON or YAML file and
+// checks its targets against the rubric registry.
+func LoadReferenceSet(path string, rubrics *rubric.Registry) (ReferenceSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ReferenceSet{}, fmt.Errorf("failed to read reference set: %w", err)
+	}
+	set, err := ParseReferenceSet(data)
+	if err != nil {
+		return ReferenceSet{}, fmt.Errorf("reference set %s: %w", path, err)
+	}
+	if err := set.Validate(rubrics); err != nil {
+		return ReferenceSet{}, fmt.Errorf("reference set %s: %w", path, err)
+	}
+	return set, nil
+}
+
+// ParseReferenceSet decodes a reference set document. Like rubrics, JSON
+// and YAML both go through the YAML decoder; unknown fields are errors.
+func ParseReferenceSet(data []byte) (ReferenceSet, error) {
+	var generic any
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return ReferenceSet{}, err
+	}
+	normalized, err := json.Marshal(generic)
+	if err != nil {
+		return ReferenceSet{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+	var set ReferenceSet
+	if err := decoder.Decode(&set); err != nil {
+		return ReferenceSet{}, err
+	}
+	return set, nil
+}
+
+// Validate checks that the rubric exists and every target is a category of
+// it within its scale.
+func (s ReferenceSet) Validate(rubrics *rubric.Registry) error {
+	var problems []string
+	r, ok := rubrics.Get(s.Rubric)
+	if !ok {
+		return fmt.Errorf("unknown rubric %q", s.Rubric)
+	}
+	if len(s.Photos) == 0 {
+		problems = append(problems, "no photos")
+	}
+	seen := map[string]bool{}
+	for i, photo := range s.Photos {
+		name := photo.ID
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+			problems = append(problems, fmt.Sprintf("photo %s is missing an id", name))
+		} else if seen[name] {
+			problems = append(problems, fmt.Sprintf("duplicate photo %s", name))
+		}
+		seen[name] = true
+		if strings.TrimSpace(photo.Image) == "" {
+			problems = append(problems, fmt.Sprintf("photo %s is missing an image", name))
+		}
+		for key, target := range photo.Targets {
+			if _, ok := r.Category(key); !ok {
+				problems = append(problems, fmt.Sprintf("photo %s: unknown category %s", name, key))
+			} else if target < float64(r.Scale.Min) || target > float64(r.Scale.Max) {
+				problems = append(problems, fmt.Sprintf("photo %s: %s target %g outside %d-%d", name, key, target, r.Scale.Min, r.Scale.Max))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New(strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// Mapping is the fitted linear map of one category: Slope*raw + Intercept.
+type Mapping struct {
+	Slope     float64 `json:"slope"`
+	Intercept float64 `json:"intercept"`
+	Samples   int     `json:"samples"`
+	// RawError and FittedError are the root mean square distances to the
+	// targets before and after the mapping.
+	RawError    float64 `json:"rawError"`
+	FittedError float64 `json:"fittedError"`
+}
+
+// Apply maps a raw score, rounded and clamped to scale.
+func (m Mapping) Apply(raw int, scale rubric.Scale) int {
+	mapped := int(math.Round(m.Slope*float64(raw) + m.Intercept))
+	return max(scale.Min, min(scale.Max, mapped))
+}
+
+// Calibration is a fitted set of per-category mappings for one model chain
+// and analysis prompt.
+type Calibration struct {
+	// ID identifies the calibration on every result it was applied to.
+	ID             string       `json:"id"`
+	ReferenceSetID string       `json:"referenceSetId"`
+	RubricID       string       `json:"rubricId"`
+	Scale          rubric.Scale `json:"scale"`
+	// Models are the models that served the reference analyses and
+	// PromptVersion the analysis prompt they answered; scores from any other
+	// model or prompt have a different raw distribution.
+	Models        []string           `json:"models"`
+	PromptVersion string             `json:"promptVersion"`
+	FittedAt      time.Time          `json:"fittedAt"`
+	Categories    map[string]Mapping `json:"categories"`
+}
+
+// Observation is one raw score of a reference photo next to its target.
+type Observation struct {
+	Raw    int
+	Target float64
+}
+
+// Fit fits a least-squares line per category. Categories whose raw scores
+// do not vary, or whose fitted slope is not positive, only get the mean
+// offset, so a calibration never reverses the order of two photos.
+func Fit(observations map[string][]Observation) (map[string]Mapping, error) {
+	mappings := map[string]Mapping{}
+	for key, points := range observations {
+		if len(points) == 0 {
+			continue
+		}
+		n := float64(len(points))
+		meanRaw, meanTarget := 0.0, 0.0
+		for _, point := range points {
+			meanRaw += float64(point.Raw)
+			meanTarget += point.Target
+		}
+		meanRaw /= n
+		meanTarget /= n
+		covariance, variance := 0.0, 0.0
+		for _, point := range points {
+			covariance += (float64(point.Raw) - meanRaw) * (point.Target - meanTarget)
+			variance += (float64(point.Raw) - meanRaw) * (float64(point.Raw) - meanRaw)
+		}
+		mapping := Mapping{Slope: 1, Samples: len(points)}
+		if variance > 0 && covariance > 0 {
+			mapping.Slope = covariance / variance
+		}
+		mapping.Intercept = meanTarget - mapping.Slope*meanRaw
+		rawSquares, fittedSquares := 0.0, 0.0
+		for _, point := range points {
+			rawSquares += (float64(point.Raw) - point.Target) * (float64(point.Raw) - point.Target)
+			fitted := mapping.Slope*float64(point.Raw) + mapping.Intercept
+			fittedSquares += (fitted - point.Target) * (fitted - point.Target)
+		}
+		mapping.RawError = math.Sqrt(rawSquares / n)
+		mapping.FittedError = math.Sqrt(fittedSquares / n)
+		mappings[key] = mapping
+	}
+	if len(mappings) == 0 {
+		return nil, errors.New("no observations to fit")
+	}
+	return mappings, nil
+}
+
+// Load reads a calibration written by Save.
+func Load(path string) (*Calibration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read calibration: %w", err)
+	}
+	var calibration Calibration
+	if err := json.Unmarshal(data, &calibration); err != nil {
+		return nil, fmt.Errorf("calibration %s: %w", path, err)
+	}
+	if calibration.ID == "" || calibration.RubricID == "" || calibration.PromptVersion == "" || len(calibration.Models) == 0 || len(calibration.Categories) == 0 {
+		return nil, fmt.Errorf("calibration %s: missing id, rubricId, promptVersion, models or categories", path)
+	}
+	return &calibration, nil
+}
+
+// Save writes the calibration as indented JSON.
+func (c *Calibration) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write calibration: %w", err)
+	}
+	return nil
+}
+
+// SkipReason explains why the calibration cannot map scores that model
+// gave under r with the analysis prompt promptVersion, or returns "" when
+// it can. A mapping fitted on one rubric, prompt or model means nothing for
+// another, even when the scales match.
+func (c *Calibration) SkipReason(r rubric.Rubric, promptVersion, model string) string {
+	switch {
+	case c == nil:
+		return "no calibration loaded"
+	case c.RubricID != r.ID:
+		return fmt.Sprintf("calibration %s was fitted on rubric %s, not %s", c.ID, c.RubricID, r.ID)
+	case c.Scale != r.Scale:
+		return fmt.Sprintf("calibration %s was fitted on a %d-%d scale", c.ID, c.Scale.Min, c.Scale.Max)
+	case c.PromptVersion != promptVersion:
+		return fmt.Sprintf("calibration %s was fitted on prompt %s, not %s", c.ID, c.PromptVersion, promptVersion)
+	case !slices.Contains(c.Models, model):
+		return fmt.Sprintf("calibration %s was not fitted on model %q", c.ID, model)
+	}
+	return ""
+}
+
+// Applies reports whether SkipReason finds nothing against mapping the scores.
+func (c *Calibration) Applies(r rubric.Rubric, promptVersion, model string) bool {
+	return c.SkipReason(r, promptVersion, model) == ""
+}
+
+var (
+	defaultMu          sync.Mutex
+	defaultCalibration *Calibration
+)
+
+// Init loads the calibration in CALIBRATION_FILE, if set, and makes it the
+// default. It is called at startup so a broken file stops the server.
+func Init() error {
+	path := strings.TrimSpace(os.Getenv("CALIBRATION_FILE"))
+	var calibration *Calibration
+	if path != "" {
+		var err error
+		if calibration, err = Load(path); err != nil {
+			return err
+		}
+	}
+	SetDefault(calibration)
+	return nil
+}
+
+// SetDefault replaces the calibration applied to live analyses; nil turns
+// calibration off.
+func SetDefault(calibration *Calibration) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultCalibration = calibration
+}
+
+// Default returns the calibration applied to live analyses, or nil.
+func Default() *Calibration {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultCalibration
+}
@@ -0,0 +1,147 @@
+package calibration
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+)
+
+func TestFit(t *testing.T) {
+	mappings, err := Fit(map[string][]Observation{
+		// The model scores composition two points too harshly and compressed.
+		"composition": {{Raw: 3, Target: 4}, {Raw: 5, Target: 8}, {Raw: 4, Target: 6}},
+		// Constant raw scores only get the mean offset.
+		"exposure": {{Raw: 6, Target: 5}, {Raw: 6, Target: 7}},
+		// A negative trend is not trusted either.
+		"color": {{Raw: 2, Target: 8}, {Raw: 8, Target: 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	composition := mappings["composition"]
+	if math.Abs(composition.Slope-2) > 1e-9 || math.Abs(composition.Intercept+2) > 1e-9 || composition.FittedError > 1e-9 {
+		t.Errorf("composition = %+v, want 2x-2 with no error", composition)
+	}
+	if composition.RawError == 0 || composition.Samples != 3 {
+		t.Errorf("composition stats = %+v", composition)
+	}
+	if exposure := mappings["exposure"]; exposure.Slope != 1 || exposure.Intercept != 0 {
+		t.Errorf("exposure = %+v, want identity", exposure)
+	}
+	if color := mappings["color"]; color.Slope != 1 {
+		t.Errorf("color slope = %v, want 1", color.Slope)
+	}
+
+	scale := rubric.Scale{Min: 0, Max: 10}
+	if got := composition.Apply(5, scale); got != 8 {
+		t.Errorf("Apply(5) = %d, want 8", got)
+	}
+	if got := composition.Apply(9, scale); got != 10 {
+		t.Errorf("Apply(9) = %d, want clamped 10", got)
+	}
+
+	if _, err := Fit(nil); err == nil {
+		t.Error("Fit(nil) succeeded")
+	}
+}
+
+func TestReferenceSetValidate(t *testing.T) {
+	set, err := ParseReferenceSet([]byte(`id: club-reference
+rubric: general-v1
+photos:
+  - id: harbor
+    image: gs://bucket/reference/harbor.jpg
+    targets: {composition: 7, exposure: 6.5}
+  - id: harbor
+    image: ""
+    targets: {impact: 5, focus: 11}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = set.Validate(rubric.Default())
+	if err == nil {
+		t.Fatal("invalid set passed validation")
+	}
+	for _, want := range []string{"duplicate photo harbor", "missing an image", "unknown category impact", "focus target 11 outside 0-10"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q lacks %q", err, want)
+		}
+	}
+
+	set.Photos = set.Photos[:1]
+	if err := set.Validate(rubric.Default()); err != nil {
+		t.Errorf("valid set: %v", err)
+	}
+	set.Rubric = "missing"
+	if err := set.Validate(rubric.Default()); err == nil {
+		t.Error("unknown rubric passed validation")
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calibration.json")
+	saved := &Calibration{
+		ID:            "club-reference-20261018",
+		RubricID:      "general-v1",
+		Scale:         rubric.Scale{Min: 0, Max: 10},
+		Models:        []string{"gemini-3-flash-preview"},
+		PromptVersion: "analysis-v7",
+		Categories:    map[string]Mapping{"composition": {Slope: 1.2, Intercept: -0.5, Samples: 12}},
+	}
+	if err := saved.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ID != saved.ID || loaded.Categories["composition"] != saved.Categories["composition"] {
+		t.Errorf("loaded %+v, want %+v", loaded, saved)
+	}
+}
+
+func TestApplies(t *testing.T) {
+	general := rubric.Default().ForGenre(rubric.General)
+	fitted := &Calibration{
+		ID:            "club-reference-20261018",
+		RubricID:      general.ID,
+		Scale:         general.Scale,
+		Models:        []string{"gemini-3-flash-preview"},
+		PromptVersion: "analysis-v7",
+	}
+	otherScale := general
+	otherScale.Scale = rubric.Scale{Min: 1, Max: 5}
+	portrait := rubric.Default().ForGenre(rubric.Portrait)
+	if portrait.Scale != general.Scale {
+		t.Fatalf("portrait scale %v differs from general %v", portrait.Scale, general.Scale)
+	}
+
+	tests := []struct {
+		name          string
+		calibration   *Calibration
+		rubric        rubric.Rubric
+		promptVersion string
+		model         string
+		wantApplies   bool
+	}{
+		{name: "fitted setup", calibration: fitted, rubric: general, promptVersion: "analysis-v7", model: "gemini-3-flash-preview", wantApplies: true},
+		{name: "other scale", calibration: fitted, rubric: otherScale, promptVersion: "analysis-v7", model: "gemini-3-flash-preview"},
+		// Same scale, different rubric: the categories mean different things.
+		{name: "other rubric", calibration: fitted, rubric: portrait, promptVersion: "analysis-v7", model: "gemini-3-flash-preview"},
+		{name: "prompt variant", calibration: fitted, rubric: general, promptVersion: "analysis-warm-v1", model: "gemini-3-flash-preview"},
+		{name: "fallback model", calibration: fitted, rubric: general, promptVersion: "analysis-v7", model: "gemini-2.5-flash"},
+		{name: "no calibration", rubric: general, promptVersion: "analysis-v7", model: "gemini-3-flash-preview"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := tt.calibration.SkipReason(tt.rubric, tt.promptVersion, tt.model)
+			if applies := tt.calibration.Applies(tt.rubric, tt.promptVersion, tt.model); applies != tt.wantApplies || (reason == "") != tt.wantApplies {
+				t.Errorf("Applies = %v (reason %q), want %v", applies, reason, tt.wantApplies)
+			}
+		})
+	}
+}
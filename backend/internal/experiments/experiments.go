@@ -0,0 +1,189 @@
+// Package experiments runs prompt A/B tests: users are assigned to a variant
+// of each experiment by hashing their ID, and outcomes are aggregated per variant.
+package experiments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/prompts"
+)
+
+// Experiment compares variants of one prompt template.
+type Experiment struct {
+	ID string `json:"id"`
+	// Template is the base prompt template name, e.g. "analysis".
+	Template string    `json:"template"`
+	Variants []Variant `json:"variants"`
+	// Disabled experiments assign nobody but keep their outcomes queryable.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// Variant is one arm of an experiment.
+type Variant struct {
+	Name string `json:"name"`
+	// Template is the prompt template used for this arm; empty means the base template.
+	Template string `json:"template,omitempty"`
+}
+
+// Assignments maps experiment IDs to the assigned variant name.
+type Assignments map[string]string
+
+// Manager holds the configured experiments and their outcome store.
+type Manager struct {
+	experiments []Experiment
+	store       OutcomeStore
+}
+
+// NewManager creates a manager for experiments recording into store.
+func NewManager(experiments []Experiment, store OutcomeStore) *Manager {
+	return &Manager{experiments: experiments, store: store}
+}
+
+// LoadFromEnv reads experiments from EXPERIMENTS_PATH (a JSON file) or
+// EXPERIMENTS_JSON and validates them against the prompt registry.
+func LoadFromEnv(registry *prompts.Registry) ([]Experiment, error) {
+	raw := []byte(strings.TrimSpace(os.Getenv("EXPERIMENTS_JSON")))
+	if path := strings.TrimSpace(os.Getenv("EXPERIMENTS_PATH")); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read EXPERIMENTS_PATH: %w", err)
+		}
+		raw = data
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var experiments []Experiment
+	if err := json.Unmarshal(raw, &experiments); err != nil {
+		return nil, fmt.Errorf("invalid experiments config: %w", err)
+	}
+	if err := Validate(experiments, registry); err != nil {
+		return nil, err
+	}
+	return experiments, nil
+}
+
+// Validate checks IDs, variant names and that every variant template
+// renders in place of its base template. Two active experiments on the same
+// template would confound each other, so that is rejected too.
+func Validate(experiments []Experiment, registry *prompts.Registry) error {
+	ids := map[string]bool{}
+	templates := map[string]string{}
+	for _, experiment := range experiments {
+		if experiment.ID == "" || ids[experiment.ID] {
+			return fmt.Errorf("experiment id %q is empty or duplicated", experiment.ID)
+		}
+		ids[experiment.ID] = true
+		if len(experiment.Variants) < 2 {
+			return fmt.Errorf("experiment %s needs at least two variants", experiment.ID)
+		}
+		if !experiment.Disabled {
+			if other, ok := templates[experiment.Template]; ok {
+				return fmt.Errorf("experiments %s and %s both vary template %s", other, experiment.ID, experiment.Template)
+			}
+			templates[experiment.Template] = experiment.ID
+		}
+		names := map[string]bool{}
+		for _, variant := range experiment.Variants {
+			if variant.Name == "" || names[variant.Name] {
+				return fmt.Errorf("experiment %s has an empty or duplicated variant name", experiment.ID)
+			}
+			names[variant.Name] = true
+			template := variant.Template
+			if template == "" {
+				template = experiment.Template
+			}
+			if err := registry.ValidateVariant(experiment.Template, template); err != nil {
+				return fmt.Errorf("experiment %s variant %s: %w", experiment.ID, variant.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// bucket deterministically picks a variant index for a user.
+func bucket(userID, experimentID string, variants int) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(experimentID))
+	hash.Write([]byte{0})
+	hash.Write([]byte(userID))
+	return int(hash.Sum32() % uint32(variants))
+}
+
+// Assign returns the user's variant in every active experiment.
+func (m *Manager) Assign(userID string) Assignments {
+	assignments := Assignments{}
+	for _, experiment := range m.experiments {
+		if experiment.Disabled {
+			continue
+		}
+		assignments[experiment.ID] = experiment.Variants[bucket(userID, experiment.ID, len(experiment.Variants))].Name
+	}
+	return assignments
+}
+
+// WithPrompts returns a context that renders the assigned variant templates.
+func (m *Manager) WithPrompts(ctx context.Context, assignments Assignments) context.Context {
+	overrides := map[string]string{}
+	for _, experiment := range m.experiments {
+		name, ok := assignments[experiment.ID]
+		if !ok || experiment.Disabled {
+			continue
+		}
+		for _, variant := range experiment.Variants {
+			if variant.Name == name && variant.Template != "" {
+				overrides[experiment.Template] = variant.Template
+			}
+		}
+	}
+	return prompts.WithOverrides(ctx, overrides)
+}
+
+// Record adds counts to every assigned variant's outcomes.
+func (m *Manager) Record(ctx context.Context, assignments Assignments, counts Counts) error {
+	var errs []error
+	for experimentID, variant := range assignments {
+		if err := m.store.Add(ctx, experimentID, variant, counts); err != nil {
+			errs = append(errs, fmt.Errorf("experiment %s: %w", experimentID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Report is the outcome summary of one experiment.
+type Report struct {
+	Experiment
+	Results []VariantSummary `json:"results"`
+}
+
+// Reports summarizes every configured experiment.
+func (m *Manager) Reports(ctx context.Context) ([]Report, error) {
+	reports := make([]Report, 0, len(m.experiments))
+	for _, experiment := range m.experiments {
+		report := Report{Experiment: experiment}
+		for _, variant := range experiment.Variants {
+			counts, err := m.store.Get(ctx, experiment.ID, variant.Name)
+			if err != nil {
+				return nil, err
+			}
+			report.Results = append(report.Results, counts.Summary(variant.Name))
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// ParseAssignments decodes assignments stored in session state.
+func ParseAssignments(raw string) Assignments {
+	assignments := Assignments{}
+	if strings.TrimSpace(raw) != "" {
+		_ = json.Unmarshal([]byte(raw), &assignments)
+	}
+	return assignments
+}
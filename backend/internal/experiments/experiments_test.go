@@ -0,0 +1,118 @@
+package experiments
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/prompts"
+)
+
+func testRegistry(t *testing.T) *prompts.Registry {
+	t.Helper()
+	dir := t.TempDir()
+	variant := "---\nversion: analysis-warm-v1\n---\n優しい口調で講評してください。"
+	if err := os.WriteFile(filepath.Join(dir, "analysis.warm.tmpl"), []byte(variant), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := prompts.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+func warmExperiment() Experiment {
+	return Experiment{
+		ID:       "analysis-tone",
+		Template: prompts.Analysis,
+		Variants: []Variant{{Name: "control"}, {Name: "warm", Template: "analysis.warm"}},
+	}
+}
+
+func TestAssignIsStickyAndBalanced(t *testing.T) {
+	manager := NewManager([]Experiment{warmExperiment()}, NewMemoryOutcomeStore())
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		variant := manager.Assign(userID)["analysis-tone"]
+		if again := manager.Assign(userID)["analysis-tone"]; again != variant {
+			t.Fatalf("%s assigned %s then %s", userID, variant, again)
+		}
+		counts[variant]++
+	}
+	if counts["control"] < 400 || counts["warm"] < 400 {
+		t.Fatalf("unbalanced assignment: %v", counts)
+	}
+}
+
+func TestWithPromptsRendersVariant(t *testing.T) {
+	registry := testRegistry(t)
+	manager := NewManager([]Experiment{warmExperiment()}, NewMemoryOutcomeStore())
+
+	ctx := manager.WithPrompts(context.Background(), Assignments{"analysis-tone": "warm"})
+	text, version, err := registry.RenderContext(ctx, prompts.Analysis, prompts.AnalysisData{})
+	if err != nil || version != "analysis-warm-v1" || !strings.Contains(text, "優しい口調") {
+		t.Fatalf("variant render = %q, %q, %v", text, version, err)
+	}
+
+	ctx = manager.WithPrompts(context.Background(), Assignments{"analysis-tone": "control"})
+	if version := registry.VersionContext(ctx, prompts.Analysis); version != registry.Version(prompts.Analysis) {
+		t.Fatalf("control version = %q", version)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	registry := testRegistry(t)
+	tests := []struct {
+		name        string
+		experiments []Experiment
+		wantErr     string
+	}{
+		{name: "valid", experiments: []Experiment{warmExperiment()}},
+		{name: "one variant", experiments: []Experiment{{ID: "x", Template: prompts.Analysis, Variants: []Variant{{Name: "a"}}}}, wantErr: "two variants"},
+		{name: "missing template", experiments: []Experiment{{ID: "x", Template: prompts.Analysis, Variants: []Variant{{Name: "a"}, {Name: "b", Template: "nope"}}}}, wantErr: "not found"},
+		{name: "variant of unknown base", experiments: []Experiment{{ID: "x", Template: "nope", Variants: []Variant{{Name: "a"}, {Name: "b"}}}}, wantErr: "cannot have variants"},
+		{name: "overlapping", experiments: []Experiment{warmExperiment(), func() Experiment { e := warmExperiment(); e.ID = "other"; return e }()}, wantErr: "both vary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.experiments, registry)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReports(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager([]Experiment{warmExperiment()}, NewMemoryOutcomeStore())
+	warm := Assignments{"analysis-tone": "warm"}
+	_ = manager.Record(ctx, warm, Counts{Analyses: 1, ScoreSum: 6, FidelityChecks: 1, FidelityFailures: 1})
+	_ = manager.Record(ctx, warm, Counts{Analyses: 1, ScoreSum: 8, FidelityChecks: 1})
+	_ = manager.Record(ctx, warm, Counts{ChatFollowUps: 3})
+	_ = manager.Record(ctx, warm, Counts{FeedbackCount: 1, FeedbackSum: 4})
+
+	reports, err := manager.Reports(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := reports[0].Results[1]
+	if summary.Variant != "warm" || summary.AverageScore != 7 || summary.FollowUpsPerAnalysis != 1.5 ||
+		summary.FidelityFailureRate != 0.5 || summary.AverageFeedback != 4 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if control := reports[0].Results[0]; control.Analyses != 0 {
+		t.Fatalf("control has outcomes: %+v", control)
+	}
+}
@@ -0,0 +1,151 @@
+package experiments
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Counts are the outcome counters kept per experiment variant.
+type Counts struct {
+	Analyses         int     `json:"analyses" firestore:"analyses"`
+	ScoreSum         float64 `json:"scoreSum" firestore:"scoreSum"`
+	FidelityChecks   int     `json:"fidelityChecks" firestore:"fidelityChecks"`
+	FidelityFailures int     `json:"fidelityFailures" firestore:"fidelityFailures"`
+	ChatFollowUps    int     `json:"chatFollowUps" firestore:"chatFollowUps"`
+	FeedbackCount    int     `json:"feedbackCount" firestore:"feedbackCount"`
+	FeedbackSum      float64 `json:"feedbackSum" firestore:"feedbackSum"`
+}
+
+func (c *Counts) add(other Counts) {
+	c.Analyses += other.Analyses
+	c.ScoreSum += other.ScoreSum
+	c.FidelityChecks += other.FidelityChecks
+	c.FidelityFailures += other.FidelityFailures
+	c.ChatFollowUps += other.ChatFollowUps
+	c.FeedbackCount += other.FeedbackCount
+	c.FeedbackSum += other.FeedbackSum
+}
+
+// VariantSummary is the aggregated outcome of one variant.
+type VariantSummary struct {
+	Variant string `json:"variant"`
+	Counts
+	AverageScore float64 `json:"averageScore"`
+	// FollowUpsPerAnalysis is the mean number of chat turns after an analysis.
+	FollowUpsPerAnalysis float64 `json:"followUpsPerAnalysis"`
+	FidelityFailureRate  float64 `json:"fidelityFailureRate"`
+	AverageFeedback      float64 `json:"averageFeedback"`
+}
+
+// Summary derives the averages from the counters.
+func (c Counts) Summary(variant string) VariantSummary {
+	summary := VariantSummary{Variant: variant, Counts: c}
+	if c.Analyses > 0 {
+		summary.AverageScore = c.ScoreSum / float64(c.Analyses)
+		summary.FollowUpsPerAnalysis = float64(c.ChatFollowUps) / float64(c.Analyses)
+	}
+	if c.FidelityChecks > 0 {
+		summary.FidelityFailureRate = float64(c.FidelityFailures) / float64(c.FidelityChecks)
+	}
+	if c.FeedbackCount > 0 {
+		summary.AverageFeedback = c.FeedbackSum / float64(c.FeedbackCount)
+	}
+	return summary
+}
+
+// OutcomeStore accumulates Counts per experiment variant.
+type OutcomeStore interface {
+	Add(ctx context.Context, experimentID, variant string, counts Counts) error
+	Get(ctx context.Context, experimentID, variant string) (Counts, error)
+}
+
+// MemoryOutcomeStore is an in-process OutcomeStore.
+type MemoryOutcomeStore struct {
+	mu     sync.Mutex
+	counts map[string]Counts
+}
+
+// NewMemoryOutcomeStore creates an empty in-memory outcome store.
+func NewMemoryOutcomeStore() *MemoryOutcomeStore {
+	return &MemoryOutcomeStore{counts: map[string]Counts{}}
+}
+
+func (m *MemoryOutcomeStore) Add(ctx context.Context, experimentID, variant string, counts Counts) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := experimentID + "/" + variant
+	current := m.counts[key]
+	current.add(counts)
+	m.counts[key] = current
+	return nil
+}
+
+func (m *MemoryOutcomeStore) Get(ctx context.Context, experimentID, variant string) (Counts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counts[experimentID+"/"+variant], nil
+}
+
+const outcomesCollection = "experiment_outcomes"
+
+// FirestoreOutcomeStore keeps one document per experiment variant, updated
+// with atomic increments.
+type FirestoreOutcomeStore struct {
+	client *firestore.Client
+}
+
+// NewFirestoreOutcomeStore creates a Firestore-backed outcome store.
+func NewFirestoreOutcomeStore(ctx context.Context, projectID string) (*FirestoreOutcomeStore, error) {
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	return &FirestoreOutcomeStore{client: client}, nil
+}
+
+// Close closes the Firestore client.
+func (s *FirestoreOutcomeStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *FirestoreOutcomeStore) docRef(experimentID, variant string) *firestore.DocumentRef {
+	return s.client.Collection(outcomesCollection).Doc(experimentID + "_" + variant)
+}
+
+func (s *FirestoreOutcomeStore) Add(ctx context.Context, experimentID, variant string, counts Counts) error {
+	_, err := s.docRef(experimentID, variant).Set(ctx, map[string]any{
+		"experimentId":     experimentID,
+		"variant":          variant,
+		"analyses":         firestore.Increment(counts.Analyses),
+		"scoreSum":         firestore.Increment(counts.ScoreSum),
+		"fidelityChecks":   firestore.Increment(counts.FidelityChecks),
+		"fidelityFailures": firestore.Increment(counts.FidelityFailures),
+		"chatFollowUps":    firestore.Increment(counts.ChatFollowUps),
+		"feedbackCount":    firestore.Increment(counts.FeedbackCount),
+		"feedbackSum":      firestore.Increment(counts.FeedbackSum),
+	}, firestore.MergeAll)
+	if err != nil {
+		return fmt.Errorf("failed to record experiment outcome: %w", err)
+	}
+	return nil
+}
+
+func (s *FirestoreOutcomeStore) Get(ctx context.Context, experimentID, variant string) (Counts, error) {
+	var counts Counts
+	snapshot, err := s.docRef(experimentID, variant).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return counts, nil
+	}
+	if err != nil {
+		return counts, fmt.Errorf("failed to read experiment outcome: %w", err)
+	}
+	if err := snapshot.DataTo(&counts); err != nil {
+		return counts, fmt.Errorf("failed to decode experiment outcome: %w", err)
+	}
+	return counts, nil
+}
@@ -1,23 +1,36 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"google.golang.org/adk/session"
 	"google.golang.org/genai"
 
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/experiments"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/purpose"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/quota"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
 	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/skill"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/usage"
 )
 
 // AnalyzeHandler handles photo analysis requests
@@ -60,14 +73,41 @@ func (h *AnalyzeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		userID = "anonymous"
 	}
 
+	options, err := parseAnalysisOptions(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	settings, err := resolveSettings(r.Context(), h.deps, r, userID, r.FormValue("locale"), r.FormValue("timeZone"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	options.Settings = settings
+	options.SkillLevel, err = resolveSkillLevel(r.Context(), h.deps, userID, r.FormValue("skillLevel"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Read file into memory for async processing
 	imageData, err := io.ReadAll(file)
 	if err != nil {
 		writeJSONError(w, http.StatusBadRequest, "Failed to read file")
 		return
 	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(imageData)); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid image")
+		return
+	}
 	contentType := header.Header.Get("Content-Type")
 
+	// Quota is claimed only for valid requests; processAnalysis releases it
+	// when the job fails before any model call.
+	if !reserveQuota(w, r.Context(), h.deps, userID, quota.ActionAnalysis) {
+		return
+	}
+
 	// Create job and return immediately
 	jobID := uuid.New().String()
 	jobStore := GetJobStore()
@@ -79,7 +119,7 @@ func (h *AnalyzeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	baseURL := resolveBaseURL(r)
 
 	// Start async processing
-	go h.processAnalysis(jobID, userID, sessionID, imageData, contentType, baseURL)
+	go h.processAnalysis(jobID, userID, sessionID, imageData, contentType, baseURL, options)
 
 	// Return job ID immediately
 	writeJSON(w, http.StatusAccepted, map[string]string{
@@ -88,17 +128,133 @@ func (h *AnalyzeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// analysisOptions are the optional per-request switches of an analysis job.
+type analysisOptions struct {
+	// Upscale brings the clean enhancement back to the full upload resolution.
+	Upscale bool
+	// Settings select the language and timezone of the critique.
+	Settings locale.Settings
+	// Genre overrides the detected genre when set.
+	Genre rubric.Genre
+	// RubricID selects a rubric regardless of the genre when set.
+	RubricID string
+	// Samples above 1 run an ensemble analysis; each sample costs a model call.
+	Samples int
+	// SkillLevel sets the tone and strictness of the critique; empty when unknown.
+	SkillLevel skill.Level
+	// Purpose decides what a good photo is; contest when not given.
+	Purpose purpose.Purpose
+	// Brief is a contest theme or rules in free text to judge the photo against.
+	Brief string
+}
+
+// defaultAnalysisJobTimeout bounds a whole analysis job, retries included.
+const defaultAnalysisJobTimeout = 5 * time.Minute
+
+// analysisJobTimeout returns the job deadline (ANALYSIS_JOB_TIMEOUT, e.g. "3m").
+func analysisJobTimeout() time.Duration {
+	raw := strings.TrimSpace(os.Getenv("ANALYSIS_JOB_TIMEOUT"))
+	if raw == "" {
+		return defaultAnalysisJobTimeout
+	}
+	timeout, err := time.ParseDuration(raw)
+	if err != nil || timeout <= 0 {
+		log.Printf("WARN: Invalid ANALYSIS_JOB_TIMEOUT %q, using %s", raw, defaultAnalysisJobTimeout)
+		return defaultAnalysisJobTimeout
+	}
+	return timeout
+}
+
+func parseAnalysisOptions(r *http.Request) (analysisOptions, error) {
+	upscale, _ := strconv.ParseBool(r.FormValue("upscale"))
+	options := analysisOptions{Upscale: upscale}
+	if raw := strings.TrimSpace(r.FormValue("genre")); raw != "" {
+		genre, ok := rubric.ParseGenre(raw)
+		if !ok {
+			return options, fmt.Errorf("unsupported genre %q", raw)
+		}
+		options.Genre = genre
+	}
+	if id := strings.TrimSpace(r.FormValue("rubric")); id != "" {
+		if _, ok := rubric.Default().Get(id); !ok {
+			return options, fmt.Errorf("unknown rubric %q", id)
+		}
+		options.RubricID = id
+	}
+	if raw := strings.TrimSpace(r.FormValue("purpose")); raw != "" {
+		p, ok := purpose.Parse(raw)
+		if !ok {
+			return options, fmt.Errorf("unsupported purpose %q", raw)
+		}
+		options.Purpose = p
+	}
+	if brief := strings.TrimSpace(r.FormValue("brief")); brief != "" {
+		if utf8.RuneCountInString(brief) > services.MaxBriefLength {
+			return options, fmt.Errorf("brief must be at most %d characters", services.MaxBriefLength)
+		}
+		options.Brief = brief
+	}
+	if raw := strings.TrimSpace(r.FormValue("samples")); raw != "" {
+		samples, err := strconv.Atoi(raw)
+		if err != nil || samples < 1 || samples > services.MaxEnsembleSamples() {
+			return options, fmt.Errorf("samples must be between 1 and %d", services.MaxEnsembleSamples())
+		}
+		options.Samples = samples
+	}
+	return options, nil
+}
+
 // processAnalysis runs the analysis in background
-func (h *AnalyzeHandler) processAnalysis(jobID, userID, sessionID string, imageData []byte, contentType string, baseURL string) {
+func (h *AnalyzeHandler) processAnalysis(jobID, userID, sessionID string, imageData []byte, contentType string, baseURL string, options analysisOptions) {
 	jobStore := GetJobStore()
 	jobStore.SetProcessing(jobID)
 
-	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(context.Background(), analysisJobTimeout())
+	defer cancel()
+	ctx, trace := services.WithCallTrace(ctx)
+	ctx = locale.WithSettings(ctx, options.Settings)
+	if options.Genre != "" {
+		ctx = rubric.WithGenre(ctx, options.Genre)
+	}
+	if options.RubricID != "" {
+		ctx = rubric.WithID(ctx, options.RubricID)
+	}
+	if options.Samples > 1 {
+		ctx = services.WithEnsemble(ctx, options.Samples)
+	}
+	if options.SkillLevel != "" {
+		ctx = skill.WithLevel(ctx, options.SkillLevel)
+	}
+	if options.Purpose != "" {
+		ctx = purpose.WithPurpose(ctx, options.Purpose)
+	}
+	if options.Brief != "" {
+		ctx = services.WithBrief(ctx, options.Brief)
+	}
+	// The model sees a resized copy, so print adequacy is judged from the upload.
+	if config, _, err := image.DecodeConfig(bytes.NewReader(imageData)); err == nil {
+		ctx = purpose.WithSourceSize(ctx, config.Width, config.Height)
+	}
+	jobStore.SetCallTrace(jobID, trace)
+	assignments := h.deps.Experiments.Assign(userID)
+	ctx = h.deps.Experiments.WithPrompts(ctx, assignments)
+	// Failed jobs cost money too, so the ledger is updated on every exit;
+	// jobs that failed before the analysis started get their quota back.
+	analysisStarted := false
+	defer func() {
+		if !analysisStarted {
+			h.deps.Quota.Release(context.WithoutCancel(ctx), userID, quota.ActionAnalysis)
+			return
+		}
+		report := trace.Usage()
+		recordLedgerUsage(context.WithoutCancel(ctx), h.deps, userID, usage.ActivityAnalysis, report)
+		h.deps.Quota.Settle(context.WithoutCancel(ctx), userID, quota.ActionAnalysis, report)
+	}()
 
 	// Storage client
-	storageClient, err := services.NewStorageClient(ctx)
-	if err != nil {
-		log.Printf("ERROR: Job %s - Storage client error: %v", jobID, err)
+	storageClient := h.deps.Storage
+	if storageClient == nil {
+		log.Printf("ERROR: Job %s - Storage client error: no object store configured", jobID)
 		jobStore.SetFailed(jobID, "Storage client error")
 		return
 	}
@@ -121,30 +277,54 @@ func (h *AnalyzeHandler) processAnalysis(jobID, userID, sessionID string, imageD
 		return
 	}
 
+	// Keep the untouched upload for full-resolution region crops, only when
+	// the user asked for full-resolution work. It still carries its EXIF
+	// (camera, GPS), so it is read server-side and never served by the proxy.
+	fullResolutionObject := ""
+	if options.Upscale {
+		if _, objectName, err := storageClient.UploadImageWithPrefix(ctx, imageData, services.DetectImageMIMEType(imageData), "originals"); err != nil {
+			log.Printf("WARN: Job %s - Failed to store full-resolution original, continuing: %v", jobID, err)
+		} else {
+			fullResolutionObject = objectName
+		}
+	}
+
+	decodedOriginal, _, err := image.Decode(bytes.NewReader(resized))
+	if err != nil {
+		log.Printf("ERROR: Job %s - Failed to decode resized image: %v", jobID, err)
+		jobStore.SetFailed(jobID, "Invalid image")
+		return
+	}
+	source := &sourcePhoto{url: imageURL, contentType: resizedContentType, image: decodedOriginal}
+
 	// Analyze with agent
+	analysisStarted = true
 	analysis, err := analyzeWithAgent(ctx, h.deps, userID, sessionID, imageURL)
 	if err != nil {
 		log.Printf("ERROR: Job %s - Failed to analyze image: %v", jobID, err)
 		jobStore.SetFailed(jobID, err.Error())
 		return
 	}
+	// Size and date rules are checked on the upload, not the resized copy.
+	services.CheckBriefRules(ctx, analysis, imageData)
 
 	// Generate enhanced images in parallel (annotated + clean)
 	type imageResult struct {
-		url string
-		err error
+		url   string
+		clean *cleanEnhancement
+		err   error
 	}
 
 	annotatedCh := make(chan imageResult, 1)
 	cleanCh := make(chan imageResult, 1)
 
 	go func() {
-		url, err := generateEnhancedImage(ctx, storageClient, imageURL, analysis, baseURL)
-		annotatedCh <- imageResult{url, err}
+		url, err := generateEnhancedImage(ctx, h.deps.Enhancer, storageClient, source, analysis, baseURL)
+		annotatedCh <- imageResult{url: url, err: err}
 	}()
 	go func() {
-		url, err := generateCleanEnhancedImage(ctx, storageClient, imageURL, analysis, baseURL)
-		cleanCh <- imageResult{url, err}
+		clean, err := generateCleanEnhancedImage(ctx, h.deps.Enhancer, storageClient, source, analysis, baseURL)
+		cleanCh <- imageResult{clean: clean, err: err}
 	}()
 
 	annotatedRes := <-annotatedCh
@@ -163,12 +343,34 @@ func (h *AnalyzeHandler) processAnalysis(jobID, userID, sessionID string, imageD
 		enhancedURL = ""
 	}
 
-	cleanEnhancedURL := cleanRes.url
+	cleanEnhancedURL := ""
+	heatmapURL := ""
+	upscaledURL := ""
+	var fidelity *services.FidelityResult
+	if cleanRes.clean != nil {
+		fidelity = cleanRes.clean.fidelity
+	}
 	if cleanRes.err != nil {
 		log.Printf("WARN: Job %s - Clean image generation failed, continuing: %v", jobID, cleanRes.err)
-		cleanEnhancedURL = ""
+	} else {
+		cleanEnhancedURL = cleanRes.clean.url
+		heatmapURL, err = generateDifferenceHeatmap(ctx, storageClient, source.image, cleanRes.clean.image, baseURL)
+		if err != nil {
+			log.Printf("WARN: Job %s - Difference heatmap generation failed, continuing: %v", jobID, err)
+			heatmapURL = ""
+		}
+		if options.Upscale {
+			upscaledURL, err = generateUpscaledImage(ctx, storageClient, imageData, cleanRes.clean.image, resizedContentType, baseURL)
+			if err != nil {
+				log.Printf("WARN: Job %s - Upscaling failed, continuing: %v", jobID, err)
+				upscaledURL = ""
+			}
+		}
 	}
 
+	servedModels := trace.Models()
+	recordAnalysisOutcome(ctx, h.deps, assignments, analysis, fidelity)
+
 	// Update session state with all analysis data
 	resolvedSessionID, resolveErr := resolveSessionID(ctx, h.deps.SessionService, "photo_levelup", userID, sessionID)
 	if resolveErr != nil {
@@ -217,6 +419,66 @@ func (h *AnalyzeHandler) processAnalysis(jobID, userID, sessionID string, imageD
 		if analysisJSON != nil {
 			stateUpdates["analysis_result"] = string(analysisJSON)
 		}
+		stateUpdates["locale"] = string(options.Settings.Locale)
+		if analysis.PromptVersion != "" {
+			stateUpdates["prompt_version"] = analysis.PromptVersion
+		}
+		if analysis.Genre != "" {
+			stateUpdates["genre"] = analysis.Genre
+		}
+		if analysis.Purpose != "" {
+			stateUpdates["purpose"] = analysis.Purpose
+		}
+		if analysis.Theme != nil {
+			stateUpdates["brief"] = analysis.Theme.Brief
+			stateUpdates["theme_score"] = analysis.Theme.Score
+		}
+		if analysis.SkillLevel != "" {
+			stateUpdates["skill_level"] = analysis.SkillLevel
+		}
+		if analysis.CalibrationID != "" {
+			stateUpdates["calibration_id"] = analysis.CalibrationID
+			stateUpdates["raw_overall_score"] = analysis.RawOverallScore
+		}
+		if analysis.RubricID != "" {
+			stateUpdates["rubric_id"] = analysis.RubricID
+			stateUpdates["score_max"] = analysis.MaxScore()
+		}
+		if fullResolutionObject != "" {
+			stateUpdates["full_resolution_object"] = fullResolutionObject
+		}
+		if heatmapURL != "" {
+			stateUpdates["heatmap_image_url"] = heatmapURL
+		}
+		if upscaledURL != "" {
+			stateUpdates["upscaled_image_url"] = upscaledURL
+		}
+		if report := trace.Usage(); !report.Empty() {
+			if sessResp, err := h.deps.SessionService.Get(ctx, &session.GetRequest{
+				AppName:   "photo_levelup",
+				UserID:    userID,
+				SessionID: resolvedSessionID,
+			}); err == nil {
+				if merged, err := mergeSessionUsage(sessResp.Session.State(), report); err == nil {
+					stateUpdates["usage"] = merged
+				}
+			}
+		}
+		if len(assignments) > 0 {
+			if assignmentsJSON, err := json.Marshal(assignments); err == nil {
+				stateUpdates["experiments"] = string(assignmentsJSON)
+			}
+		}
+		if servedModels != nil {
+			if modelsJSON, err := json.Marshal(servedModels); err == nil {
+				stateUpdates["models"] = string(modelsJSON)
+			}
+		}
+		if fidelity != nil {
+			if fidelityJSON, err := json.Marshal(fidelity); err == nil {
+				stateUpdates["fidelity_result"] = string(fidelityJSON)
+			}
+		}
 
 		if err := updateSessionState(ctx, h.deps.SessionService, userID, resolvedSessionID, stateUpdates); err != nil {
 			log.Printf("WARN: Job %s - Failed to update session state: %v", jobID, err)
@@ -234,11 +496,36 @@ func (h *AnalyzeHandler) processAnalysis(jobID, userID, sessionID string, imageD
 		CleanEnhancedImageURL: cleanEnhancedURL,
 		Analysis:              *analysis,
 		InitialAdvice:         analysis.Summary,
+		Fidelity:              fidelity,
+		HeatmapImageURL:       heatmapURL,
+		UpscaledImageURL:      upscaledURL,
+		Models:                servedModels,
+		Experiments:           assignments,
 	}
+	result.CacheHits, result.CacheSavedUSD = trace.CacheHits()
 	jobStore.SetCompleted(jobID, result)
 	log.Printf("INFO: Job %s - Completed successfully", jobID)
 }
 
+// recordAnalysisOutcome adds a finished analysis to its experiment variants.
+// A fidelity check that needed a strict retry counts as a failure, since
+// the first attempt is what the variant prompt produced.
+func recordAnalysisOutcome(ctx context.Context, deps *Dependencies, assignments experiments.Assignments, analysis *services.AnalysisResult, fidelity *services.FidelityResult) {
+	if len(assignments) == 0 {
+		return
+	}
+	counts := experiments.Counts{Analyses: 1, ScoreSum: float64(analysis.OverallScore)}
+	if fidelity != nil {
+		counts.FidelityChecks = 1
+		if !fidelity.Passed || fidelity.Retried {
+			counts.FidelityFailures = 1
+		}
+	}
+	if err := deps.Experiments.Record(ctx, assignments, counts); err != nil {
+		log.Printf("WARN: Failed to record experiment outcome: %v", err)
+	}
+}
+
 // AnalyzeStatusHandler handles job status queries
 type AnalyzeStatusHandler struct{}
 
@@ -280,46 +567,93 @@ func (h *AnalyzeStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		response["error"] = job.Error
 	}
 
+	if job.Calls != nil {
+		response["retries"] = job.Calls.Retries()
+		if class := job.Calls.ErrorClass(); class != "" {
+			response["errorClass"] = string(class)
+		}
+		if report := job.Calls.Usage(); !report.Empty() {
+			response["usage"] = report
+		}
+	}
+
 	writeJSON(w, http.StatusOK, response)
 }
 
 func analyzeWithAgent(ctx context.Context, deps *Dependencies, userID, sessionID string, imageURL string) (*services.AnalysisResult, error) {
 	log.Printf("INFO: Starting direct image analysis for user %s, session %s", userID, sessionID)
 
-	// Call Gemini API directly for reliable image analysis
-	geminiClient := services.NewGeminiClient()
-	result, err := geminiClient.AnalyzeImage(ctx, imageURL)
+	// Call the analysis model directly for reliable image analysis
+	result, err := deps.Analyzer.AnalyzeImage(ctx, imageURL)
 	if err != nil {
 		log.Printf("ERROR: Direct image analysis failed: %v", err)
-		return nil, fmt.Errorf("画像分析に失敗しました: %w", err)
+		return nil, fmt.Errorf("%s: %w", locale.FromContext(ctx).T("error.analysisFailed"), err)
 	}
 
 	log.Printf("INFO: Direct image analysis completed successfully for user %s, session %s", userID, sessionID)
 	return result, nil
 }
 
+// sourcePhoto is the resized upload that every generated image is aligned to.
+type sourcePhoto struct {
+	url         string
+	contentType string
+	image       image.Image
+}
+
+// generateEnhancedImage produces the annotated ("red pen") variant. The
+// structured annotations from the analysis are rendered locally onto the
+// original; the image model is only asked to draw them when there are none.
 func generateEnhancedImage(
 	ctx context.Context,
-	storageClient *services.StorageClient,
-	imageURL string,
+	enhancer services.Enhancer,
+	storageClient services.ObjectStore,
+	source *sourcePhoto,
+	analysis *services.AnalysisResult,
+	baseURL string,
+) (string, error) {
+	if len(analysis.Annotations) == 0 {
+		log.Printf("WARN: Analysis returned no annotations, falling back to generated annotated image")
+		return generateModelAnnotatedImage(ctx, enhancer, storageClient, source, analysis, baseURL)
+	}
+
+	processor := services.NewImageProcessor()
+	imageData, contentType, err := processor.Encode(services.RenderAnnotations(source.image, analysis.Annotations), source.contentType)
+	if err != nil {
+		return "", err
+	}
+
+	_, objectName, err := storageClient.UploadImageWithPrefix(ctx, imageData, contentType, "enhanced")
+	if err != nil {
+		return "", err
+	}
+
+	return buildImageProxyURL(baseURL, objectName)
+}
+
+// generateModelAnnotatedImage asks the image model to draw the red-pen annotations itself.
+func generateModelAnnotatedImage(
+	ctx context.Context,
+	enhancer services.Enhancer,
+	storageClient services.ObjectStore,
+	source *sourcePhoto,
 	analysis *services.AnalysisResult,
 	baseURL string,
 ) (string, error) {
-	geminiClient := services.NewGeminiClient()
-	result, err := geminiClient.EnhancePhoto(ctx, services.EnhancementInput{
-		ImageURL: imageURL,
+	result, err := enhancer.EnhancePhoto(ctx, services.EnhancementInput{
+		ImageURL: source.url,
 		Analysis: analysis,
 	})
 	if err != nil {
 		return "", err
 	}
 
-	imageData, err := base64.StdEncoding.DecodeString(result.ImageBase64)
+	imageData, contentType, _, err := normalizeGeneratedImage(result, source)
 	if err != nil {
 		return "", err
 	}
 
-	_, objectName, err := storageClient.UploadImageWithPrefix(ctx, imageData, "image/jpeg", "enhanced")
+	_, objectName, err := storageClient.UploadImageWithPrefix(ctx, imageData, contentType, "enhanced")
 	if err != nil {
 		return "", err
 	}
@@ -327,35 +661,132 @@ func generateEnhancedImage(
 	return buildImageProxyURL(baseURL, objectName)
 }
 
+// cleanEnhancement is an uploaded clean enhancement with its fidelity check.
+type cleanEnhancement struct {
+	url      string
+	image    image.Image
+	fidelity *services.FidelityResult
+}
+
 func generateCleanEnhancedImage(
 	ctx context.Context,
-	storageClient *services.StorageClient,
-	imageURL string,
+	enhancer services.Enhancer,
+	storageClient services.ObjectStore,
+	source *sourcePhoto,
 	analysis *services.AnalysisResult,
 	baseURL string,
-) (string, error) {
-	geminiClient := services.NewGeminiClient()
-	result, err := geminiClient.EnhancePhotoClean(ctx, services.EnhancementInput{
-		ImageURL: imageURL,
+) (*cleanEnhancement, error) {
+	input := services.EnhancementInput{
+		ImageURL: source.url,
 		Analysis: analysis,
-	})
+	}
+	imageData, contentType, enhanced, fidelity, err := enhanceWithFidelity(ctx, enhancer, input, source)
 	if err != nil {
+		return nil, err
+	}
+
+	if !fidelity.Passed {
+		log.Printf("WARN: Clean enhancement fidelity %.3f below threshold %.2f, retrying with strict prompt", fidelity.Score, fidelity.Threshold)
+		input.Strict = true
+		retryData, retryContentType, retryImage, retryFidelity, retryErr := enhanceWithFidelity(ctx, enhancer, input, source)
+		if retryErr != nil {
+			log.Printf("WARN: Strict clean enhancement retry failed: %v", retryErr)
+		} else if retryFidelity.Score > fidelity.Score {
+			imageData, contentType, enhanced, fidelity = retryData, retryContentType, retryImage, retryFidelity
+		}
+		fidelity.Retried = true
+	}
+
+	result := &cleanEnhancement{image: enhanced, fidelity: fidelity}
+	if !fidelity.Passed && services.CurrentFidelityMode() == services.FidelityModeReject {
+		return result, fmt.Errorf("enhanced image rejected: fidelity %.3f below threshold %.2f", fidelity.Score, fidelity.Threshold)
+	}
+
+	_, objectName, err := storageClient.UploadImageWithPrefix(ctx, imageData, contentType, "clean_enhanced")
+	if err != nil {
+		return result, err
+	}
+
+	result.url, err = buildImageProxyURL(baseURL, objectName)
+	return result, err
+}
+
+// generateDifferenceHeatmap renders and uploads the original/enhanced difference heatmap.
+func generateDifferenceHeatmap(ctx context.Context, storageClient services.ObjectStore, original, enhanced image.Image, baseURL string) (string, error) {
+	buffer := &bytes.Buffer{}
+	if err := png.Encode(buffer, services.RenderDifferenceHeatmap(original, enhanced)); err != nil {
 		return "", err
 	}
 
-	imageData, err := base64.StdEncoding.DecodeString(result.ImageBase64)
+	_, objectName, err := storageClient.UploadImageWithPrefix(ctx, buffer.Bytes(), "image/png", "heatmaps")
 	if err != nil {
 		return "", err
 	}
+	return buildImageProxyURL(baseURL, objectName)
+}
+
+// generateUpscaledImage upsamples the clean enhancement to the full-resolution
+// upload, transferring the original's fine detail, and uploads the result.
+func generateUpscaledImage(ctx context.Context, storageClient services.ObjectStore, fullResolutionData []byte, enhanced image.Image, contentType string, baseURL string) (string, error) {
+	original, _, err := image.Decode(bytes.NewReader(fullResolutionData))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode full-resolution original: %w", err)
+	}
 
-	_, objectName, err := storageClient.UploadImageWithPrefix(ctx, imageData, "image/jpeg", "clean_enhanced")
+	processor := services.NewImageProcessor()
+	imageData, encodedType, err := processor.Encode(services.UpscaleWithDetail(enhanced, original), contentType)
 	if err != nil {
 		return "", err
 	}
 
+	_, objectName, err := storageClient.UploadImageWithPrefix(ctx, imageData, encodedType, "upscaled")
+	if err != nil {
+		return "", err
+	}
 	return buildImageProxyURL(baseURL, objectName)
 }
 
+// enhanceWithFidelity generates a clean enhancement, normalizes it and scores it against the original.
+func enhanceWithFidelity(ctx context.Context, enhancer services.Enhancer, input services.EnhancementInput, source *sourcePhoto) ([]byte, string, image.Image, *services.FidelityResult, error) {
+	result, err := enhancer.EnhancePhotoClean(ctx, input)
+	if err != nil {
+		return nil, "", nil, nil, err
+	}
+
+	imageData, contentType, enhanced, err := normalizeGeneratedImage(result, source)
+	if err != nil {
+		return nil, "", nil, nil, err
+	}
+
+	fidelity := services.MeasureFidelity(source.image, enhanced, services.FidelityThreshold())
+	log.Printf("INFO: Clean enhancement fidelity score=%.3f (ssim=%.3f, edges=%.3f, worst tile=%.3f)",
+		fidelity.Score, fidelity.SSIM, fidelity.EdgeOverlap, fidelity.WorstTileScore)
+	return imageData, contentType, enhanced, fidelity, nil
+}
+
+// normalizeGeneratedImage transcodes a generated image to the source's format
+// and exact dimensions so before/after views line up.
+func normalizeGeneratedImage(result *services.ImageGenerationResult, source *sourcePhoto) ([]byte, string, image.Image, error) {
+	generated, err := base64.StdEncoding.DecodeString(result.ImageBase64)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	bounds := source.image.Bounds()
+	processor := services.NewImageProcessor()
+	imageData, contentType, err := processor.NormalizeGenerated(generated, bounds.Dx(), bounds.Dy(), source.contentType)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	log.Printf("INFO: Normalized generated image %s -> %s (%dx%d)", result.MIMEType, contentType, bounds.Dx(), bounds.Dy())
+
+	normalized, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to decode normalized image: %w", err)
+	}
+	return imageData, contentType, normalized, nil
+}
+
 func writeJSON(w http.ResponseWriter, status int, payload any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -399,6 +830,15 @@ func buildImageProxyURL(baseURL string, objectName string) (string, error) {
 	return fmt.Sprintf("%s/photo/image?object=%s", strings.TrimRight(baseURL, "/"), escaped), nil
 }
 
+// objectNameFromProxyURL extracts the storage object name from a URL built by buildImageProxyURL.
+func objectNameFromProxyURL(proxyURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(proxyURL))
+	if err != nil {
+		return ""
+	}
+	return parsed.Query().Get("object")
+}
+
 // StateUpdater is an optional interface for session services that support direct state updates.
 type StateUpdater interface {
 	UpdateState(ctx context.Context, appName, userID, sessionID string, updates map[string]any) error
@@ -423,15 +863,18 @@ func seedAnalysisEvents(ctx context.Context, sessionService session.Service, use
 	//    accessible to the Gemini API via API key)
 	userEvent := session.NewEvent(invocationID)
 	userEvent.Author = "user"
-	userEvent.Content = genai.NewContentFromText("この写真を分析して改善点を教えてください", genai.RoleUser)
+	settings := locale.FromContext(ctx)
+	userEvent.Content = genai.NewContentFromText(settings.T("seed.request"), genai.RoleUser)
 
 	if err := sessionService.AppendEvent(ctx, sess, userEvent); err != nil {
 		return fmt.Errorf("failed to append user event: %w", err)
 	}
 
 	// 2. Model event: analysis summary
-	summary := fmt.Sprintf("写真を分析しました。\n\n**%s**\n総合スコア: %d/10\n\n%s",
-		analysis.PhotoSummary, analysis.OverallScore, analysis.Summary)
+	summary := settings.T("seed.summary", analysis.PhotoSummary, analysis.OverallScore, analysis.MaxScore(), analysis.Summary)
+	if unstable := analysis.UnstableScores(settings.Locale); len(unstable) > 0 {
+		summary += "\n\n" + settings.T("ensemble.unstable", strings.Join(unstable, " / "))
+	}
 	// Fix markdown bold formatting (** text ** -> **text**)
 	summary = fixMarkdownBold(summary)
 
@@ -451,14 +894,15 @@ func updateSessionState(ctx context.Context, sessionService session.Service, use
 	// Try to use direct state update if the session service supports it
 	if updater, ok := sessionService.(StateUpdater); ok {
 		if err := updater.UpdateState(ctx, "photo_levelup", userID, sessionID, updates); err != nil {
-			log.Printf("WARN: Direct state update failed, falling back to memory-only update: %v", err)
+			log.Printf("WARN: Direct state update failed, falling back to a state-delta event: %v", err)
 		} else {
 			log.Printf("INFO: Session state updated and persisted for session %s", sessionID)
 			return nil
 		}
 	}
 
-	// Fallback: update state in memory (may not persist for all session service types)
+	// Fallback: record the updates as a state-delta event, which both the
+	// in-memory and the Firestore session services apply to the stored state.
 	response, err := sessionService.Get(ctx, &session.GetRequest{
 		AppName:   "photo_levelup",
 		UserID:    userID,
@@ -468,13 +912,13 @@ func updateSessionState(ctx context.Context, sessionService session.Service, use
 		return err
 	}
 
-	state := response.Session.State()
-	for key, value := range updates {
-		if err := state.Set(key, value); err != nil {
-			log.Printf("WARN: Failed to set state key %s: %v", key, err)
-		}
+	event := session.NewEvent(uuid.New().String())
+	event.Author = "photo_coach"
+	event.Actions.StateDelta = updates
+	if err := sessionService.AppendEvent(ctx, response.Session, event); err != nil {
+		return fmt.Errorf("failed to append state delta event: %w", err)
 	}
 
-	log.Printf("WARN: Session state updated in memory only for session %s (may not persist)", sessionID)
+	log.Printf("INFO: Session state updated via state delta for session %s", sessionID)
 	return nil
 }
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"google.golang.org/adk/session"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/purpose"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/usage"
+)
+
+func testPhoto(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	for y := 0; y < 240; y++ {
+		for x := 0; x < 320; x++ {
+			v := uint8((x/16 + y/16) % 2 * 160)
+			img.SetRGBA(x, y, color.RGBA{v + 40, uint8(x * 255 / 320), uint8(y * 255 / 240), 255})
+		}
+	}
+	buffer := &bytes.Buffer{}
+	if err := jpeg.Encode(buffer, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestProcessAnalysisWithFakeProvider(t *testing.T) {
+	store := services.NewMemoryObjectStore()
+	deps := NewDependencies(nil, session.InMemoryService(), services.NewFakeModelProvider(store), store)
+	handler := NewAnalyzeHandler(deps)
+
+	jobID := "test-process-analysis"
+	GetJobStore().Create(jobID)
+	handler.processAnalysis(jobID, "user-1", "frontend-1", testPhoto(t), "image/jpeg", "http://backend.test", analysisOptions{Upscale: true})
+
+	job, ok := GetJobStore().Get(jobID)
+	if !ok {
+		t.Fatal("job not found")
+	}
+	if job.Status != JobStatusCompleted {
+		t.Fatalf("status = %s (error %q), want completed", job.Status, job.Error)
+	}
+
+	result := job.Result
+	if result.Analysis.OverallScore != services.FakeAnalysisResult().OverallScore {
+		t.Errorf("overall score = %d", result.Analysis.OverallScore)
+	}
+	for name, url := range map[string]string{
+		"enhanced":       result.EnhancedImageURL,
+		"clean enhanced": result.CleanEnhancedImageURL,
+		"heatmap":        result.HeatmapImageURL,
+		"upscaled":       result.UpscaledImageURL,
+	} {
+		if !strings.HasPrefix(url, "http://backend.test/photo/image?object=") {
+			t.Errorf("%s url = %q", name, url)
+		}
+	}
+	if result.Models[services.StepAnalysis] != services.FakeModelName || result.Models[services.StepCleanEnhancement] != services.FakeModelName {
+		t.Errorf("models = %v", result.Models)
+	}
+	if result.Fidelity == nil || !result.Fidelity.Passed {
+		t.Errorf("fidelity = %+v, want passed", result.Fidelity)
+	}
+
+	ctx := context.Background()
+	sessionID, err := resolveSessionID(ctx, deps.SessionService, "photo_levelup", "user-1", "frontend-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := deps.SessionService.Get(ctx, &session.GetRequest{AppName: "photo_levelup", UserID: "user-1", SessionID: sessionID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := response.Session.State()
+	var stored services.AnalysisResult
+	if err := json.Unmarshal([]byte(stateString(state, "analysis_result")), &stored); err != nil {
+		t.Fatalf("analysis_result state: %v", err)
+	}
+	if !strings.Contains(stateString(state, "models"), services.FakeModelName) {
+		t.Errorf("models state = %q", stateString(state, "models"))
+	}
+	if stateString(state, "prompt_version") == "" {
+		t.Error("prompt_version was not recorded")
+	}
+	if original := stateString(state, "full_resolution_object"); !strings.HasPrefix(original, "originals/") || isSafeObjectName(original) {
+		t.Errorf("full_resolution_object = %q, want a stored original the proxy refuses", original)
+	}
+	if stateString(state, "purpose") != string(purpose.Default) {
+		t.Errorf("purpose state = %q, want the default", stateString(state, "purpose"))
+	}
+	if stateString(state, "genre") != string(services.FakeGenre) || stateString(state, "rubric_id") != rubric.Default().ForGenre(services.FakeGenre).ID {
+		t.Errorf("genre state = %q, rubric_id = %q", stateString(state, "genre"), stateString(state, "rubric_id"))
+	}
+	sessionUsage, err := services.ParseUsageReport(stateString(state, "usage"))
+	if err != nil || sessionUsage.ByStep[services.StepAnalysis].Calls != 1 {
+		t.Errorf("usage state = %q (%v)", stateString(state, "usage"), err)
+	}
+	ledger, err := deps.Usage.Get(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ledger.ByActivity[usage.ActivityAnalysis].Count != 1 || ledger.Total.Calls != sessionUsage.Total.Calls {
+		t.Errorf("ledger = %+v, want one analysis with %d calls", ledger, sessionUsage.Total.Calls)
+	}
+	if stateString(state, "clean_enhanced_image_url") != result.CleanEnhancedImageURL {
+		t.Errorf("clean_enhanced_image_url state = %q", stateString(state, "clean_enhanced_image_url"))
+	}
+	if _, _, err := loadStoredImage(ctx, store, objectNameFromProxyURL(result.CleanEnhancedImageURL)); err != nil {
+		t.Errorf("clean enhancement not stored: %v", err)
+	}
+}
+
+func TestProcessAnalysisGenreOverride(t *testing.T) {
+	store := services.NewMemoryObjectStore()
+	deps := NewDependencies(nil, session.InMemoryService(), services.NewFakeModelProvider(store), store)
+	handler := NewAnalyzeHandler(deps)
+
+	jobID := "test-genre-override"
+	GetJobStore().Create(jobID)
+	handler.processAnalysis(jobID, "user-1", "frontend-genre", testPhoto(t), "image/jpeg", "http://backend.test", analysisOptions{Genre: rubric.Portrait})
+
+	job, _ := GetJobStore().Get(jobID)
+	if job.Status != JobStatusCompleted {
+		t.Fatalf("status = %s (error %q), want completed", job.Status, job.Error)
+	}
+	analysis := job.Result.Analysis
+	if analysis.Genre != string(rubric.Portrait) || analysis.GenreDetected || analysis.RubricID != rubric.Default().ForGenre(rubric.Portrait).ID {
+		t.Errorf("genre = %q detected=%v rubric=%q", analysis.Genre, analysis.GenreDetected, analysis.RubricID)
+	}
+	if _, ok := analysis.Scores["eyeSharpness"]; !ok {
+		t.Errorf("scores = %v, want portrait criteria", analysis.Scores)
+	}
+	if _, classified := job.Result.Models[services.StepGenre]; classified {
+		t.Error("genre was classified despite the override")
+	}
+	for _, name := range store.ObjectNames() {
+		if strings.HasPrefix(name, "originals/") {
+			t.Errorf("stored original %s without an upscale request", name)
+		}
+	}
+}
+
+func TestProcessAnalysisBrief(t *testing.T) {
+	store := services.NewMemoryObjectStore()
+	deps := NewDependencies(nil, session.InMemoryService(), services.NewFakeModelProvider(store), store)
+	handler := NewAnalyzeHandler(deps)
+
+	jobID := "test-brief"
+	GetJobStore().Create(jobID)
+	brief := "テーマ: 水辺の光。縦横比は4:3または3:2に限ります。長辺3000px以上。"
+	handler.processAnalysis(jobID, "user-1", "frontend-brief", testPhoto(t), "image/jpeg", "http://backend.test", analysisOptions{Brief: brief})
+
+	job, _ := GetJobStore().Get(jobID)
+	if job.Status != JobStatusCompleted {
+		t.Fatalf("status = %s (error %q), want completed", job.Status, job.Error)
+	}
+	theme := job.Result.Analysis.Theme
+	if theme == nil || theme.Brief != brief || theme.Comment == "" {
+		t.Fatalf("theme = %+v", theme)
+	}
+	// The 320×240 upload is 4:3 but far below 3000px.
+	if len(theme.Violations) != 1 || theme.Violations[0].Kind != services.RuleResolution || !theme.Violations[0].Verified {
+		t.Errorf("violations = %+v, want one verified resolution violation", theme.Violations)
+	}
+	if len(theme.LocalChecks) != 2 {
+		t.Errorf("local checks = %+v, want aspect ratio and resolution", theme.LocalChecks)
+	}
+}
+
+func TestParseAnalysisOptions(t *testing.T) {
+	tests := []struct {
+		form    string
+		want    analysisOptions
+		wantErr bool
+	}{
+		{form: "", want: analysisOptions{}},
+		{form: "upscale=true&genre=Portrait&samples=3", want: analysisOptions{Upscale: true, Genre: rubric.Portrait, Samples: 3}},
+		{form: "rubric=general-v1", want: analysisOptions{RubricID: "general-v1"}},
+		{form: "purpose=Print", want: analysisOptions{Purpose: purpose.Print}},
+		{form: "purpose=billboard", wantErr: true},
+		{form: "brief=" + url.QueryEscape(" テーマ: 水辺の光 "), want: analysisOptions{Brief: "テーマ: 水辺の光"}},
+		{form: "brief=" + strings.Repeat("光", services.MaxBriefLength+1), wantErr: true},
+		{form: "genre=food", wantErr: true},
+		{form: "rubric=missing", wantErr: true},
+		{form: "samples=0", wantErr: true},
+		{form: "samples=99", wantErr: true},
+	}
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodPost, "/photo/analyze?"+tt.form, nil)
+		got, err := parseAnalysisOptions(request)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v", tt.form, err)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%q: options = %+v, want %+v", tt.form, got, tt.want)
+		}
+	}
+}
@@ -13,6 +13,13 @@ import (
 	"google.golang.org/adk/runner"
 	"google.golang.org/adk/session"
 	"google.golang.org/genai"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/experiments"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/quota"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/skill"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/usage"
 )
 
 type chatRequest struct {
@@ -20,6 +27,28 @@ type chatRequest struct {
 	UserID    string `json:"userId"`
 	Message   string `json:"message"`
 	ImageURL  string `json:"imageUrl,omitempty"`
+	// Region optionally narrows the question to an area of the session photo.
+	Region *services.NormalizedBox `json:"region,omitempty"`
+	// Locale and TimeZone override the user's saved preferences and replace them.
+	Locale   string `json:"locale,omitempty"`
+	TimeZone string `json:"timeZone,omitempty"`
+	// SkillLevel overrides the user's saved or inferred level and replaces it.
+	SkillLevel string `json:"skillLevel,omitempty"`
+}
+
+// regionReferencePrefix marks the content part that recorded the selected
+// area in sessions created before references moved to session state.
+const regionReferencePrefix = "[selected-region] "
+
+// regionReferencesKey is the session state key of the region references,
+// a JSON object keyed by the invocation ID of the question.
+const regionReferencesKey = "region_references"
+
+// regionReference records which area of the photo a question was about, so
+// the selection can be redrawn from history.
+type regionReference struct {
+	Box          services.NormalizedBox `json:"box"`
+	CropImageURL string                 `json:"cropImageUrl,omitempty"`
 }
 
 type chatResponse struct {
@@ -55,9 +84,32 @@ func (h *ChatHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if req.UserID == "" {
 		req.UserID = "anonymous"
 	}
+	if req.Region != nil {
+		if err := req.Region.Validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid region")
+			return
+		}
+	}
 
 	ctx := r.Context()
-	reply, err := chatWithAgent(ctx, h.deps, req.UserID, req.SessionID, req.Message, req.ImageURL)
+	settings, err := resolveSettings(ctx, h.deps, r, req.UserID, req.Locale, req.TimeZone)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx = locale.WithSettings(ctx, settings)
+	level, err := resolveSkillLevel(ctx, h.deps, req.UserID, req.SkillLevel)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if level != "" {
+		ctx = skill.WithLevel(ctx, level)
+	}
+	if !reserveQuota(w, ctx, h.deps, req.UserID, quota.ActionChat) {
+		return
+	}
+	reply, err := chatWithAgent(ctx, h.deps, req.UserID, req.SessionID, req.Message, req.ImageURL, req.Region, resolveBaseURL(r))
 	if err != nil {
 		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -66,7 +118,15 @@ func (h *ChatHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, chatResponse{Reply: reply})
 }
 
-func chatWithAgent(ctx context.Context, deps *Dependencies, userID, sessionID, message, imageURL string) (string, error) {
+func chatWithAgent(ctx context.Context, deps *Dependencies, userID, sessionID, message, imageURL string, region *services.NormalizedBox, baseURL string) (string, error) {
+	// The reserved chat turn is returned when the turn fails before the model runs.
+	runStarted := false
+	defer func() {
+		if !runStarted {
+			deps.Quota.Release(context.WithoutCancel(ctx), userID, quota.ActionChat)
+		}
+	}()
+
 	runner, err := runner.New(runner.Config{
 		AppName:        "photo_levelup",
 		Agent:          deps.Agent,
@@ -83,31 +143,58 @@ func chatWithAgent(ctx context.Context, deps *Dependencies, userID, sessionID, m
 
 	// Enrich message with analysis context from session state so the agent
 	// can answer follow-up questions about the analyzed photo.
+	settings := locale.FromContext(ctx)
 	enrichedMessage := message
+	var sessionState session.State
 	if sessResp, err := deps.SessionService.Get(ctx, &session.GetRequest{
 		AppName:   "photo_levelup",
 		UserID:    userID,
 		SessionID: resolvedSessionID,
 	}); err == nil {
 		state := sessResp.Session.State()
+		sessionState = state
 		analysisJSON, analysisErr := state.Get("analysis_result")
 		if analysisErr == nil {
 			var contextLines []string
 			if title, err := state.Get("title"); err == nil {
-				contextLines = append(contextLines, fmt.Sprintf("写真タイトル: %v", title))
+				contextLines = append(contextLines, settings.T("chat.title", title))
 			}
 			if score, err := state.Get("overall_score"); err == nil {
-				contextLines = append(contextLines, fmt.Sprintf("総合スコア: %v/10", score))
+				maxScore, err := state.Get("score_max")
+				if err != nil {
+					maxScore = 10
+				}
+				contextLines = append(contextLines, settings.T("chat.score", score, maxScore))
 			}
-			contextLines = append(contextLines, fmt.Sprintf("分析結果JSON: %v", analysisJSON))
-			enrichedMessage = fmt.Sprintf("[この写真セッションの分析コンテキスト]\n%s\n\n[ユーザーの質問]\n%s",
-				strings.Join(contextLines, "\n"), message)
+			contextLines = append(contextLines, settings.T("chat.analysisJSON", analysisJSON))
+			enrichedMessage = settings.T("chat.context", strings.Join(contextLines, "\n"), message)
 			log.Printf("INFO: Enriched chat message with analysis context for session %s", resolvedSessionID)
 		}
 	}
 
-	var content *genai.Content
-	if imageURL != "" {
+	// Keep the prompt variants the session's analysis was produced with.
+	assignments := experiments.ParseAssignments(stateString(sessionState, "experiments"))
+	sessionAssigned := len(assignments) > 0
+	if !sessionAssigned {
+		assignments = deps.Experiments.Assign(userID)
+	}
+	ctx = deps.Experiments.WithPrompts(ctx, assignments)
+
+	var (
+		content   *genai.Content
+		reference *regionReference
+	)
+	if region != nil {
+		if sessionState == nil {
+			return "", errors.New("session photo is not available for region questions")
+		}
+		parts, built, err := buildRegionParts(ctx, deps.Storage, sessionState, enrichedMessage, *region, baseURL)
+		if err != nil {
+			return "", err
+		}
+		content = genai.NewContentFromParts(parts, genai.RoleUser)
+		reference = &built
+	} else if imageURL != "" {
 		// Include image with message
 		parts := []*genai.Part{
 			genai.NewPartFromText(enrichedMessage),
@@ -120,6 +207,14 @@ func chatWithAgent(ctx context.Context, deps *Dependencies, userID, sessionID, m
 
 	log.Printf("INFO: Starting runner.Run for session %s, user message: %.100s", resolvedSessionID, message)
 
+	ctx, trace := services.WithCallTrace(ctx)
+	runStarted = true
+	defer func() {
+		report := trace.Usage()
+		recordSessionUsage(context.WithoutCancel(ctx), deps, userID, resolvedSessionID, usage.ActivityChat, report, trace.Models())
+		deps.Quota.Settle(context.WithoutCancel(ctx), userID, quota.ActionChat, report)
+	}()
+
 	// Track events seen during run
 	eventCount := 0
 	for event, err := range runner.Run(ctx, userID, resolvedSessionID, content, agent.RunConfig{}) {
@@ -127,6 +222,13 @@ func chatWithAgent(ctx context.Context, deps *Dependencies, userID, sessionID, m
 			return "", err
 		}
 		eventCount++
+		if reference != nil && event != nil && event.InvocationID != "" {
+			recordRegionReference(ctx, deps, userID, resolvedSessionID, event.InvocationID, *reference)
+			reference = nil
+		}
+		if event != nil && event.UsageMetadata != nil && !event.Partial {
+			recordAgentUsage(trace, event.UsageMetadata)
+		}
 		if event == nil || !event.IsFinalResponse() {
 			continue
 		}
@@ -136,6 +238,11 @@ func chatWithAgent(ctx context.Context, deps *Dependencies, userID, sessionID, m
 			text = fixMarkdownBold(text)
 			log.Printf("INFO: Completed runner.Run for session %s, saw %d events, returning response: %.100s",
 				resolvedSessionID, eventCount, text)
+			if sessionAssigned {
+				if err := deps.Experiments.Record(ctx, assignments, experiments.Counts{ChatFollowUps: 1}); err != nil {
+					log.Printf("WARN: Failed to record chat follow-up for session %s: %v", resolvedSessionID, err)
+				}
+			}
 			return text, nil
 		}
 	}
@@ -144,6 +251,110 @@ func chatWithAgent(ctx context.Context, deps *Dependencies, userID, sessionID, m
 	return "", errors.New("chat response missing")
 }
 
+// buildRegionParts crops the selected region from the session photo at the
+// best stored resolution and returns the message parts: the question with a
+// description of the area, the whole frame and the crop, both inline since
+// the Gemini API cannot read gs:// objects. The crop is also stored so the
+// returned reference, persisted outside the model content, can show it.
+func buildRegionParts(ctx context.Context, storageClient services.ObjectStore, state session.State, message string, region services.NormalizedBox, baseURL string) ([]*genai.Part, regionReference, error) {
+	reference := regionReference{Box: region}
+	frameObject := objectNameFromProxyURL(stateString(state, "original_image_url"))
+	if frameObject == "" {
+		return nil, reference, errors.New("session photo is not available for region questions")
+	}
+	cropSource := stateString(state, "full_resolution_object")
+	if cropSource == "" {
+		cropSource = frameObject
+	}
+
+	if storageClient == nil {
+		return nil, reference, errors.New("storage client error: no object store configured")
+	}
+
+	frame, _, err := loadStoredImage(ctx, storageClient, frameObject)
+	if err != nil {
+		return nil, reference, err
+	}
+	fullResolution := frame
+	if cropSource != frameObject {
+		if loaded, _, err := loadStoredImage(ctx, storageClient, cropSource); err == nil {
+			fullResolution = loaded
+		} else {
+			log.Printf("WARN: Falling back to resized photo for region crop: %v", err)
+		}
+	}
+
+	crop, err := services.CropNormalized(fullResolution, region)
+	if err != nil {
+		return nil, reference, err
+	}
+	processor := services.NewImageProcessor()
+	frameData, frameType, err := processor.EncodeWithMaxEdge(frame, "image/jpeg")
+	if err != nil {
+		return nil, reference, err
+	}
+	cropData, cropType, err := processor.EncodeWithMaxEdge(crop, "image/jpeg")
+	if err != nil {
+		return nil, reference, err
+	}
+	if _, cropObject, err := storageClient.UploadImageWithPrefix(ctx, cropData, cropType, "crops"); err != nil {
+		log.Printf("WARN: Failed to store region crop: %v", err)
+	} else if proxyURL, err := buildImageProxyURL(baseURL, cropObject); err == nil {
+		reference.CropImageURL = proxyURL
+	}
+
+	// Without an original upload the crop is an enlargement of the resized
+	// copy, and the note must not present it as a detail view.
+	noteKey := "chat.region"
+	if fullResolution == frame {
+		noteKey = "chat.regionResized"
+	}
+	regionNote := locale.FromContext(ctx).T(noteKey,
+		region.X*100, region.Y*100, region.Width*100, region.Height*100)
+
+	return []*genai.Part{
+		genai.NewPartFromText(message + "\n\n" + regionNote),
+		genai.NewPartFromBytes(frameData, frameType),
+		genai.NewPartFromBytes(cropData, cropType),
+	}, reference, nil
+}
+
+// recordRegionReference stores the region of a question under its
+// invocation ID so the session history can redraw the selection.
+func recordRegionReference(ctx context.Context, deps *Dependencies, userID, sessionID, invocationID string, reference regionReference) {
+	response, err := deps.SessionService.Get(ctx, &session.GetRequest{
+		AppName:   "photo_levelup",
+		UserID:    userID,
+		SessionID: sessionID,
+	})
+	if err != nil {
+		log.Printf("WARN: Failed to load session %s for region reference: %v", sessionID, err)
+		return
+	}
+	references := parseRegionReferences(response.Session.State())
+	references[invocationID] = reference
+	encoded, err := json.Marshal(references)
+	if err != nil {
+		log.Printf("WARN: Failed to encode region references for session %s: %v", sessionID, err)
+		return
+	}
+	if err := updateSessionState(ctx, deps.SessionService, userID, sessionID, map[string]any{regionReferencesKey: string(encoded)}); err != nil {
+		log.Printf("WARN: Failed to store region reference for session %s: %v", sessionID, err)
+	}
+}
+
+// parseRegionReferences reads the stored region references, keyed by invocation ID.
+func parseRegionReferences(state session.State) map[string]regionReference {
+	references := map[string]regionReference{}
+	if raw := stateString(state, regionReferencesKey); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &references); err != nil {
+			log.Printf("WARN: Ignoring unreadable region references: %v", err)
+			return map[string]regionReference{}
+		}
+	}
+	return references
+}
+
 // resolveSessionID finds or creates an ADK session for the given user and frontend sessionId.
 // The sessionId parameter is used to map frontend sessions to ADK sessions.
 // When a specific sessionId is provided (not "default"), we look for an existing ADK session
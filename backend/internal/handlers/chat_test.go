@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/adk/agent/llmagent"
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+)
+
+// stubChatModel answers every turn with a fixed reply and records itself as
+// the serving chat model, like the fallback model does.
+type stubChatModel struct {
+	name string
+	// last is the most recent request the model received.
+	last *model.LLMRequest
+}
+
+func (s *stubChatModel) Name() string { return s.name }
+
+func (s *stubChatModel) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	s.last = req
+	return func(yield func(*model.LLMResponse, error) bool) {
+		services.CallTraceFrom(ctx).RecordModel(services.StepChat, s.name)
+		yield(&model.LLMResponse{
+			Content:       genai.NewContentFromText("背景を整理しましょう。", genai.RoleModel),
+			UsageMetadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 100, CandidatesTokenCount: 20},
+			TurnComplete:  true,
+		}, nil)
+	}
+}
+
+func TestChatRecordsServingModel(t *testing.T) {
+	ctx := context.Background()
+	coach, err := llmagent.New(llmagent.Config{Name: "photo_coach", Model: &stubChatModel{name: "chat-fallback"}, Instruction: "coach"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps := NewDependencies(coach, session.InMemoryService(), services.NewFakeModelProvider(nil), nil)
+	sessionID, err := resolveSessionID(ctx, deps.SessionService, "photo_levelup", "user-1", "frontend-chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysisModels := map[string]any{"models": `{"analysis":"` + services.FakeModelName + `"}`}
+	if err := updateSessionState(ctx, deps.SessionService, "user-1", sessionID, analysisModels); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"sessionId":"frontend-chat","userId":"user-1","message":"背景はどうですか?"}`
+	recorder := httptest.NewRecorder()
+	NewChatHandler(deps).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/photo/chat", strings.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	response, err := deps.SessionService.Get(ctx, &session.GetRequest{AppName: "photo_levelup", UserID: "user-1", SessionID: sessionID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var models map[string]string
+	if err := json.Unmarshal([]byte(stateString(response.Session.State(), "models")), &models); err != nil {
+		t.Fatalf("models state: %v", err)
+	}
+	if models[services.StepChat] != "chat-fallback" || models[services.StepAnalysis] != services.FakeModelName {
+		t.Errorf("models state = %v, want the chat model next to the analysis model", models)
+	}
+}
+
+func TestChatRegionQuestionSendsInlineImages(t *testing.T) {
+	ctx := context.Background()
+	stub := &stubChatModel{name: "chat-fallback"}
+	coach, err := llmagent.New(llmagent.Config{Name: "photo_coach", Model: stub, Instruction: "coach"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := services.NewMemoryObjectStore()
+	deps := NewDependencies(coach, session.InMemoryService(), services.NewFakeModelProvider(store), store)
+	sessionID, err := resolveSessionID(ctx, deps.SessionService, "photo_levelup", "user-1", "frontend-region")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := regionSessionState(t, store, false)
+	if err := updateSessionState(ctx, deps.SessionService, "user-1", sessionID, map[string]any{"original_image_url": stateString(state, "original_image_url")}); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"sessionId":"frontend-region","userId":"user-1","message":"ここは暗すぎますか?","region":{"x":0.5,"y":0.5,"width":0.25,"height":0.25}}`
+	recorder := httptest.NewRecorder()
+	NewChatHandler(deps).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/photo/chat", strings.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	if stub.last == nil || len(stub.last.Contents) == 0 {
+		t.Fatal("the model received no request")
+	}
+	question := stub.last.Contents[len(stub.last.Contents)-1]
+	var images int
+	for _, part := range question.Parts {
+		if part.FileData != nil {
+			t.Errorf("part references %q, which the Gemini API cannot read", part.FileData.FileURI)
+		}
+		if part.InlineData != nil && strings.HasPrefix(part.InlineData.MIMEType, "image/") {
+			images++
+		}
+	}
+	if images != 2 {
+		t.Errorf("question parts = %+v, want the frame and the crop inline", question.Parts)
+	}
+}
+
+// regionSessionState stores a frame (and optionally a full-resolution
+// original) and returns the session state an analysis would have left.
+func regionSessionState(t *testing.T, store *services.MemoryObjectStore, withOriginal bool) session.State {
+	t.Helper()
+	ctx := context.Background()
+	encode := func(width, height int) []byte {
+		buffer := &bytes.Buffer{}
+		if err := jpeg.Encode(buffer, image.NewGray(image.Rect(0, 0, width, height)), nil); err != nil {
+			t.Fatal(err)
+		}
+		return buffer.Bytes()
+	}
+	_, frameObject, err := store.UploadImageWithPrefix(ctx, encode(320, 240), "image/jpeg", "uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frameURL, _ := buildImageProxyURL("http://backend.test", frameObject)
+	state := map[string]any{"original_image_url": frameURL}
+	if withOriginal {
+		_, originalObject, err := store.UploadImageWithPrefix(ctx, encode(1280, 960), "image/jpeg", "originals")
+		if err != nil {
+			t.Fatal(err)
+		}
+		state["full_resolution_object"] = originalObject
+	}
+	sessions := session.InMemoryService()
+	created, err := sessions.Create(ctx, &session.CreateRequest{AppName: "photo_levelup", UserID: "user-1", State: state})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created.Session.State()
+}
+
+// inlineBounds decodes the image bytes of part.
+func inlineBounds(t *testing.T, part *genai.Part) image.Rectangle {
+	t.Helper()
+	if part.InlineData == nil {
+		t.Fatalf("part = %+v, want inline image bytes", part)
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(part.InlineData.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded.Bounds()
+}
+
+func TestBuildRegionParts(t *testing.T) {
+	ctx := context.Background()
+	region := services.NormalizedBox{X: 0.5, Y: 0.5, Width: 0.25, Height: 0.25}
+	tests := []struct {
+		name         string
+		withOriginal bool
+		wantCrop     image.Rectangle
+		wantNote     string
+	}{
+		{name: "full-resolution crop", withOriginal: true, wantCrop: image.Rect(0, 0, 320, 240), wantNote: "元解像度で切り出した"},
+		{name: "resized crop", wantCrop: image.Rect(0, 0, 80, 60), wantNote: "元解像度ではありません"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := services.NewMemoryObjectStore()
+			state := regionSessionState(t, store, tt.withOriginal)
+			parts, reference, err := buildRegionParts(ctx, store, state, "ここは暗すぎますか?", region, "http://backend.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(parts) != 3 || !strings.HasPrefix(parts[0].Text, "ここは暗すぎますか?") || !strings.Contains(parts[0].Text, tt.wantNote) {
+				t.Fatalf("parts = %+v, want the question with %q and two images", parts, tt.wantNote)
+			}
+			if strings.Contains(parts[0].Text, regionReferencePrefix) || strings.Contains(parts[0].Text, "cropImageUrl") {
+				t.Errorf("region metadata leaked into the model content: %q", parts[0].Text)
+			}
+			if frame := inlineBounds(t, parts[1]); frame != image.Rect(0, 0, 320, 240) {
+				t.Errorf("frame bounds = %v, want the session photo", frame)
+			}
+			if crop := inlineBounds(t, parts[2]); crop != tt.wantCrop {
+				t.Errorf("crop bounds = %v, want %v", crop, tt.wantCrop)
+			}
+
+			if reference.Box != region || !strings.HasPrefix(reference.CropImageURL, "http://backend.test/photo/image?object=crops") {
+				t.Errorf("reference = %+v", reference)
+			}
+			stored, _, err := loadStoredImage(ctx, store, objectNameFromProxyURL(reference.CropImageURL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Bounds() != tt.wantCrop {
+				t.Errorf("stored crop bounds = %v, want %v", stored.Bounds(), tt.wantCrop)
+			}
+		})
+	}
+
+	created, err := session.InMemoryService().Create(ctx, &session.CreateRequest{AppName: "photo_levelup", UserID: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := buildRegionParts(ctx, services.NewMemoryObjectStore(), created.Session.State(), "?", region, ""); err == nil {
+		t.Error("region question without a session photo succeeded")
+	}
+}
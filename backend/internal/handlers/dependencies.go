@@ -3,16 +3,42 @@ package handlers
 import (
 	"google.golang.org/adk/agent"
 	"google.golang.org/adk/session"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/experiments"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/profile"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/quota"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/usage"
 )
 
 type Dependencies struct {
 	Agent          agent.Agent
 	SessionService session.Service
+	Analyzer       services.Analyzer
+	Enhancer       services.Enhancer
+	// Storage is nil when no object store could be initialized; handlers
+	// that need it report a storage client error.
+	Storage services.ObjectStore
+	// Usage is the per-user usage and cost ledger.
+	Usage usage.Ledger
+	// Quota enforces the per-user daily limits.
+	Quota *quota.Enforcer
+	// Experiments assigns prompt variants and aggregates their outcomes.
+	Experiments *experiments.Manager
+	// Profiles stores per-user preferences such as the output locale.
+	Profiles profile.Store
 }
 
-func NewDependencies(agent agent.Agent, sessionService session.Service) *Dependencies {
+func NewDependencies(agent agent.Agent, sessionService session.Service, models services.ModelProvider, storage services.ObjectStore) *Dependencies {
 	return &Dependencies{
 		Agent:          agent,
 		SessionService: sessionService,
+		Analyzer:       models,
+		Enhancer:       models,
+		Storage:        storage,
+		Usage:          usage.NewMemoryLedger(),
+		Quota:          quota.NewEnforcer(quota.NewMemoryStore(), quota.LimitsFromEnv()),
+		Experiments:    experiments.NewManager(nil, experiments.NewMemoryOutcomeStore()),
+		Profiles:       profile.NewMemoryStore(),
 	}
 }
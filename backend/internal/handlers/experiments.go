@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/adk/session"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/experiments"
+)
+
+// FeedbackHandler stores a user's rating of a session's analysis and credits
+// it to the session's experiment variants.
+type FeedbackHandler struct {
+	deps *Dependencies
+}
+
+// NewFeedbackHandler creates a new feedback handler.
+func NewFeedbackHandler(deps *Dependencies) *FeedbackHandler {
+	return &FeedbackHandler{deps: deps}
+}
+
+type feedbackRequest struct {
+	UserID    string `json:"userId"`
+	SessionID string `json:"sessionId"`
+	// Rating is 1 (not helpful) to 5 (very helpful).
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type sessionFeedback struct {
+	Rating    int       `json:"rating"`
+	Comment   string    `json:"comment,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ServeHTTP handles POST /photo/feedback
+func (h *FeedbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req feedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if req.UserID == "" || req.SessionID == "" {
+		writeJSONError(w, http.StatusBadRequest, "userId and sessionId are required")
+		return
+	}
+	if req.Rating < 1 || req.Rating > 5 {
+		writeJSONError(w, http.StatusBadRequest, "rating must be between 1 and 5")
+		return
+	}
+
+	ctx := r.Context()
+	getResponse, err := h.deps.SessionService.Get(ctx, &session.GetRequest{
+		AppName:   "photo_levelup",
+		UserID:    req.UserID,
+		SessionID: req.SessionID,
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	state := getResponse.Session.State()
+
+	// A changed rating replaces the previous one instead of counting twice.
+	counts := experiments.Counts{FeedbackCount: 1, FeedbackSum: float64(req.Rating)}
+	var previous sessionFeedback
+	if raw := stateString(state, "feedback"); raw != "" && json.Unmarshal([]byte(raw), &previous) == nil && previous.Rating > 0 {
+		counts = experiments.Counts{FeedbackSum: float64(req.Rating - previous.Rating)}
+	}
+
+	feedback := sessionFeedback{Rating: req.Rating, Comment: strings.TrimSpace(req.Comment), UpdatedAt: time.Now()}
+	feedbackJSON, err := json.Marshal(feedback)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to store feedback")
+		return
+	}
+	if err := updateSessionState(ctx, h.deps.SessionService, req.UserID, req.SessionID, map[string]any{"feedback": string(feedbackJSON)}); err != nil {
+		log.Printf("ERROR: FeedbackHandler failed to store feedback for session %s: %v", req.SessionID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to store feedback")
+		return
+	}
+
+	assignments := experiments.ParseAssignments(stateString(state, "experiments"))
+	if err := h.deps.Experiments.Record(ctx, assignments, counts); err != nil {
+		log.Printf("WARN: Failed to record feedback outcome for session %s: %v", req.SessionID, err)
+	}
+
+	writeJSON(w, http.StatusOK, feedback)
+}
+
+// ExperimentsHandler reports outcomes per variant of every prompt
+// experiment. It is an admin endpoint; see authorizeAdmin.
+type ExperimentsHandler struct {
+	deps *Dependencies
+}
+
+// NewExperimentsHandler creates a new experiments report handler.
+func NewExperimentsHandler(deps *Dependencies) *ExperimentsHandler {
+	return &ExperimentsHandler{deps: deps}
+}
+
+// ServeHTTP handles GET /photo/experiments
+func (h *ExperimentsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	reports, err := h.deps.Experiments.Reports(r.Context())
+	if err != nil {
+		log.Printf("ERROR: ExperimentsHandler failed to aggregate outcomes: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read experiments")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"experiments": reports})
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
+	"image"
 	"io"
 	"log"
 	"net/http"
@@ -12,11 +14,13 @@ import (
 )
 
 // ImageHandler streams images stored in GCS through the backend.
-type ImageHandler struct{}
+type ImageHandler struct {
+	deps *Dependencies
+}
 
 // NewImageHandler creates a new image handler.
-func NewImageHandler() *ImageHandler {
-	return &ImageHandler{}
+func NewImageHandler(deps *Dependencies) *ImageHandler {
+	return &ImageHandler{deps: deps}
 }
 
 // ServeHTTP handles GET requests for image proxying.
@@ -37,9 +41,9 @@ func (h *ImageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	storageClient, err := services.NewStorageClient(ctx)
-	if err != nil {
-		log.Printf("ERROR: ImageHandler storage client error: %v", err)
+	storageClient := h.deps.Storage
+	if storageClient == nil {
+		log.Printf("ERROR: ImageHandler storage client error: no object store configured")
 		writeJSONError(w, http.StatusInternalServerError, "storage client error")
 		return
 	}
@@ -71,6 +75,12 @@ func (h *ImageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			prefix = "annotated"
 		} else if strings.HasPrefix(objectName, "clean_enhanced/") {
 			prefix = "enhanced"
+		} else if strings.HasPrefix(objectName, "heatmaps/") {
+			prefix = "heatmap"
+		} else if strings.HasPrefix(objectName, "upscaled/") {
+			prefix = "enhanced_full"
+		} else if strings.HasPrefix(objectName, "crops/") {
+			prefix = "crop"
 		}
 		// Extract filename from object path
 		parts := strings.Split(objectName, "/")
@@ -103,5 +113,24 @@ func isSafeObjectName(objectName string) bool {
 	}
 	return strings.HasPrefix(objectName, "enhanced/") ||
 		strings.HasPrefix(objectName, "clean_enhanced/") ||
+		strings.HasPrefix(objectName, "heatmaps/") ||
+		strings.HasPrefix(objectName, "upscaled/") ||
+		strings.HasPrefix(objectName, "crops/") ||
 		strings.HasPrefix(objectName, "uploads/")
 }
+
+// loadStoredImage reads and decodes an image object from the object store
+// and returns it with its stored content type.
+func loadStoredImage(ctx context.Context, storageClient services.ObjectStore, objectName string) (image.Image, string, error) {
+	reader, contentType, _, err := storageClient.OpenObject(ctx, objectName)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open object %s: %w", objectName, err)
+	}
+	defer reader.Close()
+
+	decoded, _, err := image.Decode(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode object %s: %w", objectName, err)
+	}
+	return decoded, contentType, nil
+}
@@ -4,6 +4,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/experiments"
 	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
 )
 
@@ -24,6 +25,8 @@ type Job struct {
 	Result    *AnalyzeResult
 	Error     string
 	CreatedAt time.Time
+	// Calls tracks model call retries and the last failure class while the job runs.
+	Calls *services.CallTrace
 }
 
 // AnalyzeResult is the response structure for completed jobs
@@ -32,6 +35,20 @@ type AnalyzeResult struct {
 	CleanEnhancedImageURL string                  `json:"cleanEnhancedImageUrl,omitempty"`
 	Analysis              services.AnalysisResult `json:"analysis"`
 	InitialAdvice         string                  `json:"initialAdvice"`
+	// Fidelity reports how faithful the clean enhancement is to the original photo.
+	Fidelity *services.FidelityResult `json:"fidelity,omitempty"`
+	// HeatmapImageURL points at the false-color original/enhanced difference map.
+	HeatmapImageURL string `json:"heatmapImageUrl,omitempty"`
+	// UpscaledImageURL is the clean enhancement at full upload resolution, when requested.
+	UpscaledImageURL string `json:"upscaledImageUrl,omitempty"`
+	// Models records which model served each step after fallbacks.
+	Models map[string]string `json:"models,omitempty"`
+	// CacheHits lists the steps served from the result cache and CacheSavedUSD
+	// what they cost when first computed.
+	CacheHits     []string `json:"cacheHits,omitempty"`
+	CacheSavedUSD float64  `json:"cacheSavedUsd,omitempty"`
+	// Experiments maps each prompt experiment to the variant this job used.
+	Experiments experiments.Assignments `json:"experiments,omitempty"`
 }
 
 // JobStore manages async jobs in memory
@@ -80,6 +97,16 @@ func (s *JobStore) SetProcessing(id string) {
 	}
 }
 
+// SetCallTrace attaches the model call trace reported in the job status
+func (s *JobStore) SetCallTrace(id string, trace *services.CallTrace) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		job.Calls = trace
+	}
+}
+
 // SetCompleted marks a job as completed with a result
 func (s *JobStore) SetCompleted(id string, result *AnalyzeResult) {
 	s.mu.Lock()
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+
+	"google.golang.org/adk/session"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+)
+
+// unsafeFileNameChars are the characters replaced in download file names.
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// LUTHandler exports the enhanced look of a session as a 3D LUT.
+type LUTHandler struct {
+	deps *Dependencies
+}
+
+// NewLUTHandler creates a new LUT export handler
+func NewLUTHandler(deps *Dependencies) *LUTHandler {
+	return &LUTHandler{deps: deps}
+}
+
+// ServeHTTP handles GET /photo/sessions/{sessionId}/lut
+func (h *LUTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	sessionID := r.PathValue("sessionId")
+	if sessionID == "" {
+		writeJSONError(w, http.StatusBadRequest, "sessionId is required")
+		return
+	}
+
+	userID := r.URL.Query().Get("userId")
+	if userID == "" {
+		writeJSONError(w, http.StatusBadRequest, "userId is required")
+		return
+	}
+
+	ctx := r.Context()
+	getResponse, err := h.deps.SessionService.Get(ctx, &session.GetRequest{
+		AppName:   "photo_levelup",
+		UserID:    userID,
+		SessionID: sessionID,
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	state := getResponse.Session.State()
+	originalObject := objectNameFromProxyURL(stateString(state, "original_image_url"))
+	enhancedObject := objectNameFromProxyURL(stateString(state, "clean_enhanced_image_url"))
+	if originalObject == "" || enhancedObject == "" {
+		writeJSONError(w, http.StatusConflict, "Session has no original and clean enhanced image pair")
+		return
+	}
+
+	storageClient := h.deps.Storage
+	if storageClient == nil {
+		log.Printf("ERROR: LUTHandler storage client error: no object store configured")
+		writeJSONError(w, http.StatusInternalServerError, "storage client error")
+		return
+	}
+
+	original, _, err := loadStoredImage(ctx, storageClient, originalObject)
+	if err != nil {
+		log.Printf("ERROR: LUTHandler failed to load original image: %v", err)
+		writeJSONError(w, http.StatusNotFound, "image not found")
+		return
+	}
+	enhanced, _, err := loadStoredImage(ctx, storageClient, enhancedObject)
+	if err != nil {
+		log.Printf("ERROR: LUTHandler failed to load enhanced image: %v", err)
+		writeJSONError(w, http.StatusNotFound, "image not found")
+		return
+	}
+
+	lut, err := services.FitColorLUT(original, enhanced, services.DefaultLUTSize)
+	if err != nil {
+		log.Printf("ERROR: LUTHandler failed to fit LUT for session %s: %v", sessionID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to build LUT")
+		return
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := lut.WriteCube(buffer, stateString(state, "title")); err != nil {
+		log.Printf("ERROR: LUTHandler failed to encode LUT: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to build LUT")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="photo_levelup_%s.cube"`, unsafeFileNameChars.ReplaceAllString(sessionID, "_")))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buffer.Bytes()); err != nil {
+		log.Printf("ERROR: LUTHandler failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/adk/session"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+)
+
+func TestLUTHandler(t *testing.T) {
+	ctx := context.Background()
+	store := services.NewMemoryObjectStore()
+	deps := NewDependencies(nil, session.InMemoryService(), services.NewFakeModelProvider(store), store)
+	state := map[string]any{"title": "夕景"}
+	for key, prefix := range map[string]string{"original_image_url": "uploads", "clean_enhanced_image_url": "clean_enhanced"} {
+		_, objectName, err := store.UploadImageWithPrefix(ctx, testPhoto(t), "image/jpeg", prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state[key], _ = buildImageProxyURL("http://backend.test", objectName)
+	}
+	created, err := deps.SessionService.Create(ctx, &session.CreateRequest{
+		AppName:   "photo_levelup",
+		UserID:    "user-1",
+		SessionID: "session\"1\r\n",
+		State:     state,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(sessionID string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/photo/sessions/x/lut?userId=user-1", nil)
+		request.SetPathValue("sessionId", sessionID)
+		recorder := httptest.NewRecorder()
+		NewLUTHandler(deps).ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := get(created.Session.ID())
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if !strings.Contains(recorder.Body.String(), "LUT_3D_SIZE") {
+		t.Errorf("body is not a .cube file:\n%s", recorder.Body.String())
+	}
+	if got, want := recorder.Header().Get("Content-Disposition"), `attachment; filename="photo_levelup_session_1__.cube"`; got != want {
+		t.Errorf("Content-Disposition = %q, want %q", got, want)
+	}
+
+	if recorder := get("unknown"); recorder.Code != http.StatusNotFound {
+		t.Errorf("unknown session status = %d, want 404", recorder.Code)
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/profile"
+)
+
+var (
+	errInvalidLocale   = errors.New("unsupported locale")
+	errInvalidTimeZone = errors.New("unknown timeZone")
+)
+
+// resolveSettings picks the output locale and timezone for a request: the
+// request's own values, which are saved to the user's profile when they
+// change, then the profile, then the Accept-Language header.
+func resolveSettings(ctx context.Context, deps *Dependencies, r *http.Request, userID, requestedLocale, requestedTimeZone string) (locale.Settings, error) {
+	return settingsFor(ctx, deps, r, userID, requestedLocale, requestedTimeZone, true)
+}
+
+// readSettings resolves the settings like resolveSettings but never writes
+// the profile, so read-only endpoints can honour ?locale= without changing
+// the user's preferences.
+func readSettings(ctx context.Context, deps *Dependencies, r *http.Request, userID, requestedLocale, requestedTimeZone string) (locale.Settings, error) {
+	return settingsFor(ctx, deps, r, userID, requestedLocale, requestedTimeZone, false)
+}
+
+func settingsFor(ctx context.Context, deps *Dependencies, r *http.Request, userID, requestedLocale, requestedTimeZone string, persist bool) (locale.Settings, error) {
+	settings := locale.DefaultSettings()
+	stored, err := deps.Profiles.Get(ctx, userID)
+	if err != nil {
+		log.Printf("WARN: Failed to load profile for user %s: %v", userID, err)
+		stored = profile.Profile{UserID: userID}
+	}
+
+	updated := stored
+	if raw := strings.TrimSpace(requestedLocale); raw != "" {
+		l, ok := locale.Parse(raw)
+		if !ok {
+			return settings, errInvalidLocale
+		}
+		updated.Locale = string(l)
+	}
+	if raw := strings.TrimSpace(requestedTimeZone); raw != "" {
+		if _, err := time.LoadLocation(raw); err != nil {
+			return settings, errInvalidTimeZone
+		}
+		updated.TimeZone = raw
+	}
+	if persist && updated != stored && userID != "anonymous" {
+		if err := deps.Profiles.Save(ctx, updated); err != nil {
+			log.Printf("WARN: Failed to save profile for user %s: %v", userID, err)
+		}
+	}
+
+	if l, ok := locale.Parse(updated.Locale); ok {
+		settings.Locale = l
+	} else if l, ok := locale.FromAcceptLanguage(r.Header.Get("Accept-Language")); ok {
+		settings.Locale = l
+	}
+	settings.TimeZone = updated.TimeZone
+	return settings, nil
+}
+
+// ProfileHandler reads and updates a user's preferences.
+type ProfileHandler struct {
+	deps *Dependencies
+}
+
+// NewProfileHandler creates a new profile handler.
+func NewProfileHandler(deps *Dependencies) *ProfileHandler {
+	return &ProfileHandler{deps: deps}
+}
+
+type profileRequest struct {
+	UserID   string `json:"userId"`
+	Locale   string `json:"locale,omitempty"`
+	TimeZone string `json:"timeZone,omitempty"`
+	// SkillLevel sets the user's level; "auto" returns to inferring it.
+	SkillLevel string `json:"skillLevel,omitempty"`
+}
+
+// profileResponse is the stored profile with the level critiques currently use.
+type profileResponse struct {
+	profile.Profile
+	// EffectiveSkillLevel is the chosen level or the one inferred from the
+	// score history; empty when there is not enough history yet.
+	EffectiveSkillLevel string `json:"effectiveSkillLevel,omitempty"`
+}
+
+// ServeHTTP handles GET /photo/profile?userId=... and PUT /photo/profile
+func (h *ProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req profileRequest
+	if r.Method == http.MethodPut {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+	} else {
+		req.UserID = r.URL.Query().Get("userId")
+	}
+	if req.UserID == "" {
+		writeJSONError(w, http.StatusBadRequest, "userId is required")
+		return
+	}
+
+	ctx := r.Context()
+	if _, err := resolveSettings(ctx, h.deps, r, req.UserID, req.Locale, req.TimeZone); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	level, err := resolveSkillLevel(ctx, h.deps, req.UserID, req.SkillLevel)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	stored, err := h.deps.Profiles.Get(ctx, req.UserID)
+	if err != nil {
+		log.Printf("ERROR: ProfileHandler failed to read profile for user %s: %v", req.UserID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read profile")
+		return
+	}
+	writeJSON(w, http.StatusOK, profileResponse{Profile: stored, EffectiveSkillLevel: string(level)})
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/quota"
+)
+
+type quotaExceededResponse struct {
+	Error   string    `json:"error"`
+	Limit   string    `json:"limit"`
+	ResetAt time.Time `json:"resetAt"`
+}
+
+// reserveQuota claims quota for action and writes the error response when
+// it cannot; callers stop when it returns false.
+func reserveQuota(w http.ResponseWriter, ctx context.Context, deps *Dependencies, userID string, action quota.Action) bool {
+	err := deps.Quota.Reserve(ctx, userID, action)
+	if err == nil {
+		return true
+	}
+	if exceeded, ok := quota.AsExceeded(err); ok {
+		log.Printf("INFO: Rejected %s for user %s: %v", action, userID, err)
+		retryAfter := int(math.Ceil(time.Until(exceeded.ResetAt).Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+		writeJSON(w, http.StatusTooManyRequests, quotaExceededResponse{
+			Error:   "Daily limit reached",
+			Limit:   exceeded.Counter,
+			ResetAt: exceeded.ResetAt,
+		})
+		return false
+	}
+	log.Printf("ERROR: Quota check failed for user %s: %v", userID, err)
+	writeJSONError(w, http.StatusServiceUnavailable, "Quota check failed")
+	return false
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"google.golang.org/adk/session"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/quota"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+)
+
+// analyzeRequest builds a multipart analyze request for user-1 carrying data.
+func analyzeRequest(t *testing.T, data []byte) *http.Request {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", "photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	writer.WriteField("userId", "user-1")
+	writer.WriteField("sessionId", "frontend-quota")
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	request := httptest.NewRequest(http.MethodPost, "/photo/analyze", body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return request
+}
+
+// quotaDependencies allows one analysis and one chat turn per day.
+func quotaDependencies(storage services.ObjectStore) *Dependencies {
+	deps := NewDependencies(nil, session.InMemoryService(), services.NewFakeModelProvider(nil), storage)
+	deps.Quota = quota.NewEnforcer(quota.NewMemoryStore(), quota.Limits{quota.CounterAnalyses: 1, quota.CounterChatTurns: 1})
+	return deps
+}
+
+func usedCounter(t *testing.T, deps *Dependencies, counter string) float64 {
+	t.Helper()
+	counters, _, _, err := deps.Quota.Status(context.Background(), "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return counters[counter]
+}
+
+func TestAnalyzeQuotaExceeded(t *testing.T) {
+	deps := quotaDependencies(nil)
+	if err := deps.Quota.Reserve(context.Background(), "user-1", quota.ActionAnalysis); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	NewAnalyzeHandler(deps).ServeHTTP(recorder, analyzeRequest(t, testPhoto(t)))
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if seconds, err := strconv.Atoi(recorder.Header().Get("Retry-After")); err != nil || seconds < 1 {
+		t.Errorf("Retry-After = %q, want a positive number of seconds", recorder.Header().Get("Retry-After"))
+	}
+	var response quotaExceededResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Limit != quota.CounterAnalyses || response.ResetAt.IsZero() {
+		t.Errorf("response = %+v, want the exceeded limit and its reset time", response)
+	}
+}
+
+func TestAnalyzeInvalidImageKeepsQuota(t *testing.T) {
+	deps := quotaDependencies(services.NewMemoryObjectStore())
+
+	recorder := httptest.NewRecorder()
+	NewAnalyzeHandler(deps).ServeHTTP(recorder, analyzeRequest(t, []byte("not an image")))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if used := usedCounter(t, deps, quota.CounterAnalyses); used != 0 {
+		t.Errorf("analyses used = %g, want 0 after a rejected upload", used)
+	}
+}
+
+func TestProcessAnalysisReleasesQuotaOnEarlyFailure(t *testing.T) {
+	deps := quotaDependencies(nil)
+	if err := deps.Quota.Reserve(context.Background(), "user-1", quota.ActionAnalysis); err != nil {
+		t.Fatal(err)
+	}
+
+	jobID := "test-quota-release"
+	GetJobStore().Create(jobID)
+	NewAnalyzeHandler(deps).processAnalysis(jobID, "user-1", "frontend-quota", testPhoto(t), "image/jpeg", "http://backend.test", analysisOptions{})
+	if job, _ := GetJobStore().Get(jobID); job.Status != JobStatusFailed {
+		t.Fatalf("job = %+v, want a failed job without storage", job)
+	}
+	if used := usedCounter(t, deps, quota.CounterAnalyses); used != 0 {
+		t.Errorf("analyses used = %g, want the reservation released", used)
+	}
+}
+
+func TestChatReleasesQuotaWhenRunNeverStarts(t *testing.T) {
+	deps := quotaDependencies(services.NewMemoryObjectStore())
+
+	// A region question on a session without a photo fails before the runner starts.
+	body := `{"sessionId":"frontend-quota","userId":"user-1","message":"ここは?","region":{"x":0.1,"y":0.1,"width":0.2,"height":0.2}}`
+	recorder := httptest.NewRecorder()
+	NewChatHandler(deps).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/photo/chat", bytes.NewBufferString(body)))
+	if recorder.Code == http.StatusOK {
+		t.Fatalf("status = %d, want the region question to fail", recorder.Code)
+	}
+	if used := usedCounter(t, deps, quota.CounterChatTurns); used != 0 {
+		t.Errorf("chat turns used = %g, want the reservation released", used)
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+)
+
+// RubricsHandler lists the scoring rubrics a photo can be analyzed with.
+type RubricsHandler struct{}
+
+// NewRubricsHandler creates a new rubrics handler.
+func NewRubricsHandler() *RubricsHandler {
+	return &RubricsHandler{}
+}
+
+type rubricInfo struct {
+	ID         string         `json:"id"`
+	Name       string         `json:"name"`
+	Genre      string         `json:"genre,omitempty"`
+	Scale      rubric.Scale   `json:"scale"`
+	Categories []categoryInfo `json:"categories"`
+}
+
+type categoryInfo struct {
+	Key         string  `json:"key"`
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Weight      float64 `json:"weight"`
+}
+
+// ServeHTTP handles GET /photo/rubrics?locale=...
+func (h *RubricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l, ok := locale.Parse(r.URL.Query().Get("locale"))
+	if !ok {
+		if l, ok = locale.FromAcceptLanguage(r.Header.Get("Accept-Language")); !ok {
+			l = locale.Default
+		}
+	}
+
+	rubrics := rubric.Default().List()
+	infos := make([]rubricInfo, 0, len(rubrics))
+	for _, item := range rubrics {
+		info := rubricInfo{
+			ID:    item.ID,
+			Name:  item.Name.In(l),
+			Genre: string(item.Genre),
+			Scale: item.Scale,
+		}
+		for _, category := range item.Categories {
+			info.Categories = append(info.Categories, categoryInfo{
+				Key:         category.Key,
+				Name:        category.Name.In(l),
+				Description: category.Description.In(l),
+				Weight:      item.Weight(category.Key),
+			})
+		}
+		infos = append(infos, info)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"rubrics": infos})
+}
@@ -9,6 +9,9 @@ import (
 	"time"
 
 	"google.golang.org/adk/session"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
 )
 
 // SessionInfo represents a session summary for the list API
@@ -28,10 +31,19 @@ type SessionInfo struct {
 // SessionDetail represents a full session with conversation history
 type SessionDetail struct {
 	SessionInfo
-	Messages              []MessageInfo   `json:"messages"`
-	AnalysisResult        json.RawMessage `json:"analysisResult,omitempty"`
-	OriginalImage         string          `json:"originalImageUrl,omitempty"`
-	CleanEnhancedImageURL string          `json:"cleanEnhancedImageUrl,omitempty"`
+	Messages       []MessageInfo   `json:"messages"`
+	AnalysisResult json.RawMessage `json:"analysisResult,omitempty"`
+	// Regions repeats the located critique from the analysis for direct overlay use.
+	Regions               []services.RegionCritique `json:"regions,omitempty"`
+	OriginalImage         string                    `json:"originalImageUrl,omitempty"`
+	CleanEnhancedImageURL string                    `json:"cleanEnhancedImageUrl,omitempty"`
+	HeatmapImageURL       string                    `json:"heatmapImageUrl,omitempty"`
+	UpscaledImageURL      string                    `json:"upscaledImageUrl,omitempty"`
+	Fidelity              json.RawMessage           `json:"fidelity,omitempty"`
+	// Models records which model served each analysis step.
+	Models json.RawMessage `json:"models,omitempty"`
+	// Usage is the session's accumulated token usage and cost.
+	Usage json.RawMessage `json:"usage,omitempty"`
 }
 
 // MessageInfo represents a chat message
@@ -39,6 +51,9 @@ type MessageInfo struct {
 	Role      string    `json:"role"`
 	Content   string    `json:"content"`
 	Timestamp time.Time `json:"timestamp"`
+	// Region and CropImageURL are set when the question was about a selected area.
+	Region       *services.NormalizedBox `json:"region,omitempty"`
+	CropImageURL string                  `json:"cropImageUrl,omitempty"`
 }
 
 // SessionsHandler handles session list requests
@@ -65,6 +80,12 @@ func (h *SessionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+	settings, err := readSettings(ctx, h.deps, r, userID, r.URL.Query().Get("locale"), r.URL.Query().Get("timeZone"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx = locale.WithSettings(ctx, settings)
 	sessions, err := h.listUserSessions(ctx, userID)
 	if err != nil {
 		writeJSONError(w, http.StatusInternalServerError, "Failed to list sessions")
@@ -102,7 +123,7 @@ func (h *SessionsHandler) listUserSessions(ctx context.Context, userID string) (
 			}
 		}
 		if info.Title == "" {
-			info.Title = formatSessionTitle(sess.LastUpdateTime())
+			info.Title = locale.FromContext(ctx).FormatTitle(sess.LastUpdateTime())
 		}
 
 		if createdAt, err := state.Get("created_at"); err == nil {
@@ -186,6 +207,12 @@ func (h *SessionDetailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 
 	ctx := r.Context()
+	settings, err := readSettings(ctx, h.deps, r, userID, r.URL.Query().Get("locale"), r.URL.Query().Get("timeZone"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx = locale.WithSettings(ctx, settings)
 	detail, err := h.getSessionDetail(ctx, userID, sessionID)
 	if err != nil {
 		writeJSONError(w, http.StatusNotFound, "Session not found")
@@ -224,7 +251,7 @@ func (h *SessionDetailHandler) getSessionDetail(ctx context.Context, userID, ses
 		}
 	}
 	if detail.Title == "" {
-		detail.Title = formatSessionTitle(sess.LastUpdateTime())
+		detail.Title = locale.FromContext(ctx).FormatTitle(sess.LastUpdateTime())
 	}
 
 	if createdAt, err := state.Get("created_at"); err == nil {
@@ -269,13 +296,32 @@ func (h *SessionDetailHandler) getSessionDetail(ctx context.Context, userID, ses
 	if analysisResult, err := state.Get("analysis_result"); err == nil {
 		if s, ok := analysisResult.(string); ok {
 			detail.AnalysisResult = json.RawMessage(s)
+
+			var analysis services.AnalysisResult
+			if err := json.Unmarshal([]byte(s), &analysis); err == nil {
+				detail.Regions = analysis.Regions
+			}
 		}
 	}
 
+	detail.HeatmapImageURL = stateString(state, "heatmap_image_url")
+	detail.UpscaledImageURL = stateString(state, "upscaled_image_url")
+
+	if fidelity := stateString(state, "fidelity_result"); fidelity != "" {
+		detail.Fidelity = json.RawMessage(fidelity)
+	}
+	if models := stateString(state, "models"); models != "" {
+		detail.Models = json.RawMessage(models)
+	}
+	if usage := stateString(state, "usage"); usage != "" {
+		detail.Usage = json.RawMessage(usage)
+	}
+
 	// Extract messages from events
 	events := sess.Events()
 	log.Printf("INFO: getSessionDetail for session %s: found %d events", sessionID, events.Len())
 
+	references := parseRegionReferences(state)
 	for i := 0; i < events.Len(); i++ {
 		event := events.At(i)
 		if event.Content == nil {
@@ -294,8 +340,19 @@ func (h *SessionDetailHandler) getSessionDetail(ctx context.Context, userID, ses
 		}
 
 		var content string
+		var reference *regionReference
+		if stored, ok := references[event.InvocationID]; ok && role == "user" {
+			reference = &stored
+		}
 		if event.Content.Parts != nil {
 			for _, part := range event.Content.Parts {
+				if strings.HasPrefix(part.Text, regionReferencePrefix) {
+					var parsed regionReference
+					if err := json.Unmarshal([]byte(strings.TrimPrefix(part.Text, regionReferencePrefix)), &parsed); err == nil {
+						reference = &parsed
+					}
+					continue
+				}
 				if part.Text != "" {
 					content += part.Text
 				}
@@ -308,6 +365,10 @@ func (h *SessionDetailHandler) getSessionDetail(ctx context.Context, userID, ses
 				Content:   content,
 				Timestamp: event.Timestamp,
 			}
+			if reference != nil {
+				msg.Region = &reference.Box
+				msg.CropImageURL = reference.CropImageURL
+			}
 			detail.Messages = append(detail.Messages, msg)
 			log.Printf("INFO: Added message %d to session %s: role=%s, content length=%d", len(detail.Messages), sessionID, role, len(content))
 		} else {
@@ -321,10 +382,36 @@ func (h *SessionDetailHandler) getSessionDetail(ctx context.Context, userID, ses
 	return detail, nil
 }
 
-func formatSessionTitle(t time.Time) string {
-	loc, _ := time.LoadLocation("Asia/Tokyo")
-	if loc != nil {
-		t = t.In(loc)
+// stateString returns the string stored under key, or "" when it is missing.
+// stateNumber reads a numeric state value, which is an int in memory and a
+// float64 or int64 once it has round-tripped through storage.
+func stateNumber(state session.State, key string) (float64, bool) {
+	if state == nil {
+		return 0, false
+	}
+	value, err := state.Get(key)
+	if err != nil {
+		return 0, false
+	}
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func stateString(state session.State, key string) string {
+	if state == nil {
+		return ""
+	}
+	value, err := state.Get(key)
+	if err != nil {
+		return ""
 	}
-	return t.Format("1月2日 15:04")
+	s, _ := value.(string)
+	return s
 }
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+)
+
+func TestSessionDetailRegions(t *testing.T) {
+	ctx := context.Background()
+	deps := NewDependencies(nil, session.InMemoryService(), services.NewFakeModelProvider(nil), nil)
+	analysis := services.FakeAnalysisResult()
+	analysisJSON, _ := json.Marshal(analysis)
+	created, err := deps.SessionService.Create(ctx, &session.CreateRequest{
+		AppName: "photo_levelup",
+		UserID:  "user-1",
+		State:   map[string]any{"analysis_result": string(analysisJSON)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/photo/sessions/"+created.Session.ID()+"?userId=user-1", nil)
+	recorder := httptest.NewRecorder()
+	NewSessionDetailHandler(deps).ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var detail SessionDetail
+	if err := json.Unmarshal(recorder.Body.Bytes(), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if len(detail.Regions) == 0 || len(detail.Regions) != len(analysis.Regions) {
+		t.Fatalf("regions = %+v, want %+v", detail.Regions, analysis.Regions)
+	}
+	for i, region := range detail.Regions {
+		if region != analysis.Regions[i] {
+			t.Errorf("region %d = %+v, want %+v", i, region, analysis.Regions[i])
+		}
+	}
+}
+
+func TestSessionDetailRegionReference(t *testing.T) {
+	ctx := context.Background()
+	deps := NewDependencies(nil, session.InMemoryService(), services.NewFakeModelProvider(nil), nil)
+	created, err := deps.SessionService.Create(ctx, &session.CreateRequest{AppName: "photo_levelup", UserID: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	question := session.NewEvent("invocation-1")
+	question.Author = "user"
+	question.Content = genai.NewContentFromText("ここは暗すぎますか?", genai.RoleUser)
+	if err := deps.SessionService.AppendEvent(ctx, created.Session, question); err != nil {
+		t.Fatal(err)
+	}
+	box := services.NormalizedBox{X: 0.1, Y: 0.2, Width: 0.3, Height: 0.4}
+	recordRegionReference(ctx, deps, "user-1", created.Session.ID(), "invocation-1", regionReference{Box: box, CropImageURL: "http://backend.test/photo/image?object=crops/1"})
+
+	detail, err := NewSessionDetailHandler(deps).getSessionDetail(ctx, "user-1", created.Session.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detail.Messages) != 1 {
+		t.Fatalf("messages = %+v", detail.Messages)
+	}
+	message := detail.Messages[0]
+	if message.Content != "ここは暗すぎますか?" || message.Region == nil || *message.Region != box || message.CropImageURL == "" {
+		t.Errorf("message = %+v, want the question with its region", message)
+	}
+}
+
+func TestSessionReadsDoNotSaveSettings(t *testing.T) {
+	ctx := context.Background()
+	deps := NewDependencies(nil, session.InMemoryService(), services.NewFakeModelProvider(nil), nil)
+	created, err := deps.SessionService.Create(ctx, &session.CreateRequest{AppName: "photo_levelup", UserID: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := "?userId=user-1&locale=en&timeZone=America/New_York"
+	requests := []struct {
+		path    string
+		handler http.Handler
+	}{
+		{path: "/photo/sessions" + query, handler: NewSessionsHandler(deps)},
+		{path: "/photo/sessions/" + created.Session.ID() + query, handler: NewSessionDetailHandler(deps)},
+	}
+	for _, request := range requests {
+		recorder := httptest.NewRecorder()
+		request.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, request.path, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d: %s", request.path, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	stored, err := deps.Profiles.Get(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Locale != "" || stored.TimeZone != "" {
+		t.Errorf("profile = %+v, want the query settings left unsaved", stored)
+	}
+}
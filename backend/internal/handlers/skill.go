@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/adk/session"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/skill"
+)
+
+var errInvalidSkillLevel = errors.New("unsupported skillLevel")
+
+// skillLevelAuto clears a chosen level so it is inferred again.
+const skillLevelAuto = "auto"
+
+// skillInferenceTTL is how long an inferred level is reused before the
+// score history is read again; a level moves over weeks, not requests.
+const skillInferenceTTL = 24 * time.Hour
+
+// resolveSkillLevel picks the level critiques are written for: the
+// request's level, which is saved to the user's profile ("auto" clears it),
+// then the profile, then a level inferred from the user's score history,
+// which is cached on the profile for skillInferenceTTL. An empty level
+// means there is not enough history to tell.
+func resolveSkillLevel(ctx context.Context, deps *Dependencies, userID, requested string) (skill.Level, error) {
+	requested = strings.TrimSpace(requested)
+	var chosen skill.Level
+	if requested != "" && !strings.EqualFold(requested, skillLevelAuto) {
+		level, ok := skill.Parse(requested)
+		if !ok {
+			return "", errInvalidSkillLevel
+		}
+		chosen = level
+	}
+
+	stored, err := deps.Profiles.Get(ctx, userID)
+	loaded := err == nil
+	if err != nil {
+		log.Printf("WARN: Failed to load profile for user %s: %v", userID, err)
+	} else if requested != "" && stored.SkillLevel != string(chosen) && userID != "anonymous" {
+		stored.SkillLevel = string(chosen)
+		if err := deps.Profiles.Save(ctx, stored); err != nil {
+			log.Printf("WARN: Failed to save profile for user %s: %v", userID, err)
+		}
+	}
+	if requested == "" {
+		chosen, _ = skill.Parse(stored.SkillLevel)
+	}
+	if chosen != "" {
+		return chosen, nil
+	}
+	if cached, ok := skill.Parse(stored.InferredSkillLevel); ok && time.Since(stored.SkillInferredAt) < skillInferenceTTL {
+		return cached, nil
+	}
+	inferred := inferSkillLevel(ctx, deps.SessionService, userID)
+	// Too little history is not cached, so the next analysis can tell.
+	if inferred != "" && loaded && userID != "anonymous" {
+		stored.InferredSkillLevel = string(inferred)
+		stored.SkillInferredAt = time.Now()
+		if err := deps.Profiles.Save(ctx, stored); err != nil {
+			log.Printf("WARN: Failed to save profile for user %s: %v", userID, err)
+		}
+	}
+	return inferred, nil
+}
+
+// inferSkillLevel infers a level from the overall scores of the user's
+// analyzed sessions, newest first.
+func inferSkillLevel(ctx context.Context, sessionService session.Service, userID string) skill.Level {
+	if userID == "anonymous" {
+		return ""
+	}
+	listResponse, err := sessionService.List(ctx, &session.ListRequest{AppName: "photo_levelup", UserID: userID})
+	if err != nil {
+		log.Printf("WARN: Failed to list sessions to infer the skill level of user %s: %v", userID, err)
+		return ""
+	}
+	sessions := append([]session.Session(nil), listResponse.Sessions...)
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUpdateTime().After(sessions[j].LastUpdateTime())
+	})
+	var history []float64
+	for _, sess := range sessions {
+		state := sess.State()
+		score, ok := stateNumber(state, "overall_score")
+		if !ok {
+			continue
+		}
+		maxScore, ok := stateNumber(state, "score_max")
+		if !ok || maxScore <= 0 {
+			maxScore = 10
+		}
+		history = append(history, score/maxScore)
+	}
+	level, _ := skill.Infer(history)
+	return level
+}
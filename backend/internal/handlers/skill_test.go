@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/skill"
+)
+
+func TestResolveSkillLevel(t *testing.T) {
+	ctx := context.Background()
+	deps := NewDependencies(nil, session.InMemoryService(), services.NewFakeModelProvider(nil), nil)
+
+	if level, err := resolveSkillLevel(ctx, deps, "user-1", ""); err != nil || level != "" {
+		t.Fatalf("no history = %q, %v; want unknown", level, err)
+	}
+	for i, score := range []any{9, 8.0, int64(17)} {
+		state := map[string]any{"overall_score": score}
+		if i == 2 {
+			state["score_max"] = 20
+		}
+		if _, err := deps.SessionService.Create(ctx, &session.CreateRequest{AppName: "photo_levelup", UserID: "user-1", State: state}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if level, _ := resolveSkillLevel(ctx, deps, "user-1", ""); level != skill.Professional {
+		t.Errorf("inferred level = %q, want professional", level)
+	}
+
+	if level, err := resolveSkillLevel(ctx, deps, "user-1", "beginner"); err != nil || level != skill.Beginner {
+		t.Fatalf("chosen level = %q, %v", level, err)
+	}
+	if level, _ := resolveSkillLevel(ctx, deps, "user-1", ""); level != skill.Beginner {
+		t.Errorf("saved level = %q, want beginner", level)
+	}
+	if level, _ := resolveSkillLevel(ctx, deps, "user-1", "auto"); level != skill.Professional {
+		t.Errorf("auto level = %q, want inferred professional", level)
+	}
+	if _, err := resolveSkillLevel(ctx, deps, "user-1", "expert"); err != errInvalidSkillLevel {
+		t.Errorf("unknown level error = %v", err)
+	}
+}
+
+// countingSessions counts the session listings an inference reads.
+type countingSessions struct {
+	session.Service
+	lists int
+}
+
+func (c *countingSessions) List(ctx context.Context, req *session.ListRequest) (*session.ListResponse, error) {
+	c.lists++
+	return c.Service.List(ctx, req)
+}
+
+func TestInferredSkillLevelIsCached(t *testing.T) {
+	ctx := context.Background()
+	sessions := &countingSessions{Service: session.InMemoryService()}
+	deps := NewDependencies(nil, sessions, services.NewFakeModelProvider(nil), nil)
+	for _, score := range []any{9, 8.5, 9} {
+		if _, err := sessions.Create(ctx, &session.CreateRequest{AppName: "photo_levelup", UserID: "user-1", State: map[string]any{"overall_score": score}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for range 3 {
+		if level, _ := resolveSkillLevel(ctx, deps, "user-1", ""); level != skill.Professional {
+			t.Fatalf("inferred level = %q, want professional", level)
+		}
+	}
+	if sessions.lists != 1 {
+		t.Errorf("sessions listed %d times, want once while the inference is fresh", sessions.lists)
+	}
+	stored, _ := deps.Profiles.Get(ctx, "user-1")
+	if stored.InferredSkillLevel != string(skill.Professional) || stored.SkillLevel != "" {
+		t.Errorf("profile = %+v, want the inferred level cached apart from the chosen one", stored)
+	}
+
+	stored.SkillInferredAt = time.Now().Add(-skillInferenceTTL)
+	if err := deps.Profiles.Save(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+	resolveSkillLevel(ctx, deps, "user-1", "")
+	if sessions.lists != 2 {
+		t.Errorf("sessions listed %d times, want a stale inference recomputed", sessions.lists)
+	}
+}
@@ -3,14 +3,14 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
-
-	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
 )
 
-type TestGeminiHandler struct{}
+type TestGeminiHandler struct {
+	deps *Dependencies
+}
 
-func NewTestGeminiHandler() *TestGeminiHandler {
-	return &TestGeminiHandler{}
+func NewTestGeminiHandler(deps *Dependencies) *TestGeminiHandler {
+	return &TestGeminiHandler{deps: deps}
 }
 
 type TestGeminiRequest struct {
@@ -31,14 +31,12 @@ func (h *TestGeminiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	geminiClient := services.NewGeminiClient()
-
 	if req.Action == "generate" {
 		if req.Prompt == "" {
 			writeJSONError(w, http.StatusBadRequest, "prompt is required for generate action")
 			return
 		}
-		result, err := geminiClient.GenerateImage(r.Context(), req.Prompt)
+		result, err := h.deps.Enhancer.GenerateImage(r.Context(), req.Prompt)
 		if err != nil {
 			writeJSONError(w, http.StatusInternalServerError, err.Error())
 			return
@@ -54,7 +52,7 @@ func (h *TestGeminiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := geminiClient.AnalyzeImage(r.Context(), req.ImageURL)
+	result, err := h.deps.Analyzer.AnalyzeImage(r.Context(), req.ImageURL)
 	if err != nil {
 		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+)
+
+// UsageHandler reports token usage and cost for a user and, optionally, one session.
+type UsageHandler struct {
+	deps *Dependencies
+}
+
+// NewUsageHandler creates a new usage handler.
+func NewUsageHandler(deps *Dependencies) *UsageHandler {
+	return &UsageHandler{deps: deps}
+}
+
+type usageResponse struct {
+	User    any                   `json:"user"`
+	Session *services.UsageReport `json:"session,omitempty"`
+	// AverageCostUSD is the mean cost of one run per activity.
+	AverageCostUSD map[string]float64 `json:"averageCostUsd,omitempty"`
+}
+
+// ServeHTTP handles GET /photo/usage?userId=...[&sessionId=...]
+func (h *UsageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("userId")
+	if userID == "" {
+		writeJSONError(w, http.StatusBadRequest, "userId is required")
+		return
+	}
+
+	ctx := r.Context()
+	user, err := h.deps.Usage.Get(ctx, userID)
+	if err != nil {
+		log.Printf("ERROR: UsageHandler failed to read ledger for user %s: %v", userID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read usage")
+		return
+	}
+
+	response := usageResponse{User: user, AverageCostUSD: map[string]float64{}}
+	for activity, activityUsage := range user.ByActivity {
+		response.AverageCostUSD[activity] = activityUsage.AverageCostUSD()
+	}
+
+	if sessionID := r.URL.Query().Get("sessionId"); sessionID != "" {
+		getResponse, err := h.deps.SessionService.Get(ctx, &session.GetRequest{
+			AppName:   "photo_levelup",
+			UserID:    userID,
+			SessionID: sessionID,
+		})
+		if err != nil {
+			writeJSONError(w, http.StatusNotFound, "Session not found")
+			return
+		}
+		report, err := services.ParseUsageReport(stateString(getResponse.Session.State(), "usage"))
+		if err != nil {
+			log.Printf("WARN: UsageHandler ignoring invalid session usage for %s: %v", sessionID, err)
+		}
+		response.Session = &report
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// UsageUsersHandler lists every user's usage, highest spend first. It is an
+// admin endpoint; see authorizeAdmin.
+type UsageUsersHandler struct {
+	deps *Dependencies
+}
+
+// NewUsageUsersHandler creates a new usage listing handler.
+func NewUsageUsersHandler(deps *Dependencies) *UsageUsersHandler {
+	return &UsageUsersHandler{deps: deps}
+}
+
+// ServeHTTP handles GET /photo/usage/users
+func (h *UsageUsersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	users, err := h.deps.Usage.List(r.Context())
+	if err != nil {
+		log.Printf("ERROR: UsageUsersHandler failed to list ledger: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read usage")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"users": users})
+}
+
+// authorizeAdmin checks the USAGE_ADMIN_TOKEN bearer token and writes the
+// error response when it is missing or wrong. Without the token configured
+// admin endpoints do not exist.
+func authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := strings.TrimSpace(os.Getenv("USAGE_ADMIN_TOKEN"))
+	if token == "" {
+		writeJSONError(w, http.StatusNotFound, "Not found")
+		return false
+	}
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return false
+	}
+	return true
+}
+
+// recordAgentUsage prices one ADK runner event against the model that served the chat.
+func recordAgentUsage(trace *services.CallTrace, metadata *genai.GenerateContentResponseUsageMetadata) {
+	model := trace.Models()[services.StepChat]
+	if model == "" {
+		model = services.ModelChain(services.CapabilityAgent)[0]
+	}
+	trace.RecordUsage(services.StepChat, model, services.Prices().Measure(model, metadata, 0))
+}
+
+// recordSessionUsage adds a finished activity's usage and serving models to
+// the session state and its usage to the user's ledger.
+func recordSessionUsage(ctx context.Context, deps *Dependencies, userID, resolvedSessionID, activity string, report services.UsageReport, models map[string]string) {
+	if report.Empty() && len(models) == 0 {
+		return
+	}
+	recordLedgerUsage(ctx, deps, userID, activity, report)
+	if resolvedSessionID == "" {
+		return
+	}
+
+	getResponse, err := deps.SessionService.Get(ctx, &session.GetRequest{
+		AppName:   "photo_levelup",
+		UserID:    userID,
+		SessionID: resolvedSessionID,
+	})
+	if err != nil {
+		log.Printf("WARN: Failed to load session %s for usage update: %v", resolvedSessionID, err)
+		return
+	}
+	updates := map[string]any{}
+	if !report.Empty() {
+		merged, err := mergeSessionUsage(getResponse.Session.State(), report)
+		if err != nil {
+			log.Printf("WARN: Failed to merge usage for session %s: %v", resolvedSessionID, err)
+			return
+		}
+		updates["usage"] = merged
+	}
+	if len(models) > 0 {
+		merged, err := mergeSessionModels(getResponse.Session.State(), models)
+		if err != nil {
+			log.Printf("WARN: Failed to merge models for session %s: %v", resolvedSessionID, err)
+			return
+		}
+		updates["models"] = merged
+	}
+	if err := updateSessionState(ctx, deps.SessionService, userID, resolvedSessionID, updates); err != nil {
+		log.Printf("WARN: Failed to store usage for session %s: %v", resolvedSessionID, err)
+	}
+}
+
+// recordLedgerUsage adds an activity's usage to the user's ledger.
+func recordLedgerUsage(ctx context.Context, deps *Dependencies, userID, activity string, report services.UsageReport) {
+	if report.Empty() || deps.Usage == nil {
+		return
+	}
+	if err := deps.Usage.Record(ctx, userID, activity, report); err != nil {
+		log.Printf("WARN: Failed to record %s usage for user %s: %v", activity, userID, err)
+		return
+	}
+	log.Printf("INFO: %s for user %s used %d calls, %d input / %d output tokens, $%.4f",
+		activity, userID, report.Total.Calls, report.Total.InputTokens, report.Total.OutputTokens, report.Total.CostUSD)
+}
+
+// mergeSessionUsage returns the session's stored usage plus report, as JSON.
+func mergeSessionUsage(state session.State, report services.UsageReport) (string, error) {
+	merged, err := services.ParseUsageReport(stateString(state, "usage"))
+	if err != nil {
+		log.Printf("WARN: Replacing invalid session usage: %v", err)
+		merged = services.UsageReport{}
+	}
+	merged.Merge(report)
+	encoded, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// mergeSessionModels records the latest serving model of each step on top
+// of the models the session already lists, so a chat fallback shows up next
+// to the analysis models.
+func mergeSessionModels(state session.State, models map[string]string) (string, error) {
+	merged := map[string]string{}
+	if raw := stateString(state, "models"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &merged); err != nil {
+			log.Printf("WARN: Replacing invalid session models: %v", err)
+			merged = map[string]string{}
+		}
+	}
+	for step, model := range models {
+		merged[step] = model
+	}
+	encoded, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
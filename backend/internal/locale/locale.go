@@ -0,0 +1,121 @@
+// Package locale selects the language, title format and timezone of
+// user-facing output.
+package locale
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Locale is a supported output language.
+type Locale string
+
+const (
+	Japanese Locale = "ja"
+	English  Locale = "en"
+	// Default is used when neither the request nor the user profile names a locale.
+	Default = Japanese
+)
+
+// Supported lists every locale with prompts and messages.
+var Supported = []Locale{Japanese, English}
+
+// Parse accepts a language tag such as "en", "en-US" or "ja_JP".
+func Parse(raw string) (Locale, bool) {
+	tag := strings.ToLower(strings.TrimSpace(raw))
+	if base, _, found := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-"); found {
+		tag = base
+	}
+	for _, supported := range Supported {
+		if tag == string(supported) {
+			return supported, true
+		}
+	}
+	return "", false
+}
+
+// FromAcceptLanguage returns the first supported locale in an
+// Accept-Language header; quality values are ignored beyond their order.
+func FromAcceptLanguage(header string) (Locale, bool) {
+	for _, part := range strings.Split(header, ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		if l, ok := Parse(tag); ok {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// defaultTimeZones are used when the user has not chosen a timezone.
+var defaultTimeZones = map[Locale]string{
+	Japanese: "Asia/Tokyo",
+	English:  "UTC",
+}
+
+// Settings are a user's resolved output preferences.
+type Settings struct {
+	Locale Locale
+	// TimeZone is an IANA name; empty means the locale's default.
+	TimeZone string
+}
+
+// DefaultSettings returns the settings used when nothing else is known.
+func DefaultSettings() Settings {
+	return Settings{Locale: Default}
+}
+
+// Location returns the settings' timezone, falling back to the locale default.
+func (s Settings) Location() *time.Location {
+	for _, name := range []string{s.TimeZone, defaultTimeZones[s.Locale], defaultTimeZones[Default]} {
+		if name == "" {
+			continue
+		}
+		if location, err := time.LoadLocation(name); err == nil {
+			return location
+		}
+	}
+	return time.UTC
+}
+
+// FormatTitle formats a session title from a timestamp.
+func (s Settings) FormatTitle(t time.Time) string {
+	return t.In(s.Location()).Format(s.T("title.format"))
+}
+
+// T returns the message for key in the settings' locale.
+func (s Settings) T(key string, args ...any) string {
+	return T(s.Locale, key, args...)
+}
+
+// T returns the message for key in l, formatted with args. Messages missing
+// in l fall back to the default locale, then to the key itself.
+func T(l Locale, key string, args ...any) string {
+	message, ok := messages[l][key]
+	if !ok {
+		message, ok = messages[Default][key]
+	}
+	if !ok {
+		message = key
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+type settingsKey struct{}
+
+// WithSettings returns a context carrying s.
+func WithSettings(ctx context.Context, s Settings) context.Context {
+	return context.WithValue(ctx, settingsKey{}, s)
+}
+
+// FromContext returns the context's settings, or the defaults.
+func FromContext(ctx context.Context) Settings {
+	if s, ok := ctx.Value(settingsKey{}).(Settings); ok {
+		return s
+	}
+	return DefaultSettings()
+}
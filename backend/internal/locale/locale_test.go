@@ -0,0 +1,63 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw    string
+		want   Locale
+		wantOK bool
+	}{
+		{raw: "ja", want: Japanese, wantOK: true},
+		{raw: "EN-us", want: English, wantOK: true},
+		{raw: "ja_JP", want: Japanese, wantOK: true},
+		{raw: "fr", wantOK: false},
+		{raw: "", wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := Parse(tt.raw)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("Parse(%q) = %q, %v; want %q, %v", tt.raw, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	if got, ok := FromAcceptLanguage("fr-FR,en-GB;q=0.8,ja;q=0.5"); !ok || got != English {
+		t.Errorf("FromAcceptLanguage = %q, %v", got, ok)
+	}
+}
+
+func TestFormatTitle(t *testing.T) {
+	instant := time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC)
+	tests := []struct {
+		settings Settings
+		want     string
+	}{
+		{settings: Settings{Locale: Japanese}, want: "3月2日 08:30"},
+		{settings: Settings{Locale: English}, want: "Mar 1 23:30"},
+		{settings: Settings{Locale: English, TimeZone: "America/New_York"}, want: "Mar 1 18:30"},
+		{settings: Settings{Locale: English, TimeZone: "Nowhere/Invalid"}, want: "Mar 1 23:30"},
+	}
+	for _, tt := range tests {
+		if got := tt.settings.FormatTitle(instant); got != tt.want {
+			t.Errorf("%+v: FormatTitle = %q, want %q", tt.settings, got, tt.want)
+		}
+	}
+}
+
+func TestMessagesAreComplete(t *testing.T) {
+	for _, l := range Supported {
+		for key := range messages[Default] {
+			if _, ok := messages[l][key]; !ok {
+				t.Errorf("%s is missing message %s", l, key)
+			}
+		}
+		for key := range messages[l] {
+			if _, ok := messages[Default][key]; !ok {
+				t.Errorf("%s has message %s that %s lacks", l, key, Default)
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package locale
+
+// messages holds the user-facing strings produced in Go code; prompts live
+// in the prompt templates.
+var messages = map[Locale]map[string]string{
+	Japanese: {
+		"title.format": "1月2日 15:04",
+
+		"error.analysisFailed": "画像分析に失敗しました",
+		"error.compareFailed":  "比較アドバイスに失敗しました",
+
+		"seed.request": "この写真を分析して改善点を教えてください",
+		"seed.summary": "写真を分析しました。\n\n**%s**\n総合スコア: %d/%d\n\n%s",
+
+		"chat.title":         "写真タイトル: %v",
+		"chat.score":         "総合スコア: %v/%v",
+		"chat.analysisJSON":  "分析結果JSON: %v",
+		"chat.context":       "[この写真セッションの分析コンテキスト]\n%s\n\n[ユーザーの質問]\n%s",
+		"chat.region":        "[質問の対象領域]\nユーザーは写真の一部について質問しています。対象は左端から%.0f%%・上端から%.0f%%の位置を起点に、幅%.0f%%・高さ%.0f%%の範囲です。\n1枚目の画像は写真全体、2枚目の画像はその範囲を元解像度で切り出したものです。この範囲に焦点を当てて回答してください。",
+		"chat.regionResized": "[質問の対象領域]\nユーザーは写真の一部について質問しています。対象は左端から%.0f%%・上端から%.0f%%の位置を起点に、幅%.0f%%・高さ%.0f%%の範囲です。\n1枚目の画像は写真全体、2枚目の画像はその範囲を縮小版の写真から切り出したもので、元解像度ではありません。ピントの精度・ノイズ・微細な質感はこの画像から判断せず、その旨を伝えたうえで、この範囲の構図・明るさ・色に焦点を当てて回答してください。",
+
+		"enhance.default.contest":   "構図・露出・色彩・ライティングをより洗練されたコンテスト受賞レベルに高めてください。",
+		"enhance.default.social":    "小さなサムネイルでも主題がひと目で伝わるよう、コントラストと色を整えてください。",
+		"enhance.default.print":     "プリントで階調が豊かに出るよう、白飛び・黒つぶれを避けて露出と色を整えてください。",
+		"enhance.default.portfolio": "厳選した作品として見せられる、完成度の高い仕上がりにしてください。",
+		"enhance.summary":           "サマリー: %s",
+		"enhance.overall":           "総合コメント: %s",
+		"enhance.score":             "総合スコア: %d/%d",
+		"enhance.categories":        "項目別の改善提案:",
+		"enhance.comment":           "講評: %s",
+		"enhance.improvement":       "改善提案: %s",
+
+		"ensemble.unstable": "採点が安定しなかった項目: %s",
+
+		"validate.parse":   "JSONとして解析できません: %s",
+		"validate.empty":   "%s が空です",
+		"validate.missing": "%s がありません",
+		"validate.range":   "%s の値 %d が範囲外です(%d〜%dの整数)",
+
+		"genre.general":      "一般",
+		"genre.portrait":     "ポートレート",
+		"genre.landscape":    "風景",
+		"genre.street":       "スナップ",
+		"genre.macro":        "マクロ",
+		"genre.wildlife":     "野生動物",
+		"genre.night":        "夜景",
+		"genre.product":      "商品",
+		"genre.architecture": "建築",
+
+		"schema.score":              "%dから%dの整数で評価する",
+		"schema.comment":            "現状に対する講評コメント",
+		"schema.improvement":        "具体的な改善提案",
+		"schema.pointX":             "左端を0、右端を1とする横位置",
+		"schema.pointY":             "上端を0、下端を1とする縦位置",
+		"schema.boxX":               "左上の横位置",
+		"schema.boxY":               "左上の縦位置",
+		"schema.boxWidth":           "幅",
+		"schema.boxHeight":          "高さ",
+		"schema.annotationType":     "box: 範囲を囲む, point: 位置を示す, arrow: fromからtoへの矢印",
+		"schema.annotationCategory": "この指摘が関係する採点項目",
+		"schema.annotationLabel":    "写真に書き込む短いコメント(15文字以内)",
+		"schema.annotationBox":      "typeがboxのときの範囲",
+		"schema.regionBox":          "問題のある範囲",
+		"schema.regionCategory":     "この箇所の問題が関係する採点項目",
+		"schema.regionSeverity":     "問題の深刻度",
+		"schema.regionComment":      "この箇所についての具体的な講評と改善方法",
+		"schema.photoSummary":       "写真の内容を一言でまとめたタイトル",
+		"schema.summary":            "全体サマリー",
+		"schema.overallComment":     "総合的なコメント",
+		"schema.overallScore":       "採点項目の平均点(整数)",
+		"schema.annotations":        "写真上の改善ポイントを示す赤ペン添削",
+		"schema.regions":            "問題のある箇所ごとの講評",
+		"schema.scores":             "採点項目ごとの評価",
+		"schema.overallWeighted":    "採点項目の重み付き平均点(整数)",
+		"schema.genre":              "写真のジャンル",
+		"schema.genreConfidence":    "判定の確からしさ(0-1)",
+		"schema.theme":              "テーマ・応募規定に対する評価",
+		"schema.themeScore":         "テーマへの適合度を%dから%dの整数で評価する",
+		"schema.themeComment":       "テーマへの適合度についての講評",
+		"schema.themeViolations":    "違反している応募規定。なければ空の配列",
+		"schema.violationKind":      "違反の種類",
+		"schema.violationRule":      "該当する規定",
+		"schema.violationComment":   "違反の内容",
+		"rule.aspectRatio":          "縦横比 %.2f:1(%d×%dpx)",
+		"rule.resolution":           "%d×%dpx(%.1fメガピクセル)",
+		"rule.date":                 "撮影日時 %s(EXIF)",
+		"rule.dateUnknown":          "EXIFに撮影日時がないため確認できません",
+		"rule.compositingUnknown":   "合成の有無はEXIFから判断できないため、画像から判定します",
+		"rule.compositingSoftware":  "編集ソフト %s の記録があります。合成の有無は画像から判定します",
+	},
+	English: {
+		"title.format": "Jan 2 15:04",
+
+		"error.analysisFailed": "photo analysis failed",
+		"error.compareFailed":  "comparison advice failed",
+
+		"seed.request": "Please analyze this photo and tell me how to improve it.",
+		"seed.summary": "I analyzed your photo.\n\n**%s**\nOverall score: %d/%d\n\n%s",
+
+		"chat.title":         "Photo title: %v",
+		"chat.score":         "Overall score: %v/%v",
+		"chat.analysisJSON":  "Analysis JSON: %v",
+		"chat.context":       "[Analysis context of this photo session]\n%s\n\n[User question]\n%s",
+		"chat.region":        "[Region in question]\nThe user is asking about part of the photo: the area starting %.0f%% from the left and %.0f%% from the top, %.0f%% wide and %.0f%% high.\nThe first image is the whole photo and the second is that area cropped at full resolution. Focus your answer on this area.",
+		"chat.regionResized": "[Region in question]\nThe user is asking about part of the photo: the area starting %.0f%% from the left and %.0f%% from the top, %.0f%% wide and %.0f%% high.\nThe first image is the whole photo and the second is that area cropped from a reduced copy, not at full resolution. Do not judge focus accuracy, noise or fine texture from it and say so; focus your answer on the composition, brightness and color of this area.",
+
+		"enhance.default.contest":   "Refine the composition, exposure, color and lighting to a contest-winning level.",
+		"enhance.default.social":    "Tune contrast and color so the subject reads at a glance even as a small thumbnail.",
+		"enhance.default.print":     "Set exposure and color for rich tonal range in print, avoiding clipped highlights and blocked shadows.",
+		"enhance.default.portfolio": "Give it a polished finish worthy of a photographer's selected work.",
+		"enhance.summary":           "Summary: %s",
+		"enhance.overall":           "Overall comment: %s",
+		"enhance.score":             "Overall score: %d/%d",
+		"enhance.categories":        "Improvements by category:",
+		"enhance.comment":           "Critique: %s",
+		"enhance.improvement":       "Suggestion: %s",
+
+		"ensemble.unstable": "Scores that varied between runs: %s",
+
+		"validate.parse":   "the answer is not valid JSON: %s",
+		"validate.empty":   "%s is empty",
+		"validate.missing": "%s is missing",
+		"validate.range":   "%s is %d, outside the range %d-%d",
+
+		"genre.general":      "General",
+		"genre.portrait":     "Portrait",
+		"genre.landscape":    "Landscape",
+		"genre.street":       "Street",
+		"genre.macro":        "Macro",
+		"genre.wildlife":     "Wildlife",
+		"genre.night":        "Night",
+		"genre.product":      "Product",
+		"genre.architecture": "Architecture",
+
+		"schema.score":              "Integer score from %d to %d",
+		"schema.comment":            "Critique of the current photo",
+		"schema.improvement":        "Concrete suggestion for improvement",
+		"schema.pointX":             "Horizontal position, 0 at the left edge and 1 at the right",
+		"schema.pointY":             "Vertical position, 0 at the top edge and 1 at the bottom",
+		"schema.boxX":               "Horizontal position of the top-left corner",
+		"schema.boxY":               "Vertical position of the top-left corner",
+		"schema.boxWidth":           "Width",
+		"schema.boxHeight":          "Height",
+		"schema.annotationType":     "box: outline an area, point: mark a position, arrow: arrow from 'from' to 'to'",
+		"schema.annotationCategory": "Scoring category this mark relates to",
+		"schema.annotationLabel":    "Short comment written on the photo (at most 4 words)",
+		"schema.annotationBox":      "Area when type is box",
+		"schema.regionBox":          "Area with the problem",
+		"schema.regionCategory":     "Scoring category the problem relates to",
+		"schema.regionSeverity":     "Severity of the problem",
+		"schema.regionComment":      "Specific critique of this area and how to fix it",
+		"schema.photoSummary":       "One-line title describing the photo",
+		"schema.summary":            "Overall summary",
+		"schema.overallComment":     "Overall comment",
+		"schema.overallScore":       "Average of the category scores (integer)",
+		"schema.annotations":        "Red-pen marks showing improvement points on the photo",
+		"schema.regions":            "Critique per problem area",
+		"schema.scores":             "Evaluation per scoring category",
+		"schema.overallWeighted":    "Weighted average of the category scores (integer)",
+		"schema.genre":              "Genre of the photo",
+		"schema.genreConfidence":    "Confidence of the classification (0-1)",
+		"schema.theme":              "Evaluation against the contest theme and rules",
+		"schema.themeScore":         "Theme fit as an integer from %d to %d",
+		"schema.themeComment":       "Critique of how well the photo fits the theme",
+		"schema.themeViolations":    "Rules the photo breaks; empty when none",
+		"schema.violationKind":      "Kind of rule broken",
+		"schema.violationRule":      "The rule that is broken",
+		"schema.violationComment":   "How the photo breaks it",
+		"rule.aspectRatio":          "aspect ratio %.2f:1 (%d×%dpx)",
+		"rule.resolution":           "%d×%dpx (%.1f megapixels)",
+		"rule.date":                 "taken %s (EXIF)",
+		"rule.dateUnknown":          "EXIF has no capture time to check",
+		"rule.compositingUnknown":   "EXIF cannot show compositing; judged from the image",
+		"rule.compositingSoftware":  "EXIF records editing software %s; compositing is judged from the image",
+	},
+}
@@ -0,0 +1,108 @@
+// Package profile stores per-user preferences.
+package profile
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Profile holds a user's preferences.
+type Profile struct {
+	UserID string `json:"userId" firestore:"userId"`
+	// Locale is the output language, e.g. "ja" or "en".
+	Locale string `json:"locale,omitempty" firestore:"locale"`
+	// TimeZone is an IANA timezone name; empty means the locale's default.
+	TimeZone string `json:"timeZone,omitempty" firestore:"timeZone"`
+	// SkillLevel is the level the user chose; empty means it is inferred
+	// from their score history.
+	SkillLevel string `json:"skillLevel,omitempty" firestore:"skillLevel"`
+	// InferredSkillLevel caches the level last inferred from the score
+	// history, as of SkillInferredAt.
+	InferredSkillLevel string    `json:"inferredSkillLevel,omitempty" firestore:"inferredSkillLevel"`
+	SkillInferredAt    time.Time `json:"skillInferredAt,omitempty" firestore:"skillInferredAt"`
+	UpdatedAt          time.Time `json:"updatedAt,omitempty" firestore:"updatedAt"`
+}
+
+// Store persists profiles. Get returns an empty profile for unknown users.
+type Store interface {
+	Get(ctx context.Context, userID string) (Profile, error)
+	Save(ctx context.Context, profile Profile) error
+}
+
+// MemoryStore is an in-process Store.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	profiles map[string]Profile
+}
+
+// NewMemoryStore creates an empty in-memory profile store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{profiles: map[string]Profile{}}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, userID string) (Profile, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if profile, ok := m.profiles[userID]; ok {
+		return profile, nil
+	}
+	return Profile{UserID: userID}, nil
+}
+
+func (m *MemoryStore) Save(ctx context.Context, profile Profile) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	profile.UpdatedAt = time.Now()
+	m.profiles[profile.UserID] = profile
+	return nil
+}
+
+const profilesCollection = "user_profiles"
+
+// FirestoreStore is a Store backed by Cloud Firestore, one document per user.
+type FirestoreStore struct {
+	client *firestore.Client
+}
+
+// NewFirestoreStore creates a Firestore-backed profile store.
+func NewFirestoreStore(ctx context.Context, projectID string) (*FirestoreStore, error) {
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	return &FirestoreStore{client: client}, nil
+}
+
+// Close closes the Firestore client.
+func (s *FirestoreStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *FirestoreStore) Get(ctx context.Context, userID string) (Profile, error) {
+	profile := Profile{UserID: userID}
+	snapshot, err := s.client.Collection(profilesCollection).Doc(userID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return profile, nil
+	}
+	if err != nil {
+		return profile, fmt.Errorf("failed to read profile: %w", err)
+	}
+	if err := snapshot.DataTo(&profile); err != nil {
+		return profile, fmt.Errorf("failed to decode profile: %w", err)
+	}
+	return profile, nil
+}
+
+func (s *FirestoreStore) Save(ctx context.Context, profile Profile) error {
+	profile.UpdatedAt = time.Now()
+	if _, err := s.client.Collection(profilesCollection).Doc(profile.UserID).Set(ctx, profile); err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+	return nil
+}
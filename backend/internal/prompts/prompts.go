@@ -0,0 +1,365 @@
+// Package prompts holds the versioned prompt templates sent to the models,
+// one set per locale. Defaults are embedded; files in PROMPT_TEMPLATE_DIR
+// with the same locale and name (e.g. en/analysis.tmpl) replace them. Files
+// at the top of the directory apply to the default locale.
+package prompts
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+)
+
+// Template names.
+const (
+	Analysis             = "analysis"
+	Genre                = "genre"
+	AnalysisRepair       = "analysis_repair"
+	EnhancementAnnotated = "enhancement_annotated"
+	EnhancementClean     = "enhancement_clean"
+	Compare              = "compare"
+	AgentSystem          = "agent_system"
+)
+
+// AnalysisData fills the analysis template from the rubric applied to the photo.
+type AnalysisData struct {
+	// Genre is the localized genre name; empty for the general rubric.
+	Genre string
+	// Rubric is the name of a rubric chosen by the user; empty for genre rubrics.
+	Rubric string
+	// Categories are the scored categories in rubric order.
+	Categories []CategoryData
+	ScaleMin   int
+	ScaleMax   int
+	// Weighted is true when the overall score is a weighted average.
+	Weighted bool
+	// SkillLevel is the photographer's level (beginner, intermediate,
+	// advanced or professional); empty when it is unknown.
+	SkillLevel string
+	// Purpose is what the photo is for: contest, social, print or portfolio.
+	Purpose string
+	// PrintSize describes the largest prints the upload supports; only
+	// set for the print purpose.
+	PrintSize string
+	// Brief is the contest theme or rules the photo is judged against;
+	// empty when none was given.
+	Brief string
+}
+
+// CategoryData is one scored category.
+type CategoryData struct {
+	Key         string
+	Name        string
+	Description string
+	// Weight is the category weight, e.g. "1.5"; empty when it is 1.
+	Weight string
+}
+
+// RepairData fills the analysis repair template.
+type RepairData struct {
+	Issues []string
+}
+
+// GenreData fills the genre classification template.
+type GenreData struct {
+	Genres []string
+}
+
+// EnhancementData fills the enhancement templates.
+type EnhancementData struct {
+	Analysis    string
+	CustomNotes string
+	Strict      bool
+	// Purpose is what the photo is for, as in AnalysisData.
+	Purpose string
+}
+
+// AgentSystemData fills the agent system prompt.
+type AgentSystemData struct {
+	// SkillLevel is the user's level, as in AnalysisData.
+	SkillLevel string
+}
+
+// CompareData fills the compare template.
+type CompareData struct {
+	Analysis string
+}
+
+// required lists every template with sample data used to validate it.
+var required = map[string]any{
+	Analysis: AnalysisData{
+		Genre:      "sample",
+		Rubric:     "sample",
+		Categories: []CategoryData{{Key: "sample", Name: "sample", Description: "sample", Weight: "1.5"}},
+		ScaleMin:   0,
+		ScaleMax:   10,
+		Weighted:   true,
+		SkillLevel: "beginner",
+		Purpose:    "print",
+		PrintSize:  "sample",
+		Brief:      "sample",
+	},
+	Genre:                GenreData{Genres: []string{"sample"}},
+	AnalysisRepair:       RepairData{Issues: []string{"sample"}},
+	EnhancementAnnotated: EnhancementData{Analysis: "sample", CustomNotes: "sample", Strict: true, Purpose: "contest"},
+	EnhancementClean:     EnhancementData{Analysis: "sample", CustomNotes: "sample", Strict: true, Purpose: "contest"},
+	Compare:              CompareData{Analysis: "sample"},
+	AgentSystem:          AgentSystemData{SkillLevel: "beginner"},
+}
+
+//go:embed templates/*/*.tmpl
+var embedded embed.FS
+
+// Template is one parsed prompt template.
+type Template struct {
+	Name    string
+	Locale  locale.Locale
+	Version string
+	// Source is where the template was loaded from: "embedded" or a file path.
+	Source string
+	tmpl   *template.Template
+}
+
+// Registry maps locales and template names to templates.
+type Registry struct {
+	templates map[string]*Template
+}
+
+func templateKey(l locale.Locale, name string) string {
+	return string(l) + "/" + name
+}
+
+// Load reads the embedded templates, applies overrides from overrideDir
+// (if not empty) and validates the result.
+func Load(overrideDir string) (*Registry, error) {
+	registry := &Registry{templates: map[string]*Template{}}
+	for _, l := range locale.Supported {
+		if err := registry.loadFS(embedded, "templates/"+string(l), "embedded", l); err != nil {
+			return nil, err
+		}
+	}
+	if overrideDir != "" {
+		overrides := os.DirFS(overrideDir)
+		if err := registry.loadFS(overrides, ".", overrideDir, locale.Default); err != nil {
+			return nil, err
+		}
+		for _, l := range locale.Supported {
+			if err := registry.loadFS(overrides, string(l), overrideDir, l); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := registry.Validate(); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+func (r *Registry) loadFS(fsys fs.FS, dir, source string, l locale.Locale) error {
+	paths, err := fs.Glob(fsys, filepath.ToSlash(filepath.Join(dir, "*.tmpl")))
+	if err != nil {
+		return fmt.Errorf("failed to list prompt templates in %s: %w", source, err)
+	}
+	for _, path := range paths {
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt template %s: %w", path, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		parsed, err := parseTemplate(name, string(data))
+		if err != nil {
+			return fmt.Errorf("prompt template %s/%s (%s): %w", l, name, source, err)
+		}
+		parsed.Locale = l
+		if source != "embedded" {
+			parsed.Source = filepath.Join(source, path)
+			log.Printf("INFO: Prompt template %s/%s overridden by %s (version %s)", l, name, parsed.Source, parsed.Version)
+		}
+		r.templates[templateKey(l, name)] = parsed
+	}
+	return nil
+}
+
+// parseTemplate splits the front matter (a "version:" line between "---"
+// lines) from the body and parses the body.
+func parseTemplate(name, raw string) (*Template, error) {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	if !strings.HasPrefix(raw, "---\n") {
+		return nil, errors.New("missing front matter")
+	}
+	header, body, ok := strings.Cut(strings.TrimPrefix(raw, "---\n"), "\n---\n")
+	if !ok {
+		return nil, errors.New("unterminated front matter")
+	}
+	version := ""
+	for _, line := range strings.Split(header, "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "version" {
+			version = strings.TrimSpace(value)
+		}
+	}
+	if version == "" {
+		return nil, errors.New("missing version")
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{Name: name, Version: version, Source: "embedded", tmpl: tmpl}, nil
+}
+
+// Validate checks that every required template exists for every locale
+// and renders with sample data.
+func (r *Registry) Validate() error {
+	var problems []string
+	for _, l := range locale.Supported {
+		for name, sample := range required {
+			if _, _, err := r.render(l, name, sample); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid prompt templates: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// lookup finds a template in l, falling back to the default locale.
+func (r *Registry) lookup(l locale.Locale, name string) (*Template, bool) {
+	if tmpl, ok := r.templates[templateKey(l, name)]; ok {
+		return tmpl, true
+	}
+	tmpl, ok := r.templates[templateKey(locale.Default, name)]
+	return tmpl, ok
+}
+
+func (r *Registry) render(l locale.Locale, name string, data any) (string, string, error) {
+	tmpl, ok := r.lookup(l, name)
+	if !ok {
+		return "", "", fmt.Errorf("prompt template %s/%s not found", l, name)
+	}
+	var buffer bytes.Buffer
+	if err := tmpl.tmpl.Execute(&buffer, data); err != nil {
+		return "", "", fmt.Errorf("prompt template %s/%s: %w", tmpl.Locale, name, err)
+	}
+	return strings.TrimSpace(buffer.String()), tmpl.Version, nil
+}
+
+// Render executes a template in the default locale and returns the prompt
+// and the template version.
+func (r *Registry) Render(name string, data any) (string, string, error) {
+	return r.render(locale.Default, name, data)
+}
+
+// ValidateVariant checks that variant exists and renders, in every locale,
+// with the sample data of the base template it stands in for.
+func (r *Registry) ValidateVariant(base, variant string) error {
+	sample, ok := required[base]
+	if !ok {
+		return fmt.Errorf("prompt template %s cannot have variants", base)
+	}
+	for _, l := range locale.Supported {
+		if _, _, err := r.render(l, variant, sample); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type overridesKey struct{}
+
+// WithOverrides returns a context under which RenderContext renders the
+// mapped variant template in place of each base template name.
+func WithOverrides(ctx context.Context, overrides map[string]string) context.Context {
+	if len(overrides) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, overridesKey{}, overrides)
+}
+
+func resolve(ctx context.Context, name string) string {
+	if overrides, ok := ctx.Value(overridesKey{}).(map[string]string); ok {
+		if variant, ok := overrides[name]; ok {
+			return variant
+		}
+	}
+	return name
+}
+
+// RenderContext renders a template in the context's locale, honoring its
+// variant overrides.
+func (r *Registry) RenderContext(ctx context.Context, name string, data any) (string, string, error) {
+	return r.render(locale.FromContext(ctx).Locale, resolve(ctx, name), data)
+}
+
+// VersionContext is Version in the context's locale, honoring its variant overrides.
+func (r *Registry) VersionContext(ctx context.Context, name string) string {
+	if tmpl, ok := r.lookup(locale.FromContext(ctx).Locale, resolve(ctx, name)); ok {
+		return tmpl.Version
+	}
+	return ""
+}
+
+// Version returns the version of a template in the default locale, or "" if it does not exist.
+func (r *Registry) Version(name string) string {
+	if tmpl, ok := r.lookup(locale.Default, name); ok {
+		return tmpl.Version
+	}
+	return ""
+}
+
+// Versions returns the version of every template, keyed by locale/name.
+func (r *Registry) Versions() map[string]string {
+	versions := make(map[string]string, len(r.templates))
+	for key, tmpl := range r.templates {
+		versions[key] = tmpl.Version
+	}
+	return versions
+}
+
+var (
+	defaultMu       sync.Mutex
+	defaultRegistry *Registry
+)
+
+// Init loads the registry with PROMPT_TEMPLATE_DIR overrides and makes it
+// the default. It is called at startup so broken templates stop the server.
+func Init() error {
+	registry, err := Load(strings.TrimSpace(os.Getenv("PROMPT_TEMPLATE_DIR")))
+	if err != nil {
+		return err
+	}
+	defaultMu.Lock()
+	defaultRegistry = registry
+	defaultMu.Unlock()
+	return nil
+}
+
+// Default returns the registry set by Init, or the embedded templates if
+// Init has not run (e.g. in tests).
+func Default() *Registry {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultRegistry == nil {
+		registry, err := Load("")
+		if err != nil {
+			// The embedded templates are covered by tests, so this is a build defect.
+			panic(err)
+		}
+		defaultRegistry = registry
+	}
+	return defaultRegistry
+}
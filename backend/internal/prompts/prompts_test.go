@@ -0,0 +1,189 @@
+package prompts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	registry, err := Load("")
+	if err != nil {
+		t.Fatalf("embedded templates are invalid: %v", err)
+	}
+	for name := range required {
+		if registry.Version(name) == "" {
+			t.Errorf("%s has no version", name)
+		}
+	}
+
+	text, version, err := registry.Render(EnhancementClean, EnhancementData{Analysis: "露出を上げる", Strict: true})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if version != "enhancement-clean-v4" || !strings.Contains(text, "露出を上げる") || !strings.Contains(text, "厳守事項") {
+		t.Fatalf("unexpected render %s: %s", version, text)
+	}
+	if strings.Contains(text, "追加の要望") {
+		t.Fatal("empty custom notes were rendered")
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  string
+	}{
+		{name: "valid", contents: "---\nversion: compare-test\n---\n比較: {{.Analysis}}"},
+		{name: "missing version", contents: "---\nauthor: me\n---\n比較", wantErr: "missing version"},
+		{name: "missing front matter", contents: "比較: {{.Analysis}}", wantErr: "missing front matter"},
+		{name: "unknown field", contents: "---\nversion: compare-test\n---\n{{.Photo}}", wantErr: "Photo"},
+		{name: "parse error", contents: "---\nversion: compare-test\n---\n{{if .Analysis}}", wantErr: "compare"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "compare.tmpl"), []byte(tt.contents), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			registry, err := Load(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			text, version, err := registry.Render(Compare, CompareData{Analysis: "良い"})
+			if err != nil || version != "compare-test" || text != "比較: 良い" {
+				t.Fatalf("render = %q, %q, %v", text, version, err)
+			}
+			if registry.Version(Analysis) == "" {
+				t.Fatal("embedded templates were not kept alongside the override")
+			}
+		})
+	}
+}
+
+func TestRenderContextLocale(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "en"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A variant that only exists in the default locale is used for every locale.
+	if err := os.WriteFile(filepath.Join(dir, "compare.short.tmpl"), []byte("---\nversion: compare-short\n---\n短く: {{.Analysis}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	english := locale.WithSettings(context.Background(), locale.Settings{Locale: locale.English})
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		template    string
+		wantVersion string
+	}{
+		{name: "default locale", ctx: context.Background(), template: Analysis, wantVersion: "analysis-v7"},
+		{name: "english", ctx: english, template: Analysis, wantVersion: "analysis-en-v7"},
+		{name: "english variant falls back", ctx: WithOverrides(english, map[string]string{Compare: "compare.short"}), template: Compare, wantVersion: "compare-short"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data any = AnalysisData{}
+			if tt.template == Compare {
+				data = CompareData{Analysis: "x"}
+			}
+			_, version, err := registry.RenderContext(tt.ctx, tt.template, data)
+			if err != nil || version != tt.wantVersion {
+				t.Fatalf("version = %q, %v; want %q", version, err, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestSkillLevelShapesPrompts(t *testing.T) {
+	registry, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	english := locale.WithSettings(context.Background(), locale.Settings{Locale: locale.English})
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		template string
+		data     any
+		want     string
+	}{
+		{name: "beginner analysis", ctx: context.Background(), template: Analysis, data: AnalysisData{SkillLevel: "beginner"}, want: "寛容に"},
+		{name: "professional analysis", ctx: english, template: Analysis, data: AnalysisData{SkillLevel: "professional"}, want: "strictest standard"},
+		{name: "advanced agent", ctx: context.Background(), template: AgentSystem, data: AgentSystemData{SkillLevel: "advanced"}, want: "ユーザーは上級者です"},
+		{name: "beginner agent", ctx: english, template: AgentSystem, data: AgentSystemData{SkillLevel: "beginner"}, want: "The user is a beginner"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, _, err := registry.RenderContext(tt.ctx, tt.template, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(text, tt.want) {
+				t.Errorf("prompt lacks %q:\n%s", tt.want, text)
+			}
+		})
+	}
+
+	unknown, _, err := registry.Render(AgentSystem, AgentSystemData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(unknown, "ユーザーのレベル") {
+		t.Error("agent prompt describes a level when none is known")
+	}
+}
+
+func TestPurposeShapesPrompts(t *testing.T) {
+	registry, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	english := locale.WithSettings(context.Background(), locale.Settings{Locale: locale.English})
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		template string
+		data     any
+		want     []string
+		dontWant string
+	}{
+		{name: "print analysis", ctx: context.Background(), template: Analysis, data: AnalysisData{Purpose: "print", PrintSize: "300dpi: 50.8×33.9cm"}, want: []string{"プリントを想定", "300dpi: 50.8×33.9cm"}},
+		{name: "social analysis", ctx: english, template: Analysis, data: AnalysisData{Purpose: "social"}, want: []string{"safe area"}, dontWant: "photo contest"},
+		{name: "portfolio analysis", ctx: context.Background(), template: Analysis, data: AnalysisData{Purpose: "portfolio"}, want: []string{"作家性", "一貫性については評価しない"}},
+		{name: "contest enhancement", ctx: context.Background(), template: EnhancementClean, data: EnhancementData{Purpose: "contest"}, want: []string{"コンテスト受賞レベル"}},
+		{name: "portfolio enhancement", ctx: english, template: EnhancementAnnotated, data: EnhancementData{Purpose: "portfolio"}, want: []string{"selected work"}, dontWant: "contest-winning"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, _, err := registry.RenderContext(tt.ctx, tt.template, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("prompt lacks %q:\n%s", want, text)
+				}
+			}
+			if tt.dontWant != "" && strings.Contains(text, tt.dontWant) {
+				t.Errorf("prompt still mentions %q:\n%s", tt.dontWant, text)
+			}
+		})
+	}
+}
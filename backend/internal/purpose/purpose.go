@@ -0,0 +1,88 @@
+// Package purpose holds what a photo is meant for, which decides what a
+// good photo is: contest impact, readability in a social feed, print
+// quality or a finish strong enough for a curated portfolio.
+package purpose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Purpose is the intended use of a photo.
+type Purpose string
+
+const (
+	Contest   Purpose = "contest"
+	Social    Purpose = "social"
+	Print     Purpose = "print"
+	Portfolio Purpose = "portfolio"
+	// Default is the purpose every prompt assumed before purposes existed.
+	Default = Contest
+)
+
+// Purposes lists every purpose in display order.
+var Purposes = []Purpose{Contest, Social, Print, Portfolio}
+
+// Parse accepts a purpose name in any case.
+func Parse(raw string) (Purpose, bool) {
+	p := Purpose(strings.ToLower(strings.TrimSpace(raw)))
+	for _, known := range Purposes {
+		if p == known {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// printResolutions are the pixel densities a print size is reported at:
+// fine viewing distance and a comfortable minimum.
+var printResolutions = []int{300, 200}
+
+// PrintSize describes the largest print of a width × height pixel image at
+// each print resolution, e.g. "300dpi: 34.0×22.6cm / 200dpi: 50.9×33.9cm".
+func PrintSize(width, height int) string {
+	if width <= 0 || height <= 0 {
+		return ""
+	}
+	var sizes []string
+	for _, dpi := range printResolutions {
+		sizes = append(sizes, fmt.Sprintf("%ddpi: %.1f×%.1fcm", dpi, float64(width)/float64(dpi)*2.54, float64(height)/float64(dpi)*2.54))
+	}
+	return strings.Join(sizes, " / ")
+}
+
+type purposeKey struct{}
+
+// WithPurpose makes analyses and enhancements under ctx target p.
+func WithPurpose(ctx context.Context, p Purpose) context.Context {
+	return context.WithValue(ctx, purposeKey{}, p)
+}
+
+// FromContext returns the purpose set on ctx, or Default.
+func FromContext(ctx context.Context) Purpose {
+	if p, ok := ctx.Value(purposeKey{}).(Purpose); ok && p != "" {
+		return p
+	}
+	return Default
+}
+
+type sourceSizeKey struct{}
+
+// SourceSize is the pixel size of the photo as uploaded, before it was
+// resized for the model.
+type SourceSize struct {
+	Width  int
+	Height int
+}
+
+// WithSourceSize records the uploaded photo's pixel size on ctx.
+func WithSourceSize(ctx context.Context, width, height int) context.Context {
+	return context.WithValue(ctx, sourceSizeKey{}, SourceSize{Width: width, Height: height})
+}
+
+// SourceSizeFromContext returns the uploaded photo's pixel size, if known.
+func SourceSizeFromContext(ctx context.Context) (SourceSize, bool) {
+	size, ok := ctx.Value(sourceSizeKey{}).(SourceSize)
+	return size, ok && size.Width > 0 && size.Height > 0
+}
@@ -0,0 +1,40 @@
+package purpose
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	if p, ok := Parse(" Social "); !ok || p != Social {
+		t.Errorf("Parse(Social) = %q, %v", p, ok)
+	}
+	if _, ok := Parse("billboard"); ok {
+		t.Error("Parse accepted an unknown purpose")
+	}
+}
+
+func TestPrintSize(t *testing.T) {
+	if got, want := PrintSize(6000, 4000), "300dpi: 50.8×33.9cm / 200dpi: 76.2×50.8cm"; got != want {
+		t.Errorf("PrintSize = %q, want %q", got, want)
+	}
+	if got := PrintSize(0, 4000); got != "" {
+		t.Errorf("PrintSize without width = %q", got)
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if p := FromContext(ctx); p != Default {
+		t.Errorf("default purpose = %q", p)
+	}
+	if p := FromContext(WithPurpose(ctx, Portfolio)); p != Portfolio {
+		t.Errorf("purpose = %q, want portfolio", p)
+	}
+	if _, ok := SourceSizeFromContext(ctx); ok {
+		t.Error("source size known on an empty context")
+	}
+	if size, ok := SourceSizeFromContext(WithSourceSize(ctx, 4000, 3000)); !ok || size.Width != 4000 || size.Height != 3000 {
+		t.Errorf("source size = %+v, %v", size, ok)
+	}
+}
@@ -0,0 +1,113 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const countersCollection = "quota_counters"
+
+// FirestoreStore is a CounterStore backed by Cloud Firestore, one document
+// per user and day. Reservations run in transactions, so they are atomic
+// across instances. Documents carry expireAt for a Firestore TTL policy.
+type FirestoreStore struct {
+	client *firestore.Client
+}
+
+type counterDocument struct {
+	UserID   string             `firestore:"userId"`
+	Day      string             `firestore:"day"`
+	Counters map[string]float64 `firestore:"counters"`
+	ExpireAt time.Time          `firestore:"expireAt"`
+}
+
+// NewFirestoreStore creates a Firestore-backed counter store.
+func NewFirestoreStore(ctx context.Context, projectID string) (*FirestoreStore, error) {
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	return &FirestoreStore{client: client}, nil
+}
+
+// Close closes the Firestore client.
+func (s *FirestoreStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *FirestoreStore) docRef(userID, day string) *firestore.DocumentRef {
+	return s.client.Collection(countersCollection).Doc(fmt.Sprintf("%s_%s", userID, day))
+}
+
+// read loads a counter document inside a transaction; missing documents are empty.
+func (s *FirestoreStore) read(tx *firestore.Transaction, docRef *firestore.DocumentRef, userID, day string) (*counterDocument, error) {
+	document := &counterDocument{UserID: userID, Day: day, Counters: map[string]float64{}, ExpireAt: time.Now().AddDate(0, 0, 3)}
+	snapshot, err := tx.Get(docRef)
+	if status.Code(err) == codes.NotFound {
+		return document, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quota counters: %w", err)
+	}
+	if err := snapshot.DataTo(document); err != nil {
+		return nil, fmt.Errorf("failed to decode quota counters: %w", err)
+	}
+	if document.Counters == nil {
+		document.Counters = map[string]float64{}
+	}
+	return document, nil
+}
+
+func (s *FirestoreStore) Reserve(ctx context.Context, userID, day string, amounts map[string]float64, limits Limits) (string, error) {
+	docRef := s.docRef(userID, day)
+	exceeded := ""
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		exceeded = ""
+		document, err := s.read(tx, docRef, userID, day)
+		if err != nil {
+			return err
+		}
+		if exceeded = firstExceeded(document.Counters, amounts, limits); exceeded != "" {
+			return nil
+		}
+		for counter, amount := range amounts {
+			document.Counters[counter] += amount
+		}
+		return tx.Set(docRef, document)
+	})
+	return exceeded, err
+}
+
+func (s *FirestoreStore) Add(ctx context.Context, userID, day string, amounts map[string]float64) error {
+	docRef := s.docRef(userID, day)
+	return s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		document, err := s.read(tx, docRef, userID, day)
+		if err != nil {
+			return err
+		}
+		for counter, amount := range amounts {
+			document.Counters[counter] += amount
+		}
+		return tx.Set(docRef, document)
+	})
+}
+
+func (s *FirestoreStore) Get(ctx context.Context, userID, day string) (map[string]float64, error) {
+	snapshot, err := s.docRef(userID, day).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return map[string]float64{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quota counters: %w", err)
+	}
+	var document counterDocument
+	if err := snapshot.DataTo(&document); err != nil {
+		return nil, fmt.Errorf("failed to decode quota counters: %w", err)
+	}
+	return document.Counters, nil
+}
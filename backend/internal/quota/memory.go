@@ -0,0 +1,65 @@
+package quota
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore is an in-process CounterStore; counters of past days are dropped.
+type MemoryStore struct {
+	mu    sync.Mutex
+	users map[string]*dailyCounters
+}
+
+type dailyCounters struct {
+	day      string
+	counters map[string]float64
+}
+
+// NewMemoryStore creates an empty in-memory counter store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{users: map[string]*dailyCounters{}}
+}
+
+// countersFor returns the user's counters for day; the caller holds mu.
+func (m *MemoryStore) countersFor(userID, day string) map[string]float64 {
+	entry, ok := m.users[userID]
+	if !ok || entry.day != day {
+		entry = &dailyCounters{day: day, counters: map[string]float64{}}
+		m.users[userID] = entry
+	}
+	return entry.counters
+}
+
+func (m *MemoryStore) Reserve(ctx context.Context, userID, day string, amounts map[string]float64, limits Limits) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counters := m.countersFor(userID, day)
+	if exceeded := firstExceeded(counters, amounts, limits); exceeded != "" {
+		return exceeded, nil
+	}
+	for counter, amount := range amounts {
+		counters[counter] += amount
+	}
+	return "", nil
+}
+
+func (m *MemoryStore) Add(ctx context.Context, userID, day string, amounts map[string]float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counters := m.countersFor(userID, day)
+	for counter, amount := range amounts {
+		counters[counter] += amount
+	}
+	return nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, userID, day string) (map[string]float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := map[string]float64{}
+	for counter, value := range m.countersFor(userID, day) {
+		copied[counter] = value
+	}
+	return copied, nil
+}
@@ -0,0 +1,212 @@
+// Package quota enforces per-user daily limits on analyses, image
+// generations, chat turns and spend.
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+)
+
+// Daily counters tracked per user.
+const (
+	CounterAnalyses         = "analyses"
+	CounterImageGenerations = "imageGenerations"
+	CounterChatTurns        = "chatTurns"
+	CounterCostUSD          = "costUsd"
+)
+
+// Action is a user request that consumes quota.
+type Action string
+
+const (
+	ActionAnalysis Action = "analysis"
+	ActionChat     Action = "chat"
+)
+
+// reservations is what each action reserves up front. An analysis reserves
+// its clean enhancement; further generations are counted when it settles.
+var reservations = map[Action]map[string]float64{
+	ActionAnalysis: {CounterAnalyses: 1, CounterImageGenerations: 1, CounterCostUSD: 0},
+	ActionChat:     {CounterChatTurns: 1, CounterCostUSD: 0},
+}
+
+// Limits are the per-user daily caps; zero means unlimited.
+type Limits map[string]float64
+
+// LimitsFromEnv reads QUOTA_DAILY_ANALYSES, QUOTA_DAILY_IMAGE_GENERATIONS,
+// QUOTA_DAILY_CHAT_TURNS and QUOTA_DAILY_BUDGET_USD.
+func LimitsFromEnv() Limits {
+	limits := Limits{}
+	for counter, key := range map[string]string{
+		CounterAnalyses:         "QUOTA_DAILY_ANALYSES",
+		CounterImageGenerations: "QUOTA_DAILY_IMAGE_GENERATIONS",
+		CounterChatTurns:        "QUOTA_DAILY_CHAT_TURNS",
+		CounterCostUSD:          "QUOTA_DAILY_BUDGET_USD",
+	} {
+		raw := strings.TrimSpace(os.Getenv(key))
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value < 0 {
+			log.Printf("WARN: Invalid %s %q, leaving it unlimited", key, raw)
+			continue
+		}
+		limits[counter] = value
+	}
+	return limits
+}
+
+// CounterStore keeps the daily counters. Reserve must be atomic across
+// concurrent callers and processes.
+type CounterStore interface {
+	// Reserve adds amounts to the user's counters for day only if no counter
+	// would exceed its limit; otherwise nothing changes and the first exceeded
+	// counter is returned.
+	Reserve(ctx context.Context, userID, day string, amounts map[string]float64, limits Limits) (exceeded string, err error)
+	// Add adds amounts unconditionally, e.g. the actual cost of a finished request.
+	Add(ctx context.Context, userID, day string, amounts map[string]float64) error
+	// Get returns the user's counters for day.
+	Get(ctx context.Context, userID, day string) (map[string]float64, error)
+}
+
+// exceeds reports whether adding amount to current breaks limit. A zero
+// amount checks that the counter has not already reached its limit, which
+// is how spend is gated before its cost is known.
+func exceeds(current, amount, limit float64) bool {
+	if limit <= 0 {
+		return false
+	}
+	if amount == 0 {
+		return current >= limit
+	}
+	return current+amount > limit
+}
+
+// firstExceeded returns the first counter in amounts that would break its limit.
+func firstExceeded(counters, amounts map[string]float64, limits Limits) string {
+	for _, counter := range []string{CounterAnalyses, CounterImageGenerations, CounterChatTurns, CounterCostUSD} {
+		amount, ok := amounts[counter]
+		if ok && exceeds(counters[counter], amount, limits[counter]) {
+			return counter
+		}
+	}
+	return ""
+}
+
+// ExceededError is returned when a request would break a daily limit.
+type ExceededError struct {
+	Counter string
+	Limit   float64
+	ResetAt time.Time
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("daily %s limit of %g reached; resets at %s", e.Counter, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+// Enforcer checks and records quota usage.
+type Enforcer struct {
+	store    CounterStore
+	limits   Limits
+	location *time.Location
+	now      func() time.Time
+}
+
+// NewEnforcer creates an enforcer; days roll over at midnight in QUOTA_TIMEZONE (default Asia/Tokyo).
+func NewEnforcer(store CounterStore, limits Limits) *Enforcer {
+	name := strings.TrimSpace(os.Getenv("QUOTA_TIMEZONE"))
+	if name == "" {
+		name = "Asia/Tokyo"
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("WARN: Invalid QUOTA_TIMEZONE %q, using UTC: %v", name, err)
+		location = time.UTC
+	}
+	return &Enforcer{store: store, limits: limits, location: location, now: time.Now}
+}
+
+func (e *Enforcer) day() (string, time.Time) {
+	now := e.now().In(e.location)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, e.location)
+	return midnight.Format("2006-01-02"), midnight.AddDate(0, 0, 1)
+}
+
+// Reserve claims quota for action before any work starts. It returns an
+// *ExceededError when a limit has been reached.
+func (e *Enforcer) Reserve(ctx context.Context, userID string, action Action) error {
+	amounts, ok := reservations[action]
+	if !ok {
+		return fmt.Errorf("unknown quota action: %s", action)
+	}
+	day, resetAt := e.day()
+	exceeded, err := e.store.Reserve(ctx, userID, day, amounts, e.limits)
+	if err != nil {
+		return fmt.Errorf("quota check failed: %w", err)
+	}
+	if exceeded != "" {
+		return &ExceededError{Counter: exceeded, Limit: e.limits[exceeded], ResetAt: resetAt}
+	}
+	return nil
+}
+
+// Release returns the reservation of an action that failed before any
+// billable work started. A request that fails across midnight is refunded
+// from the new day.
+func (e *Enforcer) Release(ctx context.Context, userID string, action Action) {
+	amounts := map[string]float64{}
+	for counter, amount := range reservations[action] {
+		if amount > 0 {
+			amounts[counter] = -amount
+		}
+	}
+	if len(amounts) == 0 {
+		return
+	}
+	day, _ := e.day()
+	if err := e.store.Add(ctx, userID, day, amounts); err != nil {
+		log.Printf("WARN: Failed to release %s quota for user %s: %v", action, userID, err)
+	}
+}
+
+// Settle records what a finished action actually used: its cost and any
+// image generations beyond the reserved one.
+func (e *Enforcer) Settle(ctx context.Context, userID string, action Action, report services.UsageReport) {
+	amounts := map[string]float64{}
+	if report.Total.CostUSD > 0 {
+		amounts[CounterCostUSD] = report.Total.CostUSD
+	}
+	if extra := float64(report.Total.Images) - reservations[action][CounterImageGenerations]; extra > 0 {
+		amounts[CounterImageGenerations] = extra
+	}
+	if len(amounts) == 0 {
+		return
+	}
+	day, _ := e.day()
+	if err := e.store.Add(ctx, userID, day, amounts); err != nil {
+		log.Printf("WARN: Failed to settle %s quota for user %s: %v", action, userID, err)
+	}
+}
+
+// Status returns the user's counters for today with the limits and reset time.
+func (e *Enforcer) Status(ctx context.Context, userID string) (map[string]float64, Limits, time.Time, error) {
+	day, resetAt := e.day()
+	counters, err := e.store.Get(ctx, userID, day)
+	return counters, e.limits, resetAt, err
+}
+
+// AsExceeded unwraps an *ExceededError.
+func AsExceeded(err error) (*ExceededError, bool) {
+	var exceeded *ExceededError
+	ok := errors.As(err, &exceeded)
+	return exceeded, ok
+}
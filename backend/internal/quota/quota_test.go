@@ -0,0 +1,100 @@
+package quota
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+)
+
+func TestReserveIsAtomic(t *testing.T) {
+	enforcer := NewEnforcer(NewMemoryStore(), Limits{CounterAnalyses: 10})
+
+	var granted atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if enforcer.Reserve(context.Background(), "user", ActionAnalysis) == nil {
+				granted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if granted.Load() != 10 {
+		t.Fatalf("granted %d analyses, want 10", granted.Load())
+	}
+}
+
+func TestReserveLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  Limits
+		spent   services.UsageReport
+		action  Action
+		wantErr string
+	}{
+		{name: "unlimited", limits: Limits{}, action: ActionAnalysis},
+		{name: "budget left", limits: Limits{CounterCostUSD: 1}, spent: usageCosting(0.5), action: ActionChat},
+		{name: "budget spent", limits: Limits{CounterCostUSD: 1}, spent: usageCosting(1), action: ActionChat, wantErr: CounterCostUSD},
+		{name: "chat turns", limits: Limits{CounterChatTurns: 1}, action: ActionChat, wantErr: CounterChatTurns},
+		{name: "extra generations", limits: Limits{CounterImageGenerations: 2}, spent: services.UsageReport{Total: services.UsageTotals{Images: 2}}, action: ActionAnalysis, wantErr: CounterImageGenerations},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			enforcer := NewEnforcer(NewMemoryStore(), tt.limits)
+			enforcer.location = time.UTC
+			enforcer.now = func() time.Time { return time.Date(2026, 3, 1, 15, 0, 0, 0, time.UTC) }
+
+			if err := enforcer.Reserve(ctx, "user", tt.action); err != nil {
+				t.Fatalf("first reserve: %v", err)
+			}
+			enforcer.Settle(ctx, "user", tt.action, tt.spent)
+
+			err := enforcer.Reserve(ctx, "user", tt.action)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("second reserve: %v", err)
+				}
+				return
+			}
+			exceeded, ok := AsExceeded(err)
+			if !ok || exceeded.Counter != tt.wantErr {
+				t.Fatalf("second reserve error = %v, want %s exceeded", err, tt.wantErr)
+			}
+			if want := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC); !exceeded.ResetAt.Equal(want) {
+				t.Fatalf("resetAt = %s, want %s", exceeded.ResetAt, want)
+			}
+		})
+	}
+}
+
+func usageCosting(costUSD float64) services.UsageReport {
+	return services.UsageReport{Total: services.UsageTotals{Calls: 1, CostUSD: costUSD}}
+}
+
+func TestReleaseRefundsReservation(t *testing.T) {
+	ctx := context.Background()
+	enforcer := NewEnforcer(NewMemoryStore(), Limits{CounterAnalyses: 1})
+	if err := enforcer.Reserve(ctx, "user", ActionAnalysis); err != nil {
+		t.Fatal(err)
+	}
+	enforcer.Release(ctx, "user", ActionAnalysis)
+	counters, _, _, err := enforcer.Status(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counters[CounterAnalyses] != 0 || counters[CounterImageGenerations] != 0 {
+		t.Errorf("counters after release = %v, want zero", counters)
+	}
+	if err := enforcer.Reserve(ctx, "user", ActionAnalysis); err != nil {
+		t.Errorf("reserve after release: %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package rubric
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+)
+
+//go:embed rubrics/*.yaml
+var embedded embed.FS
+
+// maxCategories bounds a rubric so the response schema stays manageable.
+const maxCategories = 20
+
+// categoryKeyPattern keeps keys usable as JSON schema property names.
+var categoryKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// Registry holds the loaded rubrics by ID and by genre.
+type Registry struct {
+	rubrics map[string]Rubric
+	byGenre map[Genre]Rubric
+	order   []string
+}
+
+// Load reads the embedded rubrics and the .json, .yaml and .yml files in
+// dir (if not empty), resolves "extends" and validates the result. A file
+// with the ID of a built-in rubric replaces it.
+func Load(dir string) (*Registry, error) {
+	var documents []Rubric
+	builtIn, err := loadFS(embedded, "rubrics", "embedded")
+	if err != nil {
+		return nil, err
+	}
+	documents = append(documents, builtIn...)
+	if dir != "" {
+		custom, err := loadFS(os.DirFS(dir), ".", dir)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, custom...)
+	}
+
+	raw := map[string]Rubric{}
+	var order []string
+	for _, document := range documents {
+		if _, ok := raw[document.ID]; !ok {
+			order = append(order, document.ID)
+		} else if document.Source != "embedded" {
+			log.Printf("INFO: Rubric %s overridden by %s", document.ID, document.Source)
+		}
+		raw[document.ID] = document
+	}
+
+	registry := &Registry{rubrics: map[string]Rubric{}, byGenre: map[Genre]Rubric{}, order: order}
+	var problems []string
+	for _, id := range order {
+		resolved, err := resolveExtends(raw, id, nil)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if err := resolved.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("rubric %s (%s): %v", id, resolved.Source, err))
+			continue
+		}
+		registry.rubrics[id] = resolved
+		if resolved.Genre != "" {
+			registry.byGenre[resolved.Genre] = resolved
+		}
+	}
+	for _, genre := range Genres {
+		if _, ok := registry.byGenre[genre]; !ok {
+			problems = append(problems, fmt.Sprintf("no rubric for genre %s", genre))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("invalid rubrics: %s", strings.Join(problems, "; "))
+	}
+	return registry, nil
+}
+
+func loadFS(fsys fs.FS, dir, source string) ([]Rubric, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rubrics in %s: %w", source, err)
+	}
+	var documents []Rubric
+	for _, entry := range entries {
+		extension := strings.ToLower(path.Ext(entry.Name()))
+		if entry.IsDir() || (extension != ".json" && extension != ".yaml" && extension != ".yml") {
+			continue
+		}
+		filePath := path.Join(dir, entry.Name())
+		data, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rubric %s: %w", filePath, err)
+		}
+		document, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("rubric %s (%s): %w", filePath, source, err)
+		}
+		document.Source = "embedded"
+		if source != "embedded" {
+			document.Source = path.Join(source, filePath)
+		}
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+// Parse decodes a rubric document. JSON is valid YAML, so both go through
+// the YAML decoder and then the JSON field mapping; unknown fields are errors.
+func Parse(data []byte) (Rubric, error) {
+	var generic any
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return Rubric{}, err
+	}
+	normalized, err := json.Marshal(generic)
+	if err != nil {
+		return Rubric{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+	var document Rubric
+	if err := decoder.Decode(&document); err != nil {
+		return Rubric{}, err
+	}
+	if strings.TrimSpace(document.ID) == "" {
+		return Rubric{}, errors.New("missing id")
+	}
+	return document, nil
+}
+
+// resolveExtends merges a rubric onto the rubric it extends, recursively.
+func resolveExtends(raw map[string]Rubric, id string, seen []string) (Rubric, error) {
+	for _, visited := range seen {
+		if visited == id {
+			return Rubric{}, fmt.Errorf("rubric %s: extends cycle %s", id, strings.Join(append(seen, id), " -> "))
+		}
+	}
+	document, ok := raw[id]
+	if !ok {
+		return Rubric{}, fmt.Errorf("rubric %s: extends unknown rubric %s", seen[len(seen)-1], id)
+	}
+	if document.Extends == "" {
+		return document, nil
+	}
+	base, err := resolveExtends(raw, document.Extends, append(seen, id))
+	if err != nil {
+		return Rubric{}, err
+	}
+
+	merged := document
+	if merged.Scale == (Scale{}) {
+		merged.Scale = base.Scale
+	}
+	merged.Categories = append([]Category(nil), base.Categories...)
+	for _, category := range document.Categories {
+		replaced := false
+		for i, inherited := range merged.Categories {
+			if inherited.Key != category.Key {
+				continue
+			}
+			if len(category.Name) == 0 {
+				category.Name = inherited.Name
+			}
+			if len(category.Description) == 0 {
+				category.Description = inherited.Description
+			}
+			merged.Categories[i] = category
+			replaced = true
+			break
+		}
+		if !replaced {
+			merged.Categories = append(merged.Categories, category)
+		}
+	}
+	return merged, nil
+}
+
+// Validate checks the scale, the category keys and names, and the weights.
+func (r Rubric) Validate() error {
+	var problems []string
+	if r.Name.In(locale.Default) == "" {
+		problems = append(problems, fmt.Sprintf("missing %s name", locale.Default))
+	}
+	if r.Genre != "" {
+		if _, ok := ParseGenre(string(r.Genre)); !ok {
+			problems = append(problems, fmt.Sprintf("unknown genre %q", r.Genre))
+		}
+	}
+	if r.Scale.Max <= r.Scale.Min || r.Scale.Min < 0 {
+		problems = append(problems, fmt.Sprintf("invalid scale %d-%d", r.Scale.Min, r.Scale.Max))
+	}
+	if len(r.Categories) == 0 || len(r.Categories) > maxCategories {
+		problems = append(problems, fmt.Sprintf("needs 1 to %d categories, has %d", maxCategories, len(r.Categories)))
+	}
+	seen := map[string]bool{}
+	for _, category := range r.Categories {
+		switch {
+		case !categoryKeyPattern.MatchString(category.Key):
+			problems = append(problems, fmt.Sprintf("invalid category key %q", category.Key))
+		case seen[category.Key]:
+			problems = append(problems, fmt.Sprintf("duplicate category %s", category.Key))
+		}
+		seen[category.Key] = true
+		if category.Name.In(locale.Default) == "" {
+			problems = append(problems, fmt.Sprintf("category %s is missing a %s name", category.Key, locale.Default))
+		}
+		if category.Weight < 0 {
+			problems = append(problems, fmt.Sprintf("category %s has a negative weight", category.Key))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// Get returns the rubric with the given ID.
+func (r *Registry) Get(id string) (Rubric, bool) {
+	rubric, ok := r.rubrics[id]
+	return rubric, ok
+}
+
+// ForGenre returns the rubric of a genre, or the general rubric.
+func (r *Registry) ForGenre(genre Genre) Rubric {
+	if rubric, ok := r.byGenre[genre]; ok {
+		return rubric
+	}
+	return r.byGenre[General]
+}
+
+// List returns every rubric in load order.
+func (r *Registry) List() []Rubric {
+	rubrics := make([]Rubric, 0, len(r.order))
+	for _, id := range r.order {
+		if rubric, ok := r.rubrics[id]; ok {
+			rubrics = append(rubrics, rubric)
+		}
+	}
+	return rubrics
+}
+
+var (
+	defaultMu       sync.Mutex
+	defaultRegistry *Registry
+)
+
+// Init loads the registry with the rubrics in RUBRIC_DIR and makes it the
+// default. It is called at startup so broken rubrics stop the server.
+func Init() error {
+	registry, err := Load(strings.TrimSpace(os.Getenv("RUBRIC_DIR")))
+	if err != nil {
+		return err
+	}
+	defaultMu.Lock()
+	defaultRegistry = registry
+	defaultMu.Unlock()
+	return nil
+}
+
+// Default returns the registry set by Init, or the built-in rubrics if
+// Init has not run (e.g. in tests).
+func Default() *Registry {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultRegistry == nil {
+		registry, err := Load("")
+		if err != nil {
+			// The built-in rubrics are covered by tests, so this is a build defect.
+			panic(err)
+		}
+		defaultRegistry = registry
+	}
+	return defaultRegistry
+}
@@ -0,0 +1,165 @@
+// Package rubric holds the scoring rubrics: named documents listing the
+// categories a photo is scored on, their descriptions and weights, and the
+// scoring scale. Built-in rubrics cover each photo genre; clubs and
+// contests add their own judging sheets as JSON or YAML files.
+package rubric
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+)
+
+// Genre is a photo genre detected by the classification pass or chosen by the user.
+type Genre string
+
+const (
+	General      Genre = "general"
+	Portrait     Genre = "portrait"
+	Landscape    Genre = "landscape"
+	Street       Genre = "street"
+	Macro        Genre = "macro"
+	Wildlife     Genre = "wildlife"
+	Night        Genre = "night"
+	Product      Genre = "product"
+	Architecture Genre = "architecture"
+)
+
+// Genres lists the genres the classifier chooses from, in display order.
+var Genres = []Genre{Portrait, Landscape, Street, Macro, Wildlife, Night, Product, Architecture, General}
+
+// ParseGenre accepts a genre name in any case.
+func ParseGenre(raw string) (Genre, bool) {
+	genre := Genre(strings.ToLower(strings.TrimSpace(raw)))
+	for _, known := range Genres {
+		if genre == known {
+			return genre, true
+		}
+	}
+	return "", false
+}
+
+// Text is a string given per locale. A plain string applies to every locale.
+type Text map[locale.Locale]string
+
+func (t *Text) UnmarshalJSON(data []byte) error {
+	var plain string
+	if err := json.Unmarshal(data, &plain); err == nil {
+		*t = Text{locale.Default: plain}
+		return nil
+	}
+	var localized map[locale.Locale]string
+	if err := json.Unmarshal(data, &localized); err != nil {
+		return err
+	}
+	*t = localized
+	return nil
+}
+
+// In returns the text in l, falling back to the default locale.
+func (t Text) In(l locale.Locale) string {
+	if text, ok := t[l]; ok && text != "" {
+		return text
+	}
+	return t[locale.Default]
+}
+
+// Scale is the inclusive range of every score in a rubric.
+type Scale struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// Category is one scored item of a rubric.
+type Category struct {
+	Key         string `json:"key"`
+	Name        Text   `json:"name"`
+	Description Text   `json:"description,omitempty"`
+	// Weight scales the category in the overall score; 0 means 1.
+	Weight float64 `json:"weight,omitempty"`
+}
+
+// Rubric is one scoring guide.
+type Rubric struct {
+	ID   string `json:"id"`
+	Name Text   `json:"name"`
+	// Genre makes this the rubric used for photos of that genre.
+	Genre Genre `json:"genre,omitempty"`
+	// Extends names a rubric whose scale and categories this one starts
+	// from; categories with the same key replace the inherited ones.
+	Extends    string     `json:"extends,omitempty"`
+	Scale      Scale      `json:"scale"`
+	Categories []Category `json:"categories"`
+	// Source is where the rubric was loaded from: "embedded" or a file path.
+	Source string `json:"-"`
+}
+
+// Keys returns the category keys in rubric order.
+func (r Rubric) Keys() []string {
+	keys := make([]string, 0, len(r.Categories))
+	for _, category := range r.Categories {
+		keys = append(keys, category.Key)
+	}
+	return keys
+}
+
+// Category returns the category with the given key.
+func (r Rubric) Category(key string) (Category, bool) {
+	for _, category := range r.Categories {
+		if category.Key == key {
+			return category, true
+		}
+	}
+	return Category{}, false
+}
+
+// Weight returns the weight of a category; unknown categories weigh 0.
+func (r Rubric) Weight(key string) float64 {
+	category, ok := r.Category(key)
+	if !ok {
+		return 0
+	}
+	if category.Weight == 0 {
+		return 1
+	}
+	return category.Weight
+}
+
+// Weighted reports whether any category weighs other than 1.
+func (r Rubric) Weighted() bool {
+	for _, category := range r.Categories {
+		if r.Weight(category.Key) != 1 {
+			return true
+		}
+	}
+	return false
+}
+
+type genreKey struct{}
+
+// WithGenre makes analyses under ctx use genre instead of detecting one.
+func WithGenre(ctx context.Context, genre Genre) context.Context {
+	return context.WithValue(ctx, genreKey{}, genre)
+}
+
+// GenreFromContext returns the genre chosen by the user, if any.
+func GenreFromContext(ctx context.Context) (Genre, bool) {
+	genre, ok := ctx.Value(genreKey{}).(Genre)
+	return genre, ok && genre != ""
+}
+
+type idKey struct{}
+
+// WithID makes analyses under ctx use the rubric with the given ID
+// regardless of the photo's genre.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// IDFromContext returns the rubric chosen by the user, if any.
+func IDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(idKey{}).(string)
+	return id, ok && id != ""
+}
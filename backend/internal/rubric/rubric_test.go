@@ -0,0 +1,97 @@
+package rubric
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+)
+
+func TestBuiltInRubrics(t *testing.T) {
+	registry, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, genre := range Genres {
+		r := registry.ForGenre(genre)
+		if r.Genre != genre {
+			t.Errorf("%s: got rubric %s", genre, r.ID)
+		}
+		for _, l := range locale.Supported {
+			for _, category := range r.Categories {
+				if category.Name[l] == "" || category.Description[l] == "" {
+					t.Errorf("%s/%s lacks a %s name or description", r.ID, category.Key, l)
+				}
+			}
+		}
+	}
+
+	portrait := registry.ForGenre(Portrait)
+	if len(portrait.Categories) != 10 || portrait.Weight("focus") != 1.5 || portrait.Weight("color") != 1 || portrait.Scale.Max != 10 {
+		t.Errorf("portrait did not extend general: %+v", portrait)
+	}
+}
+
+func TestLoadCustomRubrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "yaml club sheet",
+			file: "club.yaml",
+			content: `id: club-2026
+name: {ja: 写真部審査シート, en: Club judging sheet}
+scale: {min: 1, max: 5}
+categories:
+  - key: impact
+    name: インパクト
+    weight: 2
+  - key: technique
+    name: 技術
+`,
+		},
+		{
+			name:    "json extending general",
+			file:    "club.json",
+			content: `{"id": "club-2026", "name": "写真部", "extends": "general-v1", "categories": [{"key": "composition", "weight": 3}]}`,
+		},
+		{name: "unknown field", file: "club.json", content: `{"id": "club", "name": "x", "scale": {"min": 0, "max": 5}, "categories": [{"key": "a", "name": "a"}], "weights": {}}`, wantErr: "unknown field"},
+		{name: "bad scale", file: "club.yaml", content: "id: club\nname: x\nscale: {min: 5, max: 5}\ncategories: [{key: a, name: a}]", wantErr: "invalid scale"},
+		{name: "duplicate key", file: "club.yaml", content: "id: club\nname: x\nscale: {min: 0, max: 5}\ncategories: [{key: a, name: a}, {key: a, name: b}]", wantErr: "duplicate category a"},
+		{name: "bad key", file: "club.yaml", content: "id: club\nname: x\nscale: {min: 0, max: 5}\ncategories: [{key: 'a b', name: a}]", wantErr: "invalid category key"},
+		{name: "unknown base", file: "club.yaml", content: "id: club\nname: x\nextends: nowhere\ncategories: [{key: a, name: a}]", wantErr: "extends unknown rubric nowhere"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			registry, err := Load(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			club, ok := registry.Get("club-2026")
+			if !ok {
+				t.Fatal("club rubric not loaded")
+			}
+			if club.Name.In(locale.English) == "" || len(club.Categories) == 0 {
+				t.Errorf("club = %+v", club)
+			}
+			if registry.ForGenre(General).ID != "general-v1" {
+				t.Error("custom rubric replaced the general rubric")
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Annotation types returned by the analysis model.
+const (
+	AnnotationBox   = "box"
+	AnnotationPoint = "point"
+	AnnotationArrow = "arrow"
+)
+
+// NormalizedBox is a rectangle in image-relative coordinates: 0-1, origin top-left.
+type NormalizedBox struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// NormalizedPoint is a position in image-relative coordinates: 0-1, origin top-left.
+type NormalizedPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Annotation is a structured "red pen" mark on the photo. Box is used by box
+// annotations, Point by point annotations, and From/To by arrows.
+type Annotation struct {
+	Type     string           `json:"type"`
+	Category string           `json:"category"`
+	Label    string           `json:"label"`
+	Box      *NormalizedBox   `json:"box,omitempty"`
+	Point    *NormalizedPoint `json:"point,omitempty"`
+	From     *NormalizedPoint `json:"from,omitempty"`
+	To       *NormalizedPoint `json:"to,omitempty"`
+}
+
+// fontCandidates are Japanese-capable fonts tried when ANNOTATION_FONT_PATH is unset.
+var fontCandidates = []string{
+	"/usr/share/fonts/opentype/noto/NotoSansCJK-Bold.ttc",
+	"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/truetype/noto/NotoSansJP-Bold.ttf",
+	"/usr/share/fonts/noto-cjk/NotoSansCJK-Bold.ttc",
+	"/System/Library/Fonts/ヒラギノ角ゴシック W6.ttc",
+}
+
+var (
+	annotationFontOnce sync.Once
+	annotationFont     *opentype.Font
+)
+
+// loadAnnotationFont returns the label font, preferring a CJK font so Japanese
+// labels render; it falls back to Go Bold, which only covers Latin text.
+func loadAnnotationFont() *opentype.Font {
+	annotationFontOnce.Do(func() {
+		paths := fontCandidates
+		if configured := strings.TrimSpace(os.Getenv("ANNOTATION_FONT_PATH")); configured != "" {
+			paths = append([]string{configured}, paths...)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			if collection, err := opentype.ParseCollection(data); err == nil && collection.NumFonts() > 0 {
+				if parsed, err := collection.Font(0); err == nil {
+					annotationFont = parsed
+					log.Printf("INFO: Loaded annotation font %s", path)
+					return
+				}
+			}
+			if parsed, err := opentype.Parse(data); err == nil {
+				annotationFont = parsed
+				log.Printf("INFO: Loaded annotation font %s", path)
+				return
+			}
+		}
+		log.Printf("WARN: No Japanese-capable annotation font found; set ANNOTATION_FONT_PATH. Falling back to Go Bold")
+		annotationFont, _ = opentype.Parse(gobold.TTF)
+	})
+	return annotationFont
+}
+
+var (
+	penColor   = color.RGBA{230, 35, 35, 255}
+	labelFill  = color.RGBA{255, 255, 255, 220}
+	labelInk   = color.RGBA{200, 20, 20, 255}
+	shadowTint = color.RGBA{0, 0, 0, 90}
+)
+
+// RenderAnnotations draws the annotations onto a copy of base in a red-pen
+// style: outlined boxes, ringed points and arrows, each with its label.
+func RenderAnnotations(base image.Image, annotations []Annotation) *image.RGBA {
+	bounds := base.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), base, bounds.Min, draw.Src)
+
+	width, height := float64(bounds.Dx()), float64(bounds.Dy())
+	longEdge := math.Max(width, height)
+	stroke := math.Max(2, longEdge/300)
+
+	var face font.Face
+	if parsed := loadAnnotationFont(); parsed != nil {
+		if created, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: math.Max(12, longEdge/40), DPI: 72, Hinting: font.HintingFull}); err == nil {
+			face = created
+			defer created.Close()
+		}
+	}
+
+	toPixel := func(p NormalizedPoint) (float64, float64) {
+		return clampUnit(p.X) * width, clampUnit(p.Y) * height
+	}
+
+	for _, annotation := range annotations {
+		var labelX, labelY float64
+		switch annotation.Type {
+		case AnnotationBox:
+			if annotation.Box == nil {
+				continue
+			}
+			x0, y0 := toPixel(NormalizedPoint{annotation.Box.X, annotation.Box.Y})
+			x1, y1 := toPixel(NormalizedPoint{annotation.Box.X + annotation.Box.Width, annotation.Box.Y + annotation.Box.Height})
+			drawLine(canvas, x0, y0, x1, y0, stroke, penColor)
+			drawLine(canvas, x1, y0, x1, y1, stroke, penColor)
+			drawLine(canvas, x1, y1, x0, y1, stroke, penColor)
+			drawLine(canvas, x0, y1, x0, y0, stroke, penColor)
+			labelX, labelY = x0, y0
+		case AnnotationPoint:
+			if annotation.Point == nil {
+				continue
+			}
+			cx, cy := toPixel(*annotation.Point)
+			radius := longEdge / 30
+			drawRing(canvas, cx, cy, radius, stroke, penColor)
+			labelX, labelY = cx+radius, cy-radius
+		case AnnotationArrow:
+			if annotation.From == nil || annotation.To == nil {
+				continue
+			}
+			fx, fy := toPixel(*annotation.From)
+			tx, ty := toPixel(*annotation.To)
+			drawArrow(canvas, fx, fy, tx, ty, stroke, longEdge/35, penColor)
+			labelX, labelY = fx, fy
+		default:
+			continue
+		}
+
+		if face != nil && strings.TrimSpace(annotation.Label) != "" {
+			drawLabel(canvas, face, strings.TrimSpace(annotation.Label), labelX, labelY)
+		}
+	}
+	return canvas
+}
+
+// drawLine strokes a segment by stamping discs along it.
+func drawLine(canvas *image.RGBA, x0, y0, x1, y1, width float64, c color.RGBA) {
+	length := math.Hypot(x1-x0, y1-y0)
+	steps := max(1, int(length/(width/3)))
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		fillDisc(canvas, x0+(x1-x0)*t, y0+(y1-y0)*t, width/2, c)
+	}
+}
+
+// drawRing strokes a circle outline.
+func drawRing(canvas *image.RGBA, cx, cy, radius, width float64, c color.RGBA) {
+	steps := max(16, int(2*math.Pi*radius/(width/3)))
+	for i := 0; i < steps; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(steps)
+		fillDisc(canvas, cx+radius*math.Cos(angle), cy+radius*math.Sin(angle), width/2, c)
+	}
+}
+
+// drawArrow strokes a line from (x0,y0) with an open arrowhead at (x1,y1).
+func drawArrow(canvas *image.RGBA, x0, y0, x1, y1, width, head float64, c color.RGBA) {
+	drawLine(canvas, x0, y0, x1, y1, width, c)
+	angle := math.Atan2(y1-y0, x1-x0)
+	for _, side := range []float64{-1, 1} {
+		wing := angle + math.Pi - side*math.Pi/7
+		drawLine(canvas, x1, y1, x1+head*math.Cos(wing), y1+head*math.Sin(wing), width, c)
+	}
+}
+
+// fillDisc alpha-blends a filled circle onto canvas.
+func fillDisc(canvas *image.RGBA, cx, cy, radius float64, c color.RGBA) {
+	bounds := canvas.Bounds()
+	minX, maxX := max(int(cx-radius), bounds.Min.X), min(int(cx+radius)+1, bounds.Max.X)
+	minY, maxY := max(int(cy-radius), bounds.Min.Y), min(int(cy+radius)+1, bounds.Max.Y)
+	for y := minY; y < maxY; y++ {
+		for x := minX; x < maxX; x++ {
+			if math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) <= radius {
+				blendPixel(canvas, x, y, c)
+			}
+		}
+	}
+}
+
+// drawLabel writes text on a translucent plate anchored near (x, y), kept inside the frame.
+func drawLabel(canvas *image.RGBA, face font.Face, text string, x, y float64) {
+	metrics := face.Metrics()
+	advance := font.MeasureString(face, text).Ceil()
+	ascent, descent := metrics.Ascent.Ceil(), metrics.Descent.Ceil()
+	padding := max(4, ascent/4)
+
+	bounds := canvas.Bounds()
+	plate := image.Rect(0, 0, advance+padding*2, ascent+descent+padding*2)
+	left := min(max(int(x), 0), max(bounds.Dx()-plate.Dx(), 0))
+	top := int(y) - plate.Dy() - padding
+	if top < 0 {
+		top = int(y) + padding
+	}
+	top = min(top, max(bounds.Dy()-plate.Dy(), 0))
+	plate = plate.Add(image.Pt(left, top))
+
+	shadow := plate.Add(image.Pt(2, 2)).Intersect(bounds)
+	for py := shadow.Min.Y; py < shadow.Max.Y; py++ {
+		for px := shadow.Min.X; px < shadow.Max.X; px++ {
+			blendPixel(canvas, px, py, shadowTint)
+		}
+	}
+	visible := plate.Intersect(bounds)
+	for py := visible.Min.Y; py < visible.Max.Y; py++ {
+		for px := visible.Min.X; px < visible.Max.X; px++ {
+			blendPixel(canvas, px, py, labelFill)
+		}
+	}
+
+	drawer := &font.Drawer{
+		Dst:  canvas,
+		Src:  image.NewUniform(labelInk),
+		Face: face,
+		Dot:  fixed.P(plate.Min.X+padding, plate.Min.Y+padding+ascent),
+	}
+	drawer.DrawString(text)
+}
+
+func blendPixel(canvas *image.RGBA, x, y int, c color.RGBA) {
+	i := canvas.PixOffset(x, y)
+	alpha := float64(c.A) / 255
+	canvas.Pix[i] = uint8(float64(c.R)*alpha + float64(canvas.Pix[i])*(1-alpha))
+	canvas.Pix[i+1] = uint8(float64(c.G)*alpha + float64(canvas.Pix[i+1])*(1-alpha))
+	canvas.Pix[i+2] = uint8(float64(c.B)*alpha + float64(canvas.Pix[i+2])*(1-alpha))
+	canvas.Pix[i+3] = 255
+}
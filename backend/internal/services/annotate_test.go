@@ -0,0 +1,43 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRenderAnnotations(t *testing.T) {
+	base := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for i := range base.Pix {
+		base.Pix[i] = 128
+	}
+
+	annotations := []Annotation{
+		{Type: AnnotationBox, Category: "composition", Label: "主題を大きく", Box: &NormalizedBox{X: 0.25, Y: 0.5, Width: 0.5, Height: 0.25}},
+		{Type: AnnotationPoint, Category: "focus", Label: "focus", Point: &NormalizedPoint{X: 1.4, Y: -0.2}},
+		{Type: AnnotationArrow, Category: "lighting", Label: "", From: &NormalizedPoint{X: 0.1, Y: 0.1}, To: &NormalizedPoint{X: 0.3, Y: 0.3}},
+		{Type: AnnotationBox, Category: "color", Label: "missing geometry"},
+	}
+
+	rendered := RenderAnnotations(base, annotations)
+	if rendered.Bounds() != base.Bounds() {
+		t.Fatalf("bounds = %v, want %v", rendered.Bounds(), base.Bounds())
+	}
+
+	isPen := func(c color.RGBA) bool { return c.R > 200 && c.G < 80 && c.B < 80 }
+	// Bottom edge of the box: y = 0.75 * 300.
+	if !isPen(rendered.RGBAAt(200, 225)) {
+		t.Errorf("box edge not drawn, got %v", rendered.RGBAAt(200, 225))
+	}
+	// Box interior stays untouched.
+	if got := rendered.RGBAAt(200, 190); got != (color.RGBA{128, 128, 128, 128}) {
+		t.Errorf("box interior modified, got %v", got)
+	}
+	// Arrow midpoint.
+	if !isPen(rendered.RGBAAt(80, 60)) {
+		t.Errorf("arrow not drawn, got %v", rendered.RGBAAt(80, 60))
+	}
+	if base.RGBAAt(200, 225) != (color.RGBA{128, 128, 128, 128}) {
+		t.Error("RenderAnnotations modified the base image")
+	}
+}
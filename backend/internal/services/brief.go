@@ -0,0 +1,361 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+)
+
+// MaxBriefLength bounds the contest rules or theme sent with an analysis.
+const MaxBriefLength = 2000
+
+// Rule kinds of a contest brief.
+const (
+	RuleAspectRatio  = "aspectRatio"
+	RuleResolution   = "resolution"
+	RuleShootingDate = "shootingDate"
+	RuleCompositing  = "compositing"
+	RuleOther        = "other"
+)
+
+// ThemeEvaluation judges a photo against a contest theme or brief.
+type ThemeEvaluation struct {
+	Brief   string `json:"brief"`
+	Score   int    `json:"score"`
+	Comment string `json:"comment"`
+	// Violations lists the rules the photo breaks.
+	Violations []RuleViolation `json:"violations"`
+	// LocalChecks are the rules checked from the upload's dimensions and
+	// EXIF rather than by the model, passed or not.
+	LocalChecks []RuleCheck `json:"localChecks,omitempty"`
+}
+
+// RuleViolation is one broken rule of the brief.
+type RuleViolation struct {
+	Kind    string `json:"kind"`
+	Rule    string `json:"rule"`
+	Comment string `json:"comment"`
+	// Verified is true when the violation was measured locally.
+	Verified bool `json:"verified,omitempty"`
+}
+
+// RuleCheck is the outcome of one locally checked rule. Verified is false
+// when the upload lacks the data to decide, e.g. EXIF without a capture date.
+type RuleCheck struct {
+	Kind     string `json:"kind"`
+	Rule     string `json:"rule"`
+	Passed   bool   `json:"passed"`
+	Verified bool   `json:"verified"`
+	Detail   string `json:"detail"`
+}
+
+type briefKey struct{}
+
+// WithBrief makes analyses under ctx judge the photo against a contest
+// theme or rules given as free text.
+func WithBrief(ctx context.Context, brief string) context.Context {
+	return context.WithValue(ctx, briefKey{}, strings.TrimSpace(brief))
+}
+
+// BriefFromContext returns the contest brief, or "".
+func BriefFromContext(ctx context.Context) string {
+	brief, _ := ctx.Value(briefKey{}).(string)
+	return brief
+}
+
+// themeSchema is the theme property of the analysis schema.
+func themeSchema(l locale.Locale, scale rubric.Scale) *genai.Schema {
+	describe := func(key string, args ...any) string { return locale.T(l, "schema."+key, args...) }
+	minScore := float64(scale.Min)
+	maxScore := float64(scale.Max)
+	return &genai.Schema{
+		Type:        genai.TypeObject,
+		Description: describe("theme"),
+		Properties: map[string]*genai.Schema{
+			"score": {
+				Type:        genai.TypeInteger,
+				Minimum:     &minScore,
+				Maximum:     &maxScore,
+				Description: describe("themeScore", scale.Min, scale.Max),
+			},
+			"comment": {
+				Type:        genai.TypeString,
+				Description: describe("themeComment"),
+			},
+			"violations": {
+				Type:        genai.TypeArray,
+				Description: describe("themeViolations"),
+				Items: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"kind": {
+							Type:        genai.TypeString,
+							Enum:        []string{RuleAspectRatio, RuleResolution, RuleShootingDate, RuleCompositing, RuleOther},
+							Description: describe("violationKind"),
+						},
+						"rule": {
+							Type:        genai.TypeString,
+							Description: describe("violationRule"),
+						},
+						"comment": {
+							Type:        genai.TypeString,
+							Description: describe("violationComment"),
+						},
+					},
+					Required: []string{"kind", "rule", "comment"},
+				},
+			},
+		},
+		Required:         []string{"score", "comment", "violations"},
+		PropertyOrdering: []string{"score", "comment", "violations"},
+	}
+}
+
+// withThemeSchema adds the theme property to an analysis schema.
+func withThemeSchema(schema *genai.Schema, l locale.Locale, scale rubric.Scale) *genai.Schema {
+	schema.Properties["theme"] = themeSchema(l, scale)
+	schema.Required = append(schema.Required, "theme")
+	schema.PropertyOrdering = append(schema.PropertyOrdering, "theme")
+	return schema
+}
+
+// photoFacts are what the local rule checks can measure on the upload.
+type photoFacts struct {
+	width, height int
+	exif          ExifInfo
+	hasExif       bool
+}
+
+// briefRule is one rule found in a brief that can be checked locally.
+type briefRule struct {
+	kind  string
+	text  string
+	check func(settings locale.Settings, photo photoFacts) RuleCheck
+}
+
+var (
+	sentenceSplitter = regexp.MustCompile(`[。\n;；]+|\.\s+`)
+	ratioPattern     = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*[:：]\s*(\d+(?:\.\d+)?)`)
+	aspectKeywords   = regexp.MustCompile(`(?i)縦横比|アスペクト比|比率|aspect|ratio`)
+	squareKeywords   = regexp.MustCompile(`(?i)正方形|square`)
+	edgePattern      = regexp.MustCompile(`(?i)(長辺|短辺|long(?:est)?\s+(?:edge|side)|short(?:est)?\s+(?:edge|side))\D{0,24}?(\d[\d,]*)\s*(?:px|ピクセル|pixels?)`)
+	sizePattern      = regexp.MustCompile(`(?i)(\d[\d,]*)\s*[x×]\s*(\d[\d,]*)\s*(?:px|ピクセル|pixels?)`)
+	megapixelPattern = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(万画素|MP\b|megapixels?|メガピクセル)`)
+	datePattern      = regexp.MustCompile(`(\d{4})\s*[年/\-.]\s*(\d{1,2})\s*[月/\-.]\s*(\d{1,2})`)
+	shotKeywords     = regexp.MustCompile(`(?i)撮影|taken|shot|captured`)
+	afterKeywords    = regexp.MustCompile(`(?i)以降|以後|after|since`)
+	beforeKeywords   = regexp.MustCompile(`(?i)以前|まで|before|until|by\s`)
+	compositeWords   = regexp.MustCompile(`(?i)合成|コラージュ|compositing|composite|montage|collage`)
+	maxWords         = regexp.MustCompile(`(?i)まで|以内|以下|未満|up to|at most|no more than|not exceed|maximum|\bmax\b|or less|or smaller`)
+	minWords         = regexp.MustCompile(`(?i)以上|at least|minimum|\bmin\b|or more|or larger`)
+)
+
+// ratioTolerance is how far a photo's aspect ratio may be from a required one.
+const ratioTolerance = 0.01
+
+// parseBriefRules finds the aspect ratio, resolution, shooting date and
+// compositing rules of a brief, one sentence at a time.
+func parseBriefRules(brief string) []briefRule {
+	var rules []briefRule
+	for _, sentence := range sentenceSplitter.Split(brief, -1) {
+		sentence = strings.TrimSpace(sentence)
+		if sentence == "" {
+			continue
+		}
+		limitIsMax := maxWords.MatchString(sentence) && !minWords.MatchString(sentence)
+		if aspectKeywords.MatchString(sentence) || squareKeywords.MatchString(sentence) {
+			if rule, ok := aspectRule(sentence, limitIsMax); ok {
+				rules = append(rules, rule)
+			}
+		}
+		rules = append(rules, resolutionRules(sentence, limitIsMax)...)
+		if shotKeywords.MatchString(sentence) {
+			if rule, ok := dateRule(sentence); ok {
+				rules = append(rules, rule)
+			}
+		}
+		if compositeWords.MatchString(sentence) {
+			rules = append(rules, briefRule{kind: RuleCompositing, text: sentence, check: checkCompositing})
+		}
+	}
+	return rules
+}
+
+// aspectRule reads the allowed ratios of a sentence, orientation-agnostic:
+// "3:2" allows 2:3 too. With limit words it is a maximum ratio instead.
+func aspectRule(sentence string, limitIsMax bool) (briefRule, bool) {
+	var ratios []float64
+	for _, match := range ratioPattern.FindAllStringSubmatch(sentence, -1) {
+		a, _ := strconv.ParseFloat(match[1], 64)
+		b, _ := strconv.ParseFloat(match[2], 64)
+		if a > 0 && b > 0 {
+			ratios = append(ratios, math.Max(a, b)/math.Min(a, b))
+		}
+	}
+	if squareKeywords.MatchString(sentence) {
+		ratios = append(ratios, 1)
+	}
+	if len(ratios) == 0 {
+		return briefRule{}, false
+	}
+	return briefRule{kind: RuleAspectRatio, text: sentence, check: func(settings locale.Settings, photo photoFacts) RuleCheck {
+		ratio := float64(max(photo.width, photo.height)) / float64(min(photo.width, photo.height))
+		passed := false
+		for _, allowed := range ratios {
+			if limitIsMax {
+				passed = passed || ratio <= allowed*(1+ratioTolerance)
+			} else {
+				passed = passed || math.Abs(ratio-allowed)/allowed <= ratioTolerance
+			}
+		}
+		return RuleCheck{Passed: passed, Verified: true, Detail: settings.T("rule.aspectRatio", ratio, photo.width, photo.height)}
+	}}, true
+}
+
+// resolutionRules reads edge, size and megapixel limits of a sentence.
+func resolutionRules(sentence string, limitIsMax bool) []briefRule {
+	var rules []briefRule
+	within := func(value, limit float64) bool {
+		if limitIsMax {
+			return value <= limit
+		}
+		return value >= limit
+	}
+	add := func(measure func(photo photoFacts) bool) {
+		rules = append(rules, briefRule{kind: RuleResolution, text: sentence, check: func(settings locale.Settings, photo photoFacts) RuleCheck {
+			megapixels := float64(photo.width*photo.height) / 1e6
+			return RuleCheck{Passed: measure(photo), Verified: true, Detail: settings.T("rule.resolution", photo.width, photo.height, megapixels)}
+		}})
+	}
+	for _, match := range edgePattern.FindAllStringSubmatch(sentence, -1) {
+		limit := parsePixels(match[2])
+		long := strings.Contains(match[1], "長") || strings.HasPrefix(strings.ToLower(match[1]), "long")
+		add(func(photo photoFacts) bool {
+			edge := min(photo.width, photo.height)
+			if long {
+				edge = max(photo.width, photo.height)
+			}
+			return within(float64(edge), limit)
+		})
+	}
+	for _, match := range sizePattern.FindAllStringSubmatch(sentence, -1) {
+		a, b := parsePixels(match[1]), parsePixels(match[2])
+		add(func(photo photoFacts) bool {
+			long, short := float64(max(photo.width, photo.height)), float64(min(photo.width, photo.height))
+			return within(long, math.Max(a, b)) && within(short, math.Min(a, b))
+		})
+	}
+	for _, match := range megapixelPattern.FindAllStringSubmatch(sentence, -1) {
+		value, _ := strconv.ParseFloat(match[1], 64)
+		pixels := value * 1e6
+		if match[2] == "万画素" {
+			pixels = value * 1e4
+		}
+		add(func(photo photoFacts) bool {
+			return within(float64(photo.width*photo.height), pixels)
+		})
+	}
+	return rules
+}
+
+func parsePixels(raw string) float64 {
+	value, _ := strconv.ParseFloat(strings.ReplaceAll(raw, ",", ""), 64)
+	return value
+}
+
+// dateRule reads a "taken on or after" or "taken before" date.
+func dateRule(sentence string) (briefRule, bool) {
+	match := datePattern.FindStringSubmatch(sentence)
+	if match == nil {
+		return briefRule{}, false
+	}
+	year, _ := strconv.Atoi(match[1])
+	month, _ := strconv.Atoi(match[2])
+	day, _ := strconv.Atoi(match[3])
+	limit := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	after := afterKeywords.MatchString(sentence)
+	if !after && !beforeKeywords.MatchString(sentence) {
+		return briefRule{}, false
+	}
+	return briefRule{kind: RuleShootingDate, text: sentence, check: func(settings locale.Settings, photo photoFacts) RuleCheck {
+		if !photo.hasExif || photo.exif.TakenAt.IsZero() {
+			return RuleCheck{Passed: true, Detail: settings.T("rule.dateUnknown")}
+		}
+		taken := photo.exif.TakenAt
+		// "Until" includes the whole limit day.
+		passed := !taken.Before(limit)
+		if !after {
+			passed = taken.Before(limit.AddDate(0, 0, 1))
+		}
+		return RuleCheck{Passed: passed, Verified: true, Detail: settings.T("rule.date", taken.Format("2006-01-02 15:04"))}
+	}}, true
+}
+
+// checkCompositing cannot see compositing in EXIF; it reports the editing
+// software, if any, and leaves the verdict to the model.
+func checkCompositing(settings locale.Settings, photo photoFacts) RuleCheck {
+	detail := settings.T("rule.compositingUnknown")
+	if photo.hasExif && photo.exif.Software != "" {
+		detail = settings.T("rule.compositingSoftware", photo.exif.Software)
+	}
+	return RuleCheck{Passed: true, Detail: detail}
+}
+
+// CheckBriefRules verifies the measurable rules of the context's brief
+// against the upload's dimensions and EXIF and merges them into the
+// analysis. Local verdicts replace the model's for the same kind of rule,
+// since the model only saw a resized copy without metadata.
+func CheckBriefRules(ctx context.Context, analysis *AnalysisResult, original []byte) {
+	brief := BriefFromContext(ctx)
+	if brief == "" {
+		return
+	}
+	if analysis.Theme == nil {
+		analysis.Theme = &ThemeEvaluation{}
+	}
+	theme := analysis.Theme
+	theme.Brief = brief
+	rules := parseBriefRules(brief)
+	if len(rules) == 0 {
+		return
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(original))
+	if err != nil || config.Width == 0 || config.Height == 0 {
+		return
+	}
+	photo := photoFacts{width: config.Width, height: config.Height}
+	photo.exif, photo.hasExif = ReadExif(original)
+
+	settings := locale.FromContext(ctx)
+	measured := map[string]bool{}
+	var local []RuleViolation
+	for _, rule := range rules {
+		check := rule.check(settings, photo)
+		check.Kind, check.Rule = rule.kind, rule.text
+		theme.LocalChecks = append(theme.LocalChecks, check)
+		if !check.Verified {
+			continue
+		}
+		measured[rule.kind] = true
+		if !check.Passed {
+			local = append(local, RuleViolation{Kind: rule.kind, Rule: rule.text, Comment: check.Detail, Verified: true})
+		}
+	}
+	violations := local
+	for _, violation := range theme.Violations {
+		if !measured[violation.Kind] {
+			violations = append(violations, violation)
+		}
+	}
+	theme.Violations = violations
+}
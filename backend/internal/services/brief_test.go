@@ -0,0 +1,142 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+	"time"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+)
+
+// exifJPEG encodes a width × height JPEG carrying Software and
+// DateTimeOriginal EXIF tags.
+func exifJPEG(t *testing.T, width, height int, software, taken string) []byte {
+	t.Helper()
+	order := binary.LittleEndian
+	entry := func(tag, kind uint16, count, value uint32) []byte {
+		b := make([]byte, 12)
+		order.PutUint16(b, tag)
+		order.PutUint16(b[2:], kind)
+		order.PutUint32(b[4:], count)
+		order.PutUint32(b[8:], value)
+		return b
+	}
+	softwareText := append([]byte(software), 0)
+	takenText := append([]byte(taken), 0)
+	// Layout: header (8), IFD0 with two entries (2+24+4), the software
+	// text, then the EXIF IFD with one entry (2+12+4) and the date text.
+	ifd0 := uint32(8)
+	softwareAt := ifd0 + 30
+	exifIFD := softwareAt + uint32(len(softwareText))
+	takenAt := exifIFD + 18
+
+	tiff := &bytes.Buffer{}
+	tiff.WriteString("II*\x00")
+	binary.Write(tiff, order, ifd0)
+	binary.Write(tiff, order, uint16(2))
+	tiff.Write(entry(exifTagSoftware, 2, uint32(len(softwareText)), softwareAt))
+	tiff.Write(entry(exifTagExifIFD, 4, 1, exifIFD))
+	binary.Write(tiff, order, uint32(0))
+	tiff.Write(softwareText)
+	binary.Write(tiff, order, uint16(1))
+	tiff.Write(entry(exifTagDateTimeOriginal, 2, uint32(len(takenText)), takenAt))
+	binary.Write(tiff, order, uint32(0))
+	tiff.Write(takenText)
+
+	encoded := &bytes.Buffer{}
+	if err := jpeg.Encode(encoded, image.NewGray(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	data := append([]byte{}, encoded.Bytes()[:2]...)
+	data = append(data, app1...)
+	data = append(data, segment...)
+	return append(data, encoded.Bytes()[2:]...)
+}
+
+func TestReadExif(t *testing.T) {
+	info, ok := ReadExif(exifJPEG(t, 16, 8, "Photoshop 25.0", "2026:05:03 06:12:00"))
+	if !ok {
+		t.Fatal("no EXIF found")
+	}
+	if info.Software != "Photoshop 25.0" || !info.TakenAt.Equal(time.Date(2026, 5, 3, 6, 12, 0, 0, time.UTC)) {
+		t.Errorf("exif = %+v", info)
+	}
+	if _, ok := ReadExif([]byte("not a jpeg")); ok {
+		t.Error("EXIF found in non-JPEG data")
+	}
+}
+
+func TestCheckBriefRules(t *testing.T) {
+	photo := exifJPEG(t, 600, 400, "Photoshop 25.0", "2026:05:03 06:12:00")
+	tests := []struct {
+		name      string
+		brief     string
+		wantKinds []string
+		wantLocal int
+	}{
+		{name: "theme only", brief: "テーマ: 水辺の光"},
+		{name: "allowed ratio", brief: "縦横比は3:2または4:3のみ", wantLocal: 1},
+		{name: "square only", brief: "Entries must be square.", wantKinds: []string{RuleAspectRatio}, wantLocal: 1},
+		{name: "ratio limit", brief: "縦横比は2:1まで", wantLocal: 1},
+		{name: "long edge", brief: "長辺1,000px以上", wantKinds: []string{RuleResolution}, wantLocal: 1},
+		{name: "megapixel cap", brief: "Files up to 24MP", wantLocal: 1},
+		{name: "size", brief: "3000×2000px以上", wantKinds: []string{RuleResolution}, wantLocal: 1},
+		{name: "taken after", brief: "2026年6月1日以降に撮影した作品", wantKinds: []string{RuleShootingDate}, wantLocal: 1},
+		{name: "taken before", brief: "Photos taken before 2026-06-01", wantLocal: 1},
+		{name: "compositing", brief: "合成写真は不可", wantLocal: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithBrief(locale.WithSettings(context.Background(), locale.Settings{Locale: locale.Japanese}), tt.brief)
+			analysis := &AnalysisResult{Theme: &ThemeEvaluation{Score: 7}}
+			CheckBriefRules(ctx, analysis, photo)
+			var kinds []string
+			for _, violation := range analysis.Theme.Violations {
+				kinds = append(kinds, violation.Kind)
+			}
+			if len(kinds) != len(tt.wantKinds) || (len(kinds) > 0 && kinds[0] != tt.wantKinds[0]) {
+				t.Errorf("violations = %+v, want kinds %v", analysis.Theme.Violations, tt.wantKinds)
+			}
+			if len(analysis.Theme.LocalChecks) != tt.wantLocal {
+				t.Errorf("local checks = %+v, want %d", analysis.Theme.LocalChecks, tt.wantLocal)
+			}
+			if analysis.Theme.Brief != tt.brief || analysis.Theme.Score != 7 {
+				t.Errorf("theme = %+v", analysis.Theme)
+			}
+		})
+	}
+}
+
+func TestCheckBriefRulesReplacesModelVerdicts(t *testing.T) {
+	ctx := WithBrief(context.Background(), "縦横比は3:2のみ。合成は禁止です。")
+	analysis := &AnalysisResult{Theme: &ThemeEvaluation{Violations: []RuleViolation{
+		{Kind: RuleAspectRatio, Rule: "縦横比は3:2のみ", Comment: "正方形に見えます"},
+		{Kind: RuleCompositing, Rule: "合成は禁止です", Comment: "空が差し替えられています"},
+	}}}
+	CheckBriefRules(ctx, analysis, exifJPEG(t, 600, 400, "", "2026:05:03 06:12:00"))
+	violations := analysis.Theme.Violations
+	if len(violations) != 1 || violations[0].Kind != RuleCompositing || violations[0].Verified {
+		t.Errorf("violations = %+v, want only the model's compositing verdict", violations)
+	}
+}
+
+func TestCheckBriefRulesWithoutExifDate(t *testing.T) {
+	ctx := WithBrief(context.Background(), "2026年6月1日以降に撮影した作品")
+	encoded := &bytes.Buffer{}
+	if err := jpeg.Encode(encoded, image.NewGray(image.Rect(0, 0, 30, 20)), nil); err != nil {
+		t.Fatal(err)
+	}
+	analysis := &AnalysisResult{}
+	CheckBriefRules(ctx, analysis, encoded.Bytes())
+	checks := analysis.Theme.LocalChecks
+	if len(checks) != 1 || checks[0].Verified || len(analysis.Theme.Violations) != 0 {
+		t.Errorf("theme = %+v, want one unverified date check", analysis.Theme)
+	}
+}
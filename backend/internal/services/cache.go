@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// ResultCache stores model results by key with a TTL.
+type ResultCache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// cachedCall is what a cache entry holds: the result plus who produced it and what it cost.
+type cachedCall struct {
+	Model  string          `json:"model"`
+	Usage  UsageTotals     `json:"usage"`
+	Result json.RawMessage `json:"result"`
+}
+
+// resultCacheKey hashes the image content, the model chain, the prompt
+// template version and any extra request parts into a cache key.
+func resultCacheKey(step string, imageData []byte, chain []string, promptVersion string, extra ...string) string {
+	hash := sha256.New()
+	for _, part := range append([]string{step, strings.Join(chain, ","), promptVersion}, extra...) {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(imageData)
+	return step + "-" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// lookupCached returns a cached result for key, recording the hit on the
+// context's trace. Cache errors are logged and treated as misses.
+func (g *GeminiClient) lookupCached(ctx context.Context, step, key string, result any) bool {
+	if g.cache == nil {
+		return false
+	}
+	raw, ok, err := g.cache.Get(ctx, key)
+	if err != nil {
+		log.Printf("WARN: Result cache read failed for %s: %v", step, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	var entry cachedCall
+	if err := json.Unmarshal(raw, &entry); err != nil || json.Unmarshal(entry.Result, result) != nil {
+		log.Printf("WARN: Ignoring unreadable result cache entry for %s", step)
+		return false
+	}
+	trace := CallTraceFrom(ctx)
+	trace.RecordModel(step, entry.Model)
+	trace.RecordCacheHit(step, entry.Usage.CostUSD)
+	log.Printf("INFO: %s served from result cache (saved $%.4f)", step, entry.Usage.CostUSD)
+	return true
+}
+
+// storeCached writes a result to the cache; failures only cost a future call.
+func (g *GeminiClient) storeCached(ctx context.Context, step, key string, call modelCall, result any) {
+	if g.cache == nil {
+		return
+	}
+	encoded, err := json.Marshal(result)
+	if err == nil {
+		var raw []byte
+		raw, err = json.Marshal(cachedCall{Model: call.Model, Usage: call.Usage, Result: encoded})
+		if err == nil {
+			err = g.cache.Set(ctx, key, raw, resultCacheTTL())
+		}
+	}
+	if err != nil {
+		log.Printf("WARN: Result cache write failed for %s: %v", step, err)
+	}
+}
+
+// resultCacheTTL reads RESULT_CACHE_TTL (default one week).
+func resultCacheTTL() time.Duration {
+	ttl := 7 * 24 * time.Hour
+	if raw := strings.TrimSpace(os.Getenv("RESULT_CACHE_TTL")); raw != "" {
+		if value, err := time.ParseDuration(raw); err == nil && value > 0 {
+			ttl = value
+		} else {
+			log.Printf("WARN: Invalid RESULT_CACHE_TTL %q, using %s", raw, ttl)
+		}
+	}
+	return ttl
+}
+
+// NewResultCache selects the cache backend from RESULT_CACHE_BACKEND:
+// "memory" (default), "gcs" (objects under cache/ in BUCKET_NAME) or "off".
+func NewResultCache(ctx context.Context) ResultCache {
+	switch backend := strings.ToLower(strings.TrimSpace(os.Getenv("RESULT_CACHE_BACKEND"))); backend {
+	case "", "memory":
+		return NewMemoryResultCache(defaultMemoryCacheEntries)
+	case "off", "none":
+		return nil
+	case "gcs":
+		cache, err := NewGCSResultCache(ctx, os.Getenv("BUCKET_NAME"))
+		if err != nil {
+			log.Printf("WARN: Failed to create GCS result cache, using memory: %v", err)
+			return NewMemoryResultCache(defaultMemoryCacheEntries)
+		}
+		return cache
+	default:
+		log.Printf("WARN: Unknown RESULT_CACHE_BACKEND %q, using memory", backend)
+		return NewMemoryResultCache(defaultMemoryCacheEntries)
+	}
+}
+
+// defaultMemoryCacheEntries bounds the memory cache; enhancement entries hold whole images.
+const defaultMemoryCacheEntries = 128
+
+// MemoryResultCache is an in-process ResultCache holding at most maxEntries
+// entries; the entry closest to expiry is evicted first.
+type MemoryResultCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]memoryCacheEntry
+	now        func() time.Time
+}
+
+type memoryCacheEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemoryResultCache creates an in-memory cache bounded to maxEntries.
+func NewMemoryResultCache(maxEntries int) *MemoryResultCache {
+	return &MemoryResultCache{maxEntries: maxEntries, entries: map[string]memoryCacheEntry{}, now: time.Now}
+}
+
+func (c *MemoryResultCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !c.now().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (c *MemoryResultCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		oldest := ""
+		for candidate, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, candidate)
+				continue
+			}
+			if oldest == "" || entry.expiresAt.Before(c.entries[oldest].expiresAt) {
+				oldest = candidate
+			}
+		}
+		if len(c.entries) >= c.maxEntries && oldest != "" {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = memoryCacheEntry{value: append([]byte(nil), value...), expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// GCSResultCache persists entries as objects under cache/ in a bucket. The
+// expiry is kept in object metadata; a bucket lifecycle rule on the prefix
+// can delete stale objects.
+type GCSResultCache struct {
+	client     *storage.Client
+	bucketName string
+}
+
+const gcsCacheExpiresKey = "expires-at"
+
+// NewGCSResultCache creates a bucket-backed cache.
+func NewGCSResultCache(ctx context.Context, bucketName string) (*GCSResultCache, error) {
+	if strings.TrimSpace(bucketName) == "" {
+		return nil, errors.New("BUCKET_NAME is required")
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &GCSResultCache{client: client, bucketName: bucketName}, nil
+}
+
+func (c *GCSResultCache) object(key string) *storage.ObjectHandle {
+	return c.client.Bucket(c.bucketName).Object("cache/" + key)
+}
+
+func (c *GCSResultCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	attrs, err := c.object(key).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cache object attributes: %w", err)
+	}
+	expiresAt, err := time.Parse(time.RFC3339, attrs.Metadata[gcsCacheExpiresKey])
+	if err != nil || !time.Now().Before(expiresAt) {
+		return nil, false, nil
+	}
+	reader, err := c.object(key).Generation(attrs.Generation).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open cache object: %w", err)
+	}
+	defer reader.Close()
+	value, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cache object: %w", err)
+	}
+	return value, true, nil
+}
+
+func (c *GCSResultCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	writer := c.object(key).NewWriter(ctx)
+	writer.ContentType = "application/json"
+	writer.Metadata = map[string]string{gcsCacheExpiresKey: time.Now().Add(ttl).UTC().Format(time.RFC3339)}
+	if _, err := writer.Write(value); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to write cache object: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write cache object: %w", err)
+	}
+	return nil
+}
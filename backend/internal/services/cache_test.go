@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResultCacheKey(t *testing.T) {
+	base := resultCacheKey(StepAnalysis, []byte("image"), []string{"a", "b"}, "v1")
+	tests := []struct {
+		name string
+		key  string
+		same bool
+	}{
+		{name: "identical", key: resultCacheKey(StepAnalysis, []byte("image"), []string{"a", "b"}, "v1"), same: true},
+		{name: "other image", key: resultCacheKey(StepAnalysis, []byte("image2"), []string{"a", "b"}, "v1")},
+		{name: "other chain", key: resultCacheKey(StepAnalysis, []byte("image"), []string{"b"}, "v1")},
+		{name: "other prompt version", key: resultCacheKey(StepAnalysis, []byte("image"), []string{"a", "b"}, "v2")},
+		{name: "other step", key: resultCacheKey(StepCleanEnhancement, []byte("image"), []string{"a", "b"}, "v1")},
+		{name: "extra part", key: resultCacheKey(StepAnalysis, []byte("image"), []string{"a", "b"}, "v1", "prompt")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.key == base) != tt.same {
+				t.Fatalf("key equality = %v, want %v", tt.key == base, tt.same)
+			}
+		})
+	}
+}
+
+func TestMemoryResultCacheExpiryAndEviction(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewMemoryResultCache(2)
+	cache.now = func() time.Time { return now }
+
+	_ = cache.Set(ctx, "short", []byte("1"), time.Minute)
+	_ = cache.Set(ctx, "long", []byte("2"), time.Hour)
+	_ = cache.Set(ctx, "new", []byte("3"), time.Hour)
+	if _, ok, _ := cache.Get(ctx, "short"); ok {
+		t.Fatal("entry closest to expiry was not evicted")
+	}
+	if value, ok, _ := cache.Get(ctx, "long"); !ok || string(value) != "2" {
+		t.Fatalf("long = %q, %v", value, ok)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, ok, _ := cache.Get(ctx, "new"); ok {
+		t.Fatal("expired entry was returned")
+	}
+}
+
+func TestGeminiClientCacheRoundTrip(t *testing.T) {
+	client := &GeminiClient{cache: NewMemoryResultCache(4)}
+	ctx, trace := WithCallTrace(context.Background())
+
+	var miss AnalysisResult
+	if client.lookupCached(ctx, StepAnalysis, "key", &miss) {
+		t.Fatal("empty cache reported a hit")
+	}
+	client.storeCached(ctx, StepAnalysis, "key", modelCall{Model: "model-a", Usage: UsageTotals{Calls: 1, CostUSD: 0.02}}, FakeAnalysisResult())
+
+	var hit AnalysisResult
+	if !client.lookupCached(ctx, StepAnalysis, "key", &hit) {
+		t.Fatal("stored result was not found")
+	}
+	if hit.OverallScore != FakeAnalysisResult().OverallScore {
+		t.Fatalf("cached score = %d", hit.OverallScore)
+	}
+	steps, saved := trace.CacheHits()
+	if len(steps) != 1 || steps[0] != StepAnalysis || saved != 0.02 {
+		t.Fatalf("cache hits = %v, saved %v", steps, saved)
+	}
+	if trace.Models()[StepAnalysis] != "model-a" {
+		t.Fatalf("serving model = %q", trace.Models()[StepAnalysis])
+	}
+}
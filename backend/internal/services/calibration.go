@@ -0,0 +1,40 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/calibration"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+)
+
+// applyCalibration maps the model's scores through c, keeping the raw
+// scores on the result, and recomputes the overall score. Results from a
+// rubric, prompt or model the calibration was not fitted on, or written for
+// a skill level, are left raw with the reason recorded.
+func (a *AnalysisResult) applyCalibration(c *calibration.Calibration, r rubric.Rubric) {
+	if c == nil || a.CalibrationID != "" {
+		return
+	}
+	a.CalibrationSkipped = c.SkipReason(r, a.PromptVersion, a.Model)
+	if a.CalibrationSkipped == "" && a.SkillLevel != "" {
+		// The prompt scores more or less strictly for a skill level.
+		a.CalibrationSkipped = fmt.Sprintf("calibration %s was fitted without a skill level, not for %s", c.ID, a.SkillLevel)
+	}
+	if a.CalibrationSkipped != "" {
+		return
+	}
+	a.RawScores = map[string]int{}
+	for key, score := range a.Scores {
+		a.RawScores[key] = score.Score
+		if mapping, ok := c.Categories[key]; ok {
+			score.Score = mapping.Apply(score.Score, r.Scale)
+			a.Scores[key] = score
+		}
+	}
+	a.RawOverallScore = a.OverallScore
+	a.syncCoreCategories()
+	if overall, ok := weightedOverallScore(a, r); ok {
+		a.OverallScore = overall
+	}
+	a.CalibrationID = c.ID
+}
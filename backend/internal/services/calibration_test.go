@@ -0,0 +1,74 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/calibration"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+)
+
+func TestApplyCalibration(t *testing.T) {
+	general := rubric.Default().ForGenre(rubric.General)
+	c := &calibration.Calibration{
+		ID:            "reference-v2",
+		RubricID:      general.ID,
+		Scale:         general.Scale,
+		Models:        []string{FakeModelName},
+		PromptVersion: "analysis-v7",
+		Categories:    map[string]calibration.Mapping{"composition": {Slope: 1, Intercept: 2}, "exposure": {Slope: 0.5, Intercept: 0}},
+	}
+	// fitted is a raw result of the model and prompt c was fitted on.
+	fitted := func() *AnalysisResult {
+		result := FakeAnalysisResult()
+		result.Model, result.PromptVersion = FakeModelName, c.PromptVersion
+		return result
+	}
+
+	result := fitted()
+	rawOverall := result.OverallScore
+	result.applyCalibration(c, general)
+	if result.CalibrationID != "reference-v2" || result.RawOverallScore != rawOverall || result.CalibrationSkipped != "" {
+		t.Errorf("calibration not recorded: id=%q rawOverall=%d", result.CalibrationID, result.RawOverallScore)
+	}
+	if result.RawScores["composition"] != 5 || result.Scores["composition"].Score != 7 || result.Composition.Score != 7 {
+		t.Errorf("composition raw=%d calibrated=%d field=%d, want 5/7/7", result.RawScores["composition"], result.Scores["composition"].Score, result.Composition.Score)
+	}
+	if result.Scores["exposure"].Score != 4 || result.Scores["color"].Score != 6 {
+		t.Errorf("exposure=%d color=%d, want 4 and unmapped 6", result.Scores["exposure"].Score, result.Scores["color"].Score)
+	}
+	if overall, _ := weightedOverallScore(result, general); result.OverallScore != overall {
+		t.Errorf("overall %d not recomputed from calibrated scores (%d)", result.OverallScore, overall)
+	}
+
+	// Applying twice must not map the calibrated scores again.
+	result.applyCalibration(c, general)
+	if result.Scores["composition"].Score != 7 {
+		t.Errorf("calibration applied twice: %d", result.Scores["composition"].Score)
+	}
+
+	fivePoint := general
+	fivePoint.Scale = rubric.Scale{Min: 1, Max: 5}
+	skipped := []struct {
+		name   string
+		rubric rubric.Rubric
+		adjust func(*AnalysisResult)
+	}{
+		{name: "different scale", rubric: fivePoint},
+		{name: "different rubric on the same scale", rubric: rubric.Default().ForGenre(rubric.Portrait)},
+		{name: "prompt variant", rubric: general, adjust: func(a *AnalysisResult) { a.PromptVersion = "analysis-warm-v1" }},
+		{name: "fallback model", rubric: general, adjust: func(a *AnalysisResult) { a.Model = "gemini-2.5-flash" }},
+		{name: "skill level", rubric: general, adjust: func(a *AnalysisResult) { a.SkillLevel = "beginner" }},
+	}
+	for _, tt := range skipped {
+		t.Run(tt.name, func(t *testing.T) {
+			other := fitted()
+			if tt.adjust != nil {
+				tt.adjust(other)
+			}
+			other.applyCalibration(c, tt.rubric)
+			if other.CalibrationID != "" || other.RawScores != nil || other.CalibrationSkipped == "" {
+				t.Errorf("calibration id=%q skipped=%q, want it skipped with a reason", other.CalibrationID, other.CalibrationSkipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/genai"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+)
+
+// defaultMaxEnsembleSamples bounds ensemble analyses; each sample is a full model call.
+const defaultMaxEnsembleSamples = 5
+
+// unstableRange is the share of the score scale above which samples
+// disagree enough for a score to be flagged as unstable.
+const unstableRange = 0.15
+
+// EnsembleSummary describes how much the sampled analyses agreed.
+type EnsembleSummary struct {
+	Samples int `json:"samples"`
+	// Categories holds the spread of each category score across samples.
+	Categories map[string]ScoreSpread `json:"categories"`
+	Overall    ScoreSpread            `json:"overall"`
+	// Confidence is the mean category confidence, 0 to 1.
+	Confidence float64 `json:"confidence"`
+}
+
+// ScoreSpread is the distribution of one score across samples.
+type ScoreSpread struct {
+	Median int `json:"median"`
+	Min    int `json:"min"`
+	Max    int `json:"max"`
+	// Spread is half the range, rounded up: the score is Median ± Spread.
+	Spread int `json:"spread"`
+	// Confidence is 1 when the samples agree and 0 when they span the whole scale.
+	Confidence float64 `json:"confidence"`
+	Unstable   bool    `json:"unstable"`
+}
+
+type ensembleKey struct{}
+
+// WithEnsemble makes analyses under ctx sample the model n times and
+// aggregate the answers.
+func WithEnsemble(ctx context.Context, samples int) context.Context {
+	return context.WithValue(ctx, ensembleKey{}, samples)
+}
+
+// EnsembleSize returns the number of samples requested, 1 by default.
+func EnsembleSize(ctx context.Context) int {
+	if samples, ok := ctx.Value(ensembleKey{}).(int); ok && samples > 1 {
+		return samples
+	}
+	return 1
+}
+
+// MaxEnsembleSamples returns the most samples a request may ask for
+// (ANALYSIS_MAX_SAMPLES, default 5).
+func MaxEnsembleSamples() int {
+	raw := strings.TrimSpace(os.Getenv("ANALYSIS_MAX_SAMPLES"))
+	if raw == "" {
+		return defaultMaxEnsembleSamples
+	}
+	samples, err := strconv.Atoi(raw)
+	if err != nil || samples < 1 {
+		log.Printf("WARN: Invalid ANALYSIS_MAX_SAMPLES %q, using %d", raw, defaultMaxEnsembleSamples)
+		return defaultMaxEnsembleSamples
+	}
+	return samples
+}
+
+// sampleAnalysis runs one analysis call through the fallback chain and validation.
+func (g *GeminiClient) sampleAnalysis(ctx context.Context, contents []*genai.Content, config *genai.GenerateContentConfig, r rubric.Rubric) (*AnalysisResult, modelCall, error) {
+	var raw string
+	call, err := g.generateWithFallback(ctx, StepAnalysis, ModelChain(CapabilityAnalysis), contents, config, func(response *genai.GenerateContentResponse) error {
+		raw = strings.TrimSpace(response.Text())
+		if raw == "" {
+			return fmt.Errorf("empty analysis response")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, modelCall{}, err
+	}
+	result, err := g.finishAnalysis(ctx, call.Model, contents, config, raw, r)
+	if err != nil {
+		return nil, modelCall{}, fmt.Errorf("%s: %w", call.Model, err)
+	}
+	result.Model = call.Model
+	return result, call, nil
+}
+
+// ensembleAnalysis samples the analysis concurrently with varied seeds and
+// aggregates the answers. Failed samples are dropped; it fails only when
+// every sample does.
+func (g *GeminiClient) ensembleAnalysis(ctx context.Context, samples int, contents []*genai.Content, config *genai.GenerateContentConfig, r rubric.Rubric) (*AnalysisResult, modelCall, error) {
+	results := make([]*AnalysisResult, samples)
+	calls := make([]modelCall, samples)
+	errs := make([]error, samples)
+	var wg sync.WaitGroup
+	for i := range samples {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seeded := *config
+			seeded.Seed = genai.Ptr(rand.Int32())
+			results[i], calls[i], errs[i] = g.sampleAnalysis(ctx, contents, &seeded, r)
+		}()
+	}
+	wg.Wait()
+
+	var succeeded []*AnalysisResult
+	combined := modelCall{}
+	for i, result := range results {
+		if errs[i] != nil {
+			log.Printf("WARN: Ensemble sample %d/%d failed: %v", i+1, samples, errs[i])
+			continue
+		}
+		succeeded = append(succeeded, result)
+		if combined.Model == "" {
+			combined.Model = calls[i].Model
+		}
+		combined.Usage.Add(calls[i].Usage)
+	}
+	if len(succeeded) == 0 {
+		return nil, modelCall{}, fmt.Errorf("every ensemble sample failed: %w", errs[0])
+	}
+	aggregated := aggregateSamples(succeeded, r)
+	aggregated.Model = succeeded[0].Model
+	for _, result := range succeeded {
+		if result.Model != aggregated.Model {
+			aggregated.Model = ""
+		}
+	}
+	return aggregated, combined, nil
+}
+
+// aggregateSamples merges sampled analyses: each category gets the median
+// score and the comments of the sample closest to it; the texts and marks
+// come from the sample closest to the medians overall.
+func aggregateSamples(samples []*AnalysisResult, r rubric.Rubric) *AnalysisResult {
+	span := float64(r.Scale.Max - r.Scale.Min)
+	summary := &EnsembleSummary{Samples: len(samples), Categories: map[string]ScoreSpread{}}
+	medians := map[string]int{}
+	scores := map[string]CategoryScore{}
+	for _, key := range r.Keys() {
+		var values []int
+		for _, sample := range samples {
+			if score, ok := sample.Scores[key]; ok {
+				values = append(values, score.Score)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		spread := newScoreSpread(values, span)
+		summary.Categories[key] = spread
+		summary.Confidence += spread.Confidence
+		medians[key] = spread.Median
+
+		closest := CategoryScore{}
+		distance := math.MaxInt
+		for _, sample := range samples {
+			if score, ok := sample.Scores[key]; ok && abs(score.Score-spread.Median) < distance {
+				closest, distance = score, abs(score.Score-spread.Median)
+			}
+		}
+		closest.Score = spread.Median
+		scores[key] = closest
+	}
+	if len(summary.Categories) > 0 {
+		summary.Confidence /= float64(len(summary.Categories))
+	}
+
+	representative := samples[0]
+	best := math.MaxInt
+	var overalls, claims []int
+	for _, sample := range samples {
+		distance := 0
+		for key, median := range medians {
+			if score, ok := sample.Scores[key]; ok {
+				distance += abs(score.Score - median)
+			}
+		}
+		if distance < best {
+			representative, best = sample, distance
+		}
+		if overall, ok := weightedOverallScore(sample, r); ok {
+			overalls = append(overalls, overall)
+		}
+		claims = append(claims, sample.OverallScore)
+	}
+	if len(overalls) > 0 {
+		summary.Overall = newScoreSpread(overalls, span)
+	}
+
+	merged := *representative
+	merged.Scores = scores
+	merged.OverallScore = median(claims)
+	merged.Repaired = false
+	merged.ValidationIssues = nil
+	for _, sample := range samples {
+		merged.Repaired = merged.Repaired || sample.Repaired
+		merged.ValidationIssues = append(merged.ValidationIssues, sample.ValidationIssues...)
+	}
+	merged.Ensemble = summary
+	return &merged
+}
+
+func newScoreSpread(values []int, span float64) ScoreSpread {
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	low, high := sorted[0], sorted[len(sorted)-1]
+	spread := ScoreSpread{
+		Median:     median(sorted),
+		Min:        low,
+		Max:        high,
+		Spread:     (high - low + 1) / 2,
+		Confidence: 1,
+	}
+	if span > 0 {
+		spread.Confidence = math.Max(0, 1-float64(high-low)/span)
+		spread.Unstable = float64(high-low)/span > unstableRange
+	}
+	return spread
+}
+
+// median returns the middle value, rounding the mean of the two middle values.
+func median(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[middle]
+	}
+	return int(math.Round(float64(sorted[middle-1]+sorted[middle]) / 2))
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// UnstableScores describes the categories whose samples disagreed, e.g.
+// "構図 6 ± 2", in rubric order.
+func (a *AnalysisResult) UnstableScores(l locale.Locale) []string {
+	if a.Ensemble == nil {
+		return nil
+	}
+	var labels []string
+	for _, category := range resultRubric(a).Categories {
+		spread, ok := a.Ensemble.Categories[category.Key]
+		if ok && spread.Unstable {
+			labels = append(labels, fmt.Sprintf("%s %d ± %d", category.Name.In(l), spread.Median, spread.Spread))
+		}
+	}
+	return labels
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+)
+
+func TestAggregateSamples(t *testing.T) {
+	general := rubric.Default().ForGenre(rubric.General)
+	sample := func(composition int, comment string, claim int) *AnalysisResult {
+		result := &AnalysisResult{Summary: comment, OverallScore: claim, Scores: map[string]CategoryScore{}}
+		for _, key := range general.Keys() {
+			result.Scores[key] = CategoryScore{Score: 6, Comment: comment}
+		}
+		result.Scores["composition"] = CategoryScore{Score: composition, Comment: comment}
+		return result
+	}
+
+	merged := aggregateSamples([]*AnalysisResult{sample(4, "low", 5), sample(8, "high", 9), sample(7, "mid", 6)}, general)
+	composition := merged.Ensemble.Categories["composition"]
+	if merged.Scores["composition"].Score != 7 || merged.Scores["composition"].Comment != "mid" {
+		t.Errorf("composition = %+v, want the median sample's comment", merged.Scores["composition"])
+	}
+	if composition.Min != 4 || composition.Max != 8 || composition.Spread != 2 || !composition.Unstable {
+		t.Errorf("composition spread = %+v", composition)
+	}
+	if focus := merged.Ensemble.Categories["focus"]; focus.Unstable || focus.Confidence != 1 {
+		t.Errorf("focus spread = %+v", focus)
+	}
+	if merged.Summary != "mid" || merged.OverallScore != 6 || merged.Ensemble.Samples != 3 {
+		t.Errorf("merged = summary %q, overall %d, samples %d", merged.Summary, merged.OverallScore, merged.Ensemble.Samples)
+	}
+	if labels := merged.UnstableScores(locale.Japanese); len(labels) != 1 || labels[0] != "構図 7 ± 2" {
+		t.Errorf("unstable = %q", labels)
+	}
+
+	if got := median([]int{3, 8, 5, 6}); got != 6 {
+		t.Errorf("median = %d", got)
+	}
+}
+
+func TestFakeEnsemble(t *testing.T) {
+	ctx, trace := WithCallTrace(WithEnsemble(context.Background(), 3))
+	result, err := NewFakeModelProvider(nil).AnalyzeImage(ctx, "gs://memory/photo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Ensemble == nil || result.Ensemble.Samples != 3 {
+		t.Fatalf("ensemble = %+v", result.Ensemble)
+	}
+	if labels := result.UnstableScores(locale.Japanese); len(labels) != 1 || !strings.HasPrefix(labels[0], "構図") {
+		t.Errorf("unstable = %q", labels)
+	}
+	if calls := trace.Usage().ByStep[StepAnalysis].Calls; calls != 3 {
+		t.Errorf("analysis calls = %d, want 3", calls)
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// ExifInfo is the subset of a JPEG's EXIF metadata the rule checks use.
+type ExifInfo struct {
+	Make     string `json:"make,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Software string `json:"software,omitempty"`
+	// TakenAt is DateTimeOriginal, in the camera's local time.
+	TakenAt time.Time `json:"takenAt,omitempty"`
+}
+
+// EXIF tags read by ReadExif.
+const (
+	exifTagMake             = 0x010F
+	exifTagModel            = 0x0110
+	exifTagSoftware         = 0x0131
+	exifTagDateTime         = 0x0132
+	exifTagExifIFD          = 0x8769
+	exifTagDateTimeOriginal = 0x9003
+)
+
+const exifDateLayout = "2006:01:02 15:04:05"
+
+// ReadExif extracts camera, software and capture time from a JPEG's APP1
+// segment. ok is false for other formats and JPEGs without EXIF.
+func ReadExif(data []byte) (ExifInfo, bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return ExifInfo{}, false
+	}
+	for offset := 2; offset+4 <= len(data); {
+		if data[offset] != 0xFF {
+			return ExifInfo{}, false
+		}
+		marker := data[offset+1]
+		// Start of scan: the metadata segments are over.
+		if marker == 0xDA {
+			return ExifInfo{}, false
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		end := offset + 2 + length
+		if length < 2 || end > len(data) {
+			return ExifInfo{}, false
+		}
+		segment := data[offset+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return parseTIFF(segment[6:])
+		}
+		offset = end
+	}
+	return ExifInfo{}, false
+}
+
+// parseTIFF reads IFD0 and the EXIF sub-IFD of a TIFF structure.
+func parseTIFF(tiff []byte) (ExifInfo, bool) {
+	if len(tiff) < 8 {
+		return ExifInfo{}, false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return ExifInfo{}, false
+	}
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:]))
+	info := ExifInfo{
+		Make:     ifd0.text(exifTagMake),
+		Model:    ifd0.text(exifTagModel),
+		Software: ifd0.text(exifTagSoftware),
+	}
+	modified := ifd0.text(exifTagDateTime)
+	if pointer, ok := ifd0.values[exifTagExifIFD]; ok {
+		sub := readIFD(tiff, order, pointer)
+		if taken, err := time.Parse(exifDateLayout, sub.text(exifTagDateTimeOriginal)); err == nil {
+			info.TakenAt = taken
+		}
+	}
+	// Cameras that omit DateTimeOriginal still record the file time.
+	if info.TakenAt.IsZero() {
+		if taken, err := time.Parse(exifDateLayout, modified); err == nil {
+			info.TakenAt = taken
+		}
+	}
+	return info, true
+}
+
+// ifdEntries are the ASCII strings and LONG values of one IFD.
+type ifdEntries struct {
+	texts  map[uint16]string
+	values map[uint16]uint32
+}
+
+func (e ifdEntries) text(tag uint16) string {
+	return e.texts[tag]
+}
+
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) ifdEntries {
+	entries := ifdEntries{texts: map[uint16]string{}, values: map[uint16]uint32{}}
+	if int(offset)+2 > len(tiff) {
+		return entries
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := range count {
+		start := int(offset) + 2 + i*12
+		if start+12 > len(tiff) {
+			break
+		}
+		entry := tiff[start : start+12]
+		tag := order.Uint16(entry)
+		kind := order.Uint16(entry[2:])
+		size := order.Uint32(entry[4:])
+		switch kind {
+		case 2: // ASCII
+			value := entry[8:12]
+			if size > 4 {
+				pointer := order.Uint32(entry[8:])
+				if uint64(pointer)+uint64(size) > uint64(len(tiff)) {
+					continue
+				}
+				value = tiff[pointer : pointer+size]
+			}
+			entries.texts[tag] = strings.TrimSpace(strings.TrimRight(string(value[:min(int(size), len(value))]), "\x00"))
+		case 4: // LONG
+			entries.values[tag] = order.Uint32(entry[8:])
+		}
+	}
+	return entries
+}
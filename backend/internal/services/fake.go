@@ -0,0 +1,284 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/calibration"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/prompts"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/purpose"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/skill"
+)
+
+// FakeModelProvider is a deterministic, offline ModelProvider for tests and
+// local development (MODEL_PROVIDER=fake). When Store is set, enhancements
+// are a tone-shifted copy of the stored source photo so fidelity checks pass;
+// otherwise a gradient PNG is returned.
+type FakeModelProvider struct {
+	Store ObjectStore
+}
+
+// NewFakeModelProvider creates a fake provider that reads source photos from store (may be nil).
+func NewFakeModelProvider(store ObjectStore) *FakeModelProvider {
+	return &FakeModelProvider{Store: store}
+}
+
+var _ ModelProvider = (*FakeModelProvider)(nil)
+
+// FakeModelName is recorded as the serving model of every fake call.
+const FakeModelName = "fake"
+
+// FakeGenre is the genre the fake classifier detects for every photo.
+const FakeGenre = rubric.Street
+
+// fakeCategoryScores are the canned 0-10 scores; other categories score 6.
+var fakeCategoryScores = map[string]int{
+	"composition":   5,
+	"exposure":      7,
+	"color":         6,
+	"lighting":      7,
+	"focus":         6,
+	"development":   6,
+	"distance":      5,
+	"intentClarity": 5,
+}
+
+// FakeAnalysisResult returns the canned analysis used by FakeModelProvider,
+// scored with the general rubric.
+func FakeAnalysisResult() *AnalysisResult {
+	result := &AnalysisResult{
+		PhotoSummary:   "夕暮れの街角",
+		Summary:        "光の扱いは良いので、構図を整理するとさらに良くなります。",
+		OverallComment: "主題をはっきりさせると印象が強まります。",
+		OverallScore:   6,
+		Annotations: []Annotation{
+			{Type: AnnotationBox, Category: "composition", Label: "主題", Box: &NormalizedBox{X: 0.3, Y: 0.3, Width: 0.4, Height: 0.4}},
+			{Type: AnnotationPoint, Category: "lighting", Label: "ハイライト", Point: &NormalizedPoint{X: 0.8, Y: 0.2}},
+		},
+		Regions: []RegionCritique{
+			{Box: NormalizedBox{X: 0, Y: 0.7, Width: 0.3, Height: 0.3}, Category: "composition", Severity: SeverityMedium, Comment: "左下の要素が視線を散らしています。"},
+		},
+	}
+	fakeScore(result, rubric.Default().ForGenre(rubric.General), rubric.General, false)
+	return result
+}
+
+// fakeScore scores result with the canned values on r's scale.
+func fakeScore(result *AnalysisResult, r rubric.Rubric, genre rubric.Genre, detected bool) {
+	result.Scores = map[string]CategoryScore{}
+	for _, category := range r.Categories {
+		score, ok := fakeCategoryScores[category.Key]
+		if !ok {
+			score = 6
+		}
+		name := category.Name.In(locale.Default)
+		result.Scores[category.Key] = CategoryScore{
+			Score:       rescaleScore(score, r.Scale),
+			Comment:     name + "は概ね良好です。",
+			Improvement: name + "をもう一段整えましょう。",
+		}
+	}
+	result.OverallScore = rescaleScore(6, r.Scale)
+	result.applyRubric(r, genre, detected)
+}
+
+func (f *FakeModelProvider) AnalyzeImage(ctx context.Context, imageURL string) (*AnalysisResult, error) {
+	if strings.TrimSpace(imageURL) == "" {
+		return nil, fmt.Errorf("image url is required")
+	}
+	analysisRubric, genre, chosen := chosenRubric(ctx)
+	if !chosen {
+		recordFakeCall(ctx, StepGenre, 0)
+		analysisRubric, genre = rubric.Default().ForGenre(FakeGenre), FakeGenre
+	}
+	samples := EnsembleSize(ctx)
+	var sampled []*AnalysisResult
+	for i := range samples {
+		recordFakeCall(ctx, StepAnalysis, 0)
+		sample := FakeAnalysisResult()
+		fakeScore(sample, analysisRubric, genre, !chosen)
+		if samples > 1 {
+			// Ensembles disagree on composition so the spread is visible.
+			composition := sample.Scores["composition"]
+			composition.Score = max(analysisRubric.Scale.Min, min(analysisRubric.Scale.Max, composition.Score+fakeEnsembleOffsets[i%len(fakeEnsembleOffsets)]))
+			sample.Scores["composition"] = composition
+		}
+		sampled = append(sampled, sample)
+	}
+	result := sampled[0]
+	if samples > 1 {
+		result = aggregateSamples(sampled, analysisRubric)
+		result.applyRubric(analysisRubric, genre, !chosen)
+	}
+	result.PromptVersion = prompts.Default().VersionContext(ctx, prompts.Analysis)
+	result.Model = FakeModelName
+	result.SkillLevel = string(skill.FromContext(ctx))
+	result.Purpose = string(purpose.FromContext(ctx))
+	if BriefFromContext(ctx) != "" {
+		result.Theme = &ThemeEvaluation{
+			Score:      rescaleScore(6, analysisRubric.Scale),
+			Comment:    "テーマは読み取れますが、主題との結び付きをもう一歩強められます。",
+			Violations: []RuleViolation{},
+		}
+	}
+	result.applyCalibration(calibration.Default(), analysisRubric)
+	return result, nil
+}
+
+// fakeEnsembleOffsets shift the composition score of successive ensemble samples.
+var fakeEnsembleOffsets = []int{0, -2, 2, -1, 1}
+
+func (f *FakeModelProvider) CompareAndAdvise(ctx context.Context, originalURL, transformedURL, analysisJSON string) (string, error) {
+	recordFakeCall(ctx, StepComparison, 0)
+	return "改善版では主題の周囲を整理し、露出を半段持ち上げています。", nil
+}
+
+func (f *FakeModelProvider) GenerateImage(ctx context.Context, prompt string) (*ImageGenerationResult, error) {
+	recordFakeCall(ctx, StepGeneration, 1)
+	return fakeGeneratedImage(gradientImage(256, 256), "fake image for: "+prompt)
+}
+
+func (f *FakeModelProvider) EnhancePhoto(ctx context.Context, input EnhancementInput) (*ImageGenerationResult, error) {
+	return f.enhance(ctx, StepAnnotatedEnhancement, input)
+}
+
+func (f *FakeModelProvider) EnhancePhotoClean(ctx context.Context, input EnhancementInput) (*ImageGenerationResult, error) {
+	return f.enhance(ctx, StepCleanEnhancement, input)
+}
+
+// enhance brightens the stored source photo, or falls back to a gradient.
+func (f *FakeModelProvider) enhance(ctx context.Context, step string, input EnhancementInput) (*ImageGenerationResult, error) {
+	source := image.Image(gradientImage(256, 256))
+	if f.Store != nil && strings.HasPrefix(input.ImageURL, "gs://") {
+		parts := strings.SplitN(strings.TrimPrefix(input.ImageURL, "gs://"), "/", 2)
+		if len(parts) == 2 {
+			reader, _, _, err := f.Store.OpenObject(ctx, parts[1])
+			if err != nil {
+				return nil, err
+			}
+			decoded, _, err := image.Decode(reader)
+			reader.Close()
+			if err != nil {
+				return nil, err
+			}
+			source = decoded
+		}
+	}
+
+	bounds := source.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			r, g, b, _ := source.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			out.SetRGBA(x, y, color.RGBA{brighten(r), brighten(g), brighten(b), 255})
+		}
+	}
+	recordFakeCall(ctx, step, 1)
+	return fakeGeneratedImage(out, "fake enhancement")
+}
+
+// recordFakeCall records a fake call on the context's trace with nominal token counts.
+func recordFakeCall(ctx context.Context, step string, images int) {
+	trace := CallTraceFrom(ctx)
+	trace.RecordModel(step, FakeModelName)
+	trace.RecordUsage(step, FakeModelName, UsageTotals{Calls: 1, InputTokens: 1000, OutputTokens: 200, Images: images})
+}
+
+func brighten(v uint32) uint8 {
+	return uint8(min(255, int(v>>8)*9/10+30))
+}
+
+func gradientImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(x * 255 / width), uint8(y * 255 / height), 128, 255})
+		}
+	}
+	return img
+}
+
+func fakeGeneratedImage(img image.Image, reasoning string) (*ImageGenerationResult, error) {
+	buffer := &bytes.Buffer{}
+	if err := png.Encode(buffer, img); err != nil {
+		return nil, err
+	}
+	return &ImageGenerationResult{
+		ImageBase64: base64.StdEncoding.EncodeToString(buffer.Bytes()),
+		MIMEType:    "image/png",
+		Reasoning:   reasoning,
+	}, nil
+}
+
+// MemoryObjectStore is an in-process ObjectStore for tests and local
+// development (STORAGE_BACKEND=memory). URLs use the "memory" bucket.
+type MemoryObjectStore struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data        []byte
+	contentType string
+}
+
+const memoryBucket = "memory"
+
+// NewMemoryObjectStore creates an empty in-memory object store.
+func NewMemoryObjectStore() *MemoryObjectStore {
+	return &MemoryObjectStore{objects: map[string]memoryObject{}}
+}
+
+var _ ObjectStore = (*MemoryObjectStore)(nil)
+
+func (m *MemoryObjectStore) UploadImage(ctx context.Context, data []byte, contentType string) (string, error) {
+	url, _, err := m.UploadImageWithPrefix(ctx, data, contentType, "uploads")
+	return url, err
+}
+
+func (m *MemoryObjectStore) UploadImageWithPrefix(ctx context.Context, data []byte, contentType, prefix string) (string, string, error) {
+	trimmedPrefix := strings.Trim(prefix, "/")
+	if trimmedPrefix == "" {
+		trimmedPrefix = "uploads"
+	}
+
+	objectName := fmt.Sprintf("%s/%s", trimmedPrefix, uuid.NewString())
+	m.mu.Lock()
+	m.objects[objectName] = memoryObject{data: append([]byte(nil), data...), contentType: contentType}
+	m.mu.Unlock()
+
+	return fmt.Sprintf("gs://%s/%s", memoryBucket, objectName), objectName, nil
+}
+
+func (m *MemoryObjectStore) OpenObject(ctx context.Context, objectName string) (io.ReadCloser, string, int64, error) {
+	m.mu.RLock()
+	object, ok := m.objects[objectName]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, "", 0, fmt.Errorf("object not found: %s", objectName)
+	}
+	return io.NopCloser(bytes.NewReader(object.data)), object.contentType, int64(len(object.data)), nil
+}
+
+// ObjectNames lists the stored object names.
+func (m *MemoryObjectStore) ObjectNames() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.objects))
+	for name := range m.objects {
+		names = append(names, name)
+	}
+	return names
+}
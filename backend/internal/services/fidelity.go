@@ -0,0 +1,270 @@
+package services
+
+import (
+	"image"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// fidelitySampleEdge is the long edge both images are resampled to before comparison.
+	fidelitySampleEdge = 256
+	// fidelityGrid splits the frame into fidelityGrid x fidelityGrid tiles for local scores.
+	fidelityGrid = 4
+	// ssimWindow is the side of the square SSIM window in sample pixels.
+	ssimWindow = 8
+	// edgeQuantile selects the strongest gradients (top 12%) as edges.
+	edgeQuantile = 0.88
+
+	// defaultFidelityThreshold sits between tone-only edits, which score
+	// close to 1, and an object added to one tile, which scores about 0.5.
+	defaultFidelityThreshold = 0.65
+)
+
+// FidelityMode controls what happens to an enhancement that fails the fidelity check.
+type FidelityMode string
+
+const (
+	// FidelityModeFlag keeps the image but marks it as not faithful.
+	FidelityModeFlag FidelityMode = "flag"
+	// FidelityModeReject discards the image.
+	FidelityModeReject FidelityMode = "reject"
+)
+
+// FidelityResult describes how faithfully an enhanced image preserves the
+// content of the original photo.
+type FidelityResult struct {
+	Score          float64 `json:"score"`
+	SSIM           float64 `json:"ssim"`
+	EdgeOverlap    float64 `json:"edgeOverlap"`
+	WorstTileScore float64 `json:"worstTileScore"`
+	Threshold      float64 `json:"threshold"`
+	Passed         bool    `json:"passed"`
+	Retried        bool    `json:"retried"`
+}
+
+// FidelityThreshold returns the minimum acceptable fidelity score (FIDELITY_THRESHOLD).
+func FidelityThreshold() float64 {
+	raw := strings.TrimSpace(os.Getenv("FIDELITY_THRESHOLD"))
+	if raw == "" {
+		return defaultFidelityThreshold
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || value < 0 || value > 1 {
+		log.Printf("WARN: Invalid FIDELITY_THRESHOLD %q, using %.2f", raw, defaultFidelityThreshold)
+		return defaultFidelityThreshold
+	}
+	return value
+}
+
+// CurrentFidelityMode returns the configured handling for failed checks (FIDELITY_MODE).
+func CurrentFidelityMode() FidelityMode {
+	if FidelityMode(strings.ToLower(strings.TrimSpace(os.Getenv("FIDELITY_MODE")))) == FidelityModeReject {
+		return FidelityModeReject
+	}
+	return FidelityModeFlag
+}
+
+// MeasureFidelity compares original and enhanced at a matched size using SSIM
+// on normalized luminance and the overlap of their edge maps. Global tone
+// changes are factored out, so retouching scores high while added, removed or
+// reshaped objects pull the local tile scores down.
+func MeasureFidelity(original, enhanced image.Image, threshold float64) *FidelityResult {
+	width, height := sampleDimensions(original.Bounds(), fidelitySampleEdge)
+	if width < ssimWindow || height < ssimWindow {
+		return &FidelityResult{Score: 1, SSIM: 1, EdgeOverlap: 1, WorstTileScore: 1, Threshold: threshold, Passed: true}
+	}
+
+	lumA := normalizedLuminance(resampleRGBA(original, width, height))
+	lumB := normalizedLuminance(resampleRGBA(enhanced, width, height))
+	edgesA := edgeMap(lumA, width, height)
+	edgesB := edgeMap(lumB, width, height)
+
+	ssim := meanSSIM(lumA, lumB, width, image.Rect(0, 0, width, height))
+	edgeOverlap := edgeF1(edgesA, edgesB, width, height, image.Rect(0, 0, width, height))
+
+	worst := 1.0
+	total := 0.0
+	tiles := 0
+	for ty := 0; ty < fidelityGrid; ty++ {
+		for tx := 0; tx < fidelityGrid; tx++ {
+			rect := image.Rect(tx*width/fidelityGrid, ty*height/fidelityGrid, (tx+1)*width/fidelityGrid, (ty+1)*height/fidelityGrid)
+			score := combineFidelity(meanSSIM(lumA, lumB, width, rect), edgeF1(edgesA, edgesB, width, height, rect))
+			worst = math.Min(worst, score)
+			total += score
+			tiles++
+		}
+	}
+
+	score := 0.5*(total/float64(tiles)) + 0.5*worst
+	return &FidelityResult{
+		Score:          roundTo(score, 3),
+		SSIM:           roundTo(ssim, 3),
+		EdgeOverlap:    roundTo(edgeOverlap, 3),
+		WorstTileScore: roundTo(worst, 3),
+		Threshold:      threshold,
+		Passed:         score >= threshold,
+	}
+}
+
+func combineFidelity(ssim, edgeOverlap float64) float64 {
+	return 0.5*math.Max(ssim, 0) + 0.5*edgeOverlap
+}
+
+// normalizedLuminance returns Rec.601 luminance scaled to zero mean and unit variance.
+func normalizedLuminance(img *image.RGBA) []float64 {
+	bounds := img.Bounds()
+	lum := make([]float64, 0, bounds.Dx()*bounds.Dy())
+	sum := 0.0
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			i := img.PixOffset(x, y)
+			v := 0.299*float64(img.Pix[i]) + 0.587*float64(img.Pix[i+1]) + 0.114*float64(img.Pix[i+2])
+			lum = append(lum, v)
+			sum += v
+		}
+	}
+	mean := sum / float64(len(lum))
+	variance := 0.0
+	for _, v := range lum {
+		variance += (v - mean) * (v - mean)
+	}
+	std := math.Sqrt(variance / float64(len(lum)))
+	if std < 1e-6 {
+		std = 1
+	}
+	for i := range lum {
+		lum[i] = (lum[i] - mean) / std
+	}
+	return lum
+}
+
+// meanSSIM averages SSIM over half-overlapping windows inside rect.
+func meanSSIM(a, b []float64, stride int, rect image.Rectangle) float64 {
+	// Stabilizers for the normalized range (roughly ±3).
+	const c1 = 0.01 * 0.01 * 36
+	const c2 = 0.03 * 0.03 * 36
+
+	total := 0.0
+	windows := 0
+	step := ssimWindow / 2
+	for y := rect.Min.Y; y+ssimWindow <= rect.Max.Y; y += step {
+		for x := rect.Min.X; x+ssimWindow <= rect.Max.X; x += step {
+			var meanA, meanB float64
+			for wy := 0; wy < ssimWindow; wy++ {
+				for wx := 0; wx < ssimWindow; wx++ {
+					i := (y+wy)*stride + x + wx
+					meanA += a[i]
+					meanB += b[i]
+				}
+			}
+			n := float64(ssimWindow * ssimWindow)
+			meanA /= n
+			meanB /= n
+			var varA, varB, cov float64
+			for wy := 0; wy < ssimWindow; wy++ {
+				for wx := 0; wx < ssimWindow; wx++ {
+					i := (y+wy)*stride + x + wx
+					da := a[i] - meanA
+					db := b[i] - meanB
+					varA += da * da
+					varB += db * db
+					cov += da * db
+				}
+			}
+			varA /= n - 1
+			varB /= n - 1
+			cov /= n - 1
+			total += ((2*meanA*meanB + c1) * (2*cov + c2)) / ((meanA*meanA + meanB*meanB + c1) * (varA + varB + c2))
+			windows++
+		}
+	}
+	if windows == 0 {
+		return 1
+	}
+	return total / float64(windows)
+}
+
+// edgeMap marks the strongest Sobel gradients of lum.
+func edgeMap(lum []float64, width, height int) []bool {
+	magnitude := make([]float64, width*height)
+	at := func(x, y int) float64 {
+		x = min(max(x, 0), width-1)
+		y = min(max(y, 0), height-1)
+		return lum[y*width+x]
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+			magnitude[y*width+x] = math.Hypot(gx, gy)
+		}
+	}
+
+	sorted := append([]float64(nil), magnitude...)
+	sort.Float64s(sorted)
+	cutoff := sorted[int(float64(len(sorted)-1)*edgeQuantile)]
+	edges := make([]bool, len(magnitude))
+	for i, m := range magnitude {
+		edges[i] = m > cutoff && m > 0.5
+	}
+	return edges
+}
+
+// edgeF1 is the F1 overlap of two edge maps inside rect, tolerating 1px misalignment.
+func edgeF1(a, b []bool, width, height int, rect image.Rectangle) float64 {
+	near := func(edges []bool, x, y int) bool {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				nx, ny := x+dx, y+dy
+				if nx >= 0 && ny >= 0 && nx < width && ny < height && edges[ny*width+nx] {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var countA, countB, matchedA, matchedB int
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			i := y*width + x
+			if a[i] {
+				countA++
+				if near(b, x, y) {
+					matchedA++
+				}
+			}
+			if b[i] {
+				countB++
+				if near(a, x, y) {
+					matchedB++
+				}
+			}
+		}
+	}
+
+	// Flat tiles carry no structural evidence either way.
+	const minEdges = 8
+	if countA < minEdges && countB < minEdges {
+		return 1
+	}
+	if countA == 0 || countB == 0 {
+		return 0
+	}
+	recall := float64(matchedA) / float64(countA)
+	precision := float64(matchedB) / float64(countB)
+	if recall+precision == 0 {
+		return 0
+	}
+	return 2 * recall * precision / (recall + precision)
+}
+
+func roundTo(v float64, digits int) float64 {
+	scale := math.Pow(10, float64(digits))
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestMeasureFidelity(t *testing.T) {
+	original := syntheticScene(256, 192)
+
+	toned := image.NewRGBA(original.Bounds())
+	for y := 0; y < 192; y++ {
+		for x := 0; x < 256; x++ {
+			c := original.RGBAAt(x, y)
+			lift := func(v uint8) uint8 { return uint8(math.Min(255, 30+float64(v)*0.9)) }
+			toned.SetRGBA(x, y, color.RGBA{lift(c.R), lift(c.G), c.B, 255})
+		}
+	}
+
+	hallucinated := image.NewRGBA(original.Bounds())
+	copy(hallucinated.Pix, original.Pix)
+	for y := 20; y < 80; y++ {
+		for x := 150; x < 230; x++ {
+			if (x/6+y/6)%2 == 0 {
+				hallucinated.SetRGBA(x, y, color.RGBA{240, 240, 240, 255})
+			} else {
+				hallucinated.SetRGBA(x, y, color.RGBA{10, 10, 10, 255})
+			}
+		}
+	}
+
+	threshold := defaultFidelityThreshold
+	tonedResult := MeasureFidelity(original, toned, threshold)
+	if !tonedResult.Passed {
+		t.Errorf("tone-only edit failed fidelity check: %+v", tonedResult)
+	}
+	hallucinatedResult := MeasureFidelity(original, hallucinated, threshold)
+	if hallucinatedResult.Passed {
+		t.Errorf("added object passed the default fidelity threshold %.2f: %+v", threshold, hallucinatedResult)
+	}
+	if hallucinatedResult.WorstTileScore >= tonedResult.WorstTileScore {
+		t.Errorf("added object did not lower the worst tile score: toned=%+v hallucinated=%+v", tonedResult, hallucinatedResult)
+	}
+	if hallucinatedResult.Score >= tonedResult.Score {
+		t.Errorf("added object did not lower the score: toned=%.3f hallucinated=%.3f", tonedResult.Score, hallucinatedResult.Score)
+	}
+}
+
+// syntheticScene draws smooth gradients with a few hard-edged shapes.
+func syntheticScene(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{uint8(x * 255 / width), uint8(y * 255 / height), 120, 255}
+			if dx, dy := x-70, y-100; dx*dx+dy*dy < 40*40 {
+				c = color.RGBA{200, 60, 40, 255}
+			}
+			if x > 140 && x < 240 && y > 120 && y < 170 {
+				c = color.RGBA{30, 50, 160, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
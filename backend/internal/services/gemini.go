@@ -1,39 +1,145 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 
 	"cloud.google.com/go/storage"
 	"google.golang.org/genai"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/calibration"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/prompts"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/purpose"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/skill"
 )
 
 type GeminiClient struct {
-	client *genai.Client
+	mu         sync.Mutex
+	client     *genai.Client
+	resilience *Resilience
+	// cache serves repeated analyses and enhancements; nil disables it.
+	cache ResultCache
 }
 
 type AnalysisResult struct {
-	PhotoSummary   string        `json:"photoSummary"`
-	Summary        string        `json:"summary"`
-	OverallComment string        `json:"overallComment"`
-	OverallScore   int           `json:"overallScore"`
-	Composition    CategoryScore `json:"composition"`
-	Exposure       CategoryScore `json:"exposure"`
-	Color          CategoryScore `json:"color"`
-	Lighting       CategoryScore `json:"lighting"`
-	Focus          CategoryScore `json:"focus"`
-	Development    CategoryScore `json:"development"`
-	Distance       CategoryScore `json:"distance"`
-	IntentClarity  CategoryScore `json:"intentClarity"`
+	PhotoSummary   string `json:"photoSummary"`
+	Summary        string `json:"summary"`
+	OverallComment string `json:"overallComment"`
+	// OverallScore is computed locally from the category scores and the
+	// rubric weights; ModelOverallScore is what the model claimed.
+	OverallScore      int `json:"overallScore"`
+	ModelOverallScore int `json:"modelOverallScore"`
+	// Scores holds every category of the rubric, keyed by category key.
+	Scores map[string]CategoryScore `json:"scores,omitempty"`
+	// The eight core categories mirror Scores when the rubric has them, so
+	// clients written for the fixed categories keep working.
+	Composition   CategoryScore `json:"composition"`
+	Exposure      CategoryScore `json:"exposure"`
+	Color         CategoryScore `json:"color"`
+	Lighting      CategoryScore `json:"lighting"`
+	Focus         CategoryScore `json:"focus"`
+	Development   CategoryScore `json:"development"`
+	Distance      CategoryScore `json:"distance"`
+	IntentClarity CategoryScore `json:"intentClarity"`
+	// Scale is the range of every score; nil means 0-10.
+	Scale *rubric.Scale `json:"scale,omitempty"`
+	// Genre is the genre the photo was judged as; GenreDetected is false
+	// when the user chose it.
+	Genre         string `json:"genre,omitempty"`
+	GenreDetected bool   `json:"genreDetected,omitempty"`
+	// RubricID identifies the scoring rubric applied.
+	RubricID string `json:"rubricId,omitempty"`
+	// Annotations are red-pen marks rendered locally onto the photo.
+	Annotations []Annotation `json:"annotations,omitempty"`
+	// Regions locate the critique: where in the frame each problem is.
+	Regions []RegionCritique `json:"regions,omitempty"`
+	// PromptVersion identifies the analysis prompt template that produced the scores.
+	PromptVersion string `json:"promptVersion,omitempty"`
+	// Model is the analysis model that gave the raw scores; empty when
+	// ensemble samples were served by different models.
+	Model string `json:"model,omitempty"`
+	// Ensemble describes the agreement of the samples when the analysis
+	// was sampled several times; scores are then the per-category medians.
+	Ensemble *EnsembleSummary `json:"ensemble,omitempty"`
+	// Repaired is true when the model had to correct its first answer;
+	// ValidationIssues lists what was still wrong after that.
+	Repaired         bool     `json:"repaired,omitempty"`
+	ValidationIssues []string `json:"validationIssues,omitempty"`
+	// Purpose is what the photo was judged for: contest, social, print or portfolio.
+	Purpose string `json:"purpose,omitempty"`
+	// SkillLevel is the photographer level the critique was written for.
+	SkillLevel string `json:"skillLevel,omitempty"`
+	// CalibrationID identifies the calibration that mapped the scores;
+	// RawScores and RawOverallScore are what the model gave before it.
+	// CalibrationSkipped says why a loaded calibration left the scores raw.
+	CalibrationID      string         `json:"calibrationId,omitempty"`
+	CalibrationSkipped string         `json:"calibrationSkipped,omitempty"`
+	RawScores          map[string]int `json:"rawScores,omitempty"`
+	RawOverallScore    int            `json:"rawOverallScore,omitempty"`
+	// Theme judges the photo against the contest theme or rules it was
+	// analyzed with; nil when none was given.
+	Theme *ThemeEvaluation `json:"theme,omitempty"`
+}
+
+// coreCategories maps the keys of the eight core categories to their fields.
+func (a *AnalysisResult) coreCategories() map[string]*CategoryScore {
+	return map[string]*CategoryScore{
+		"composition":   &a.Composition,
+		"exposure":      &a.Exposure,
+		"color":         &a.Color,
+		"lighting":      &a.Lighting,
+		"focus":         &a.Focus,
+		"development":   &a.Development,
+		"distance":      &a.Distance,
+		"intentClarity": &a.IntentClarity,
+	}
+}
+
+// Categories returns the scored categories keyed by category key. Results
+// stored before rubrics existed only have the eight core fields.
+func (a *AnalysisResult) Categories() map[string]CategoryScore {
+	categories := map[string]CategoryScore{}
+	if len(a.Scores) == 0 {
+		for key, score := range a.coreCategories() {
+			categories[key] = *score
+		}
+		return categories
+	}
+	for key, score := range a.Scores {
+		categories[key] = score
+	}
+	return categories
+}
+
+// syncCoreCategories copies the core categories in Scores to their fields.
+func (a *AnalysisResult) syncCoreCategories() {
+	for key, field := range a.coreCategories() {
+		if score, ok := a.Scores[key]; ok {
+			*field = score
+		}
+	}
+}
+
+// MaxScore returns the top of the score scale.
+func (a *AnalysisResult) MaxScore() int {
+	if a.Scale != nil && a.Scale.Max > 0 {
+		return a.Scale.Max
+	}
+	return 10
 }
 
 type CategoryScore struct {
@@ -42,22 +148,52 @@ type CategoryScore struct {
 	Improvement string `json:"improvement"`
 }
 
+// Region critique severities.
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// RegionCritique is a comment about one area of the photo.
+type RegionCritique struct {
+	Box      NormalizedBox `json:"box"`
+	Category string        `json:"category"`
+	Severity string        `json:"severity"`
+	Comment  string        `json:"comment"`
+}
+
 type EnhancementInput struct {
 	ImageURL    string
 	Analysis    *AnalysisResult
 	CustomNotes string
+	// Strict asks the model to keep every object and the framing untouched;
+	// used when retrying after a failed fidelity check.
+	Strict bool
 }
 
 type ImageGenerationResult struct {
 	ImageBase64 string
-	Reasoning   string
+	// MIMEType is the format the model actually returned, sniffed from the bytes.
+	MIMEType  string
+	Reasoning string
 }
 
 func NewGeminiClient() *GeminiClient {
-	return &GeminiClient{}
+	return &GeminiClient{
+		resilience: NewResilience(DefaultResiliencePolicy()),
+		cache:      NewMemoryResultCache(defaultMemoryCacheEntries),
+	}
+}
+
+// SetCache replaces the result cache; nil disables caching.
+func (g *GeminiClient) SetCache(cache ResultCache) {
+	g.cache = cache
 }
 
 func (g *GeminiClient) Ensure(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	if g.client != nil {
 		return nil
 	}
@@ -79,6 +215,17 @@ func (g *GeminiClient) Ensure(ctx context.Context) error {
 	return nil
 }
 
+// generateContent calls the model through the retry and circuit breaker layer.
+func (g *GeminiClient) generateContent(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error) {
+	var response *genai.GenerateContentResponse
+	err := g.resilience.Do(ctx, model, func(ctx context.Context) error {
+		var err error
+		response, err = g.client.Models.GenerateContent(ctx, model, contents, config)
+		return err
+	})
+	return response, err
+}
+
 // fetchImageBytes fetches image data from GCS URL or HTTP URL and returns the bytes
 func fetchImageBytes(ctx context.Context, imageURL string) ([]byte, string, error) {
 	if strings.HasPrefix(imageURL, "gs://") {
@@ -188,14 +335,11 @@ func (g *GeminiClient) AnalyzeImage(ctx context.Context, imageURL string) (*Anal
 	}
 	log.Printf("DEBUG: Fetched image data (%d bytes, %s) for analysis", len(imageData), mimeType)
 
-	analysisPrompt := strings.Join([]string{
-		"あなたは写真講評のプロです。次の写真を詳細に評価してください。",
-		"採点項目は構図、露出、色彩、ライティング、ピント、現像、距離感、意図の明確さの8項目です。",
-		"各項目は0〜10点で採点し、短い講評コメントと具体的な改善提案を必ず記述してください。",
-		"また、写真の内容を一言でまとめたタイトル(photoSummary)を作成してください。",
-		"全体サマリーと総合コメント、平均点(0〜10)も作成してください。",
-		"出力は日本語で、指定されたJSONスキーマに厳密に従ってください。",
-	}, "\n")
+	analysisRubric, genre, detected := g.analysisRubric(ctx, imageData, mimeType)
+	analysisPrompt, promptVersion, err := prompts.Default().RenderContext(ctx, prompts.Analysis, analysisData(ctx, analysisRubric, genre))
+	if err != nil {
+		return nil, err
+	}
 	contents := []*genai.Content{
 		genai.NewContentFromParts([]*genai.Part{
 			genai.NewPartFromText(analysisPrompt),
@@ -203,29 +347,53 @@ func (g *GeminiClient) AnalyzeImage(ctx context.Context, imageURL string) (*Anal
 		}, genai.RoleUser),
 	}
 
-	response, err := g.client.Models.GenerateContent(ctx, modelName(), contents, &genai.GenerateContentConfig{
+	chain := ModelChain(CapabilityAnalysis)
+	// The rendered prompt carries the skill level, purpose, print size and brief.
+	cacheExtra := []string{rubricFingerprint(analysisRubric), string(genre), analysisPrompt}
+	samples := EnsembleSize(ctx)
+	if samples > 1 {
+		cacheExtra = append(cacheExtra, fmt.Sprintf("samples=%d", samples))
+	}
+	cacheKey := resultCacheKey(StepAnalysis, imageData, chain, promptVersion, cacheExtra...)
+	var result AnalysisResult
+	if g.lookupCached(ctx, StepAnalysis, cacheKey, &result) {
+		result.GenreDetected = detected
+		result.applyCalibration(calibration.Default(), analysisRubric)
+		return &result, nil
+	}
+
+	schema := analysisResponseSchema(locale.FromContext(ctx).Locale, analysisRubric)
+	if BriefFromContext(ctx) != "" {
+		schema = withThemeSchema(schema, locale.FromContext(ctx).Locale, analysisRubric.Scale)
+	}
+	config := &genai.GenerateContentConfig{
 		ResponseMIMEType: "application/json",
-		ResponseSchema:   analysisResponseSchema(),
+		ResponseSchema:   schema,
 		Tools: []*genai.Tool{
 			{CodeExecution: &genai.ToolCodeExecution{}},
 		},
-	})
-	if err != nil {
-		log.Printf("ERROR: AnalyzeImage GenerateContent failed: %v", err)
-		return nil, err
 	}
-
-	text := strings.TrimSpace(response.Text())
-	if text == "" {
-		log.Printf("ERROR: AnalyzeImage returned empty response")
-		return nil, errors.New("empty analysis response")
+	var (
+		finished *AnalysisResult
+		call     modelCall
+	)
+	if samples > 1 {
+		finished, call, err = g.ensembleAnalysis(ctx, samples, contents, config, analysisRubric)
+	} else {
+		finished, call, err = g.sampleAnalysis(ctx, contents, config, analysisRubric)
 	}
-
-	var result AnalysisResult
-	if err := json.Unmarshal([]byte(text), &result); err != nil {
-		log.Printf("ERROR: AnalyzeImage JSON unmarshal failed: %v", err)
-		return nil, fmt.Errorf("analysis response parse failed: %w", err)
+	if err != nil {
+		log.Printf("ERROR: AnalyzeImage failed: %v", err)
+		return nil, err
 	}
+	result = *finished
+	result.applyRubric(analysisRubric, genre, detected)
+	result.PromptVersion = promptVersion
+	result.SkillLevel = string(skill.FromContext(ctx))
+	result.Purpose = string(purpose.FromContext(ctx))
+	// The cache keeps raw scores so a new calibration applies to cached results too.
+	g.storeCached(ctx, StepAnalysis, cacheKey, call, &result)
+	result.applyCalibration(calibration.Default(), analysisRubric)
 	return &result, nil
 }
 
@@ -254,7 +422,10 @@ func (g *GeminiClient) CompareAndAdvise(ctx context.Context, originalURL, transf
 		}
 	}
 
-	prompt := fmt.Sprintf("元の写真と改善案の写真を比較し、改善点とアドバイスを具体的に説明してください。\n分析結果: %s", analysisText)
+	prompt, _, err := prompts.Default().RenderContext(ctx, prompts.Compare, prompts.CompareData{Analysis: analysisText})
+	if err != nil {
+		return "", err
+	}
 	contents := []*genai.Content{
 		genai.NewContentFromParts([]*genai.Part{
 			genai.NewPartFromText(prompt),
@@ -263,12 +434,19 @@ func (g *GeminiClient) CompareAndAdvise(ctx context.Context, originalURL, transf
 		}, genai.RoleUser),
 	}
 
-	response, err := g.client.Models.GenerateContent(ctx, modelName(), contents, &genai.GenerateContentConfig{})
+	var advice string
+	_, err = g.generateWithFallback(ctx, StepComparison, ModelChain(CapabilityComparison), contents, &genai.GenerateContentConfig{}, func(response *genai.GenerateContentResponse) error {
+		advice = strings.TrimSpace(response.Text())
+		if advice == "" {
+			return errors.New("empty comparison response")
+		}
+		return nil
+	})
 	if err != nil {
 		return "", err
 	}
 
-	return fixMarkdownBold(strings.TrimSpace(response.Text())), nil
+	return fixMarkdownBold(advice), nil
 }
 
 func (g *GeminiClient) GenerateImage(ctx context.Context, prompt string) (*ImageGenerationResult, error) {
@@ -277,45 +455,19 @@ func (g *GeminiClient) GenerateImage(ctx context.Context, prompt string) (*Image
 	}
 
 	config := &genai.GenerateContentConfig{ResponseModalities: []string{"IMAGE", "TEXT"}}
-	response, err := g.client.Models.GenerateContent(ctx, "gemini-3-pro-image-preview", genai.Text(prompt), config)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(response.Candidates) == 0 || response.Candidates[0].Content == nil {
-		return nil, errors.New("empty image generation response")
-	}
-
-	var imageBase64 string
-	var reasoning string
-	for _, part := range response.Candidates[0].Content.Parts {
-		if part.InlineData != nil {
-			imageBase64 = base64.StdEncoding.EncodeToString(part.InlineData.Data)
-		}
-		if part.Text != "" {
-			reasoning += part.Text
-		}
-	}
-
-	if imageBase64 == "" {
-		return nil, errors.New("image data missing in response")
-	}
-
-	return &ImageGenerationResult{
-		ImageBase64: imageBase64,
-		Reasoning:   strings.TrimSpace(reasoning),
-	}, nil
+	result, _, err := g.generateImageWithFallback(ctx, StepGeneration, genai.Text(prompt), config)
+	return result, err
 }
 
 func (g *GeminiClient) EnhancePhoto(ctx context.Context, input EnhancementInput) (*ImageGenerationResult, error) {
-	return g.enhancePhotoWithPrompt(ctx, input, buildEnhancementPrompt)
+	return g.enhancePhotoWithPrompt(ctx, StepAnnotatedEnhancement, input, buildEnhancementPrompt)
 }
 
 func (g *GeminiClient) EnhancePhotoClean(ctx context.Context, input EnhancementInput) (*ImageGenerationResult, error) {
-	return g.enhancePhotoWithPrompt(ctx, input, buildCleanEnhancementPrompt)
+	return g.enhancePhotoWithPrompt(ctx, StepCleanEnhancement, input, buildCleanEnhancementPrompt)
 }
 
-func (g *GeminiClient) enhancePhotoWithPrompt(ctx context.Context, input EnhancementInput, promptBuilder func(EnhancementInput) string) (*ImageGenerationResult, error) {
+func (g *GeminiClient) enhancePhotoWithPrompt(ctx context.Context, step string, input EnhancementInput, promptBuilder func(context.Context, EnhancementInput) (string, string, error)) (*ImageGenerationResult, error) {
 	if err := g.Ensure(ctx); err != nil {
 		return nil, err
 	}
@@ -330,100 +482,142 @@ func (g *GeminiClient) enhancePhotoWithPrompt(ctx context.Context, input Enhance
 		return nil, fmt.Errorf("failed to fetch image: %w", err)
 	}
 
-	prompt := promptBuilder(input)
+	prompt, promptVersion, err := promptBuilder(ctx, input)
+	if err != nil {
+		return nil, err
+	}
 	config := &genai.GenerateContentConfig{ResponseModalities: []string{"IMAGE", "TEXT"}}
+	// Ask for the original's aspect ratio so the output needs as little fitting as possible.
+	if imageConfig, _, err := image.DecodeConfig(bytes.NewReader(imageData)); err == nil {
+		if ratio := NearestAspectRatio(imageConfig.Width, imageConfig.Height); ratio != "" {
+			config.ImageConfig = &genai.ImageConfig{AspectRatio: ratio}
+		}
+	}
 	contents := []*genai.Content{
 		genai.NewContentFromParts([]*genai.Part{
 			genai.NewPartFromText(prompt),
 			genai.NewPartFromBytes(imageData, mimeType),
 		}, genai.RoleUser),
 	}
-	response, err := g.client.Models.GenerateContent(ctx, "gemini-3-pro-image-preview", contents, config)
+
+	// The prompt carries the analysis and notes, so it is part of the key.
+	cacheKey := resultCacheKey(step, imageData, ModelChain(CapabilityEnhancement), promptVersion, prompt)
+	var cached ImageGenerationResult
+	if g.lookupCached(ctx, step, cacheKey, &cached) {
+		return &cached, nil
+	}
+	result, call, err := g.generateImageWithFallback(ctx, step, contents, config)
 	if err != nil {
 		return nil, err
 	}
+	g.storeCached(ctx, step, cacheKey, call, result)
+	return result, nil
+}
 
-	if len(response.Candidates) == 0 || response.Candidates[0].Content == nil {
-		return nil, errors.New("empty image generation response")
+// generateImageWithFallback runs an image generation request down the
+// enhancement model chain until a model returns an image.
+func (g *GeminiClient) generateImageWithFallback(ctx context.Context, step string, contents []*genai.Content, config *genai.GenerateContentConfig) (*ImageGenerationResult, modelCall, error) {
+	var result *ImageGenerationResult
+	call, err := g.generateWithFallback(ctx, step, ModelChain(CapabilityEnhancement), contents, config, func(response *genai.GenerateContentResponse) error {
+		if len(response.Candidates) == 0 || response.Candidates[0].Content == nil {
+			return errors.New("empty image generation response")
+		}
+		var err error
+		result, err = imageGenerationResult(response)
+		return err
+	})
+	if err != nil {
+		return nil, modelCall{}, err
 	}
+	return result, call, nil
+}
 
-	var imageBase64 string
+// imageGenerationResult extracts the generated image and accompanying text from a response.
+func imageGenerationResult(response *genai.GenerateContentResponse) (*ImageGenerationResult, error) {
+	var imageData []byte
 	var reasoning string
 	for _, part := range response.Candidates[0].Content.Parts {
 		if part.InlineData != nil {
-			imageBase64 = base64.StdEncoding.EncodeToString(part.InlineData.Data)
+			imageData = part.InlineData.Data
 		}
 		if part.Text != "" {
 			reasoning += part.Text
 		}
 	}
 
-	if imageBase64 == "" {
+	if len(imageData) == 0 {
 		return nil, errors.New("image data missing in response")
 	}
 
+	mimeType := DetectImageMIMEType(imageData)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, fmt.Errorf("generated data is not an image: %s", mimeType)
+	}
+
 	return &ImageGenerationResult{
-		ImageBase64: imageBase64,
+		ImageBase64: base64.StdEncoding.EncodeToString(imageData),
+		MIMEType:    mimeType,
 		Reasoning:   strings.TrimSpace(reasoning),
 	}, nil
 }
 
-func buildEnhancementPrompt(input EnhancementInput) string {
-	analysisDetails := formatEnhancementAnalysis(input.Analysis)
-	if analysisDetails == "" {
-		analysisDetails = "構図・露出・色彩・ライティングをより洗練されたコンテスト受賞レベルに高めてください。"
-	}
-
-	customNotes := strings.TrimSpace(input.CustomNotes)
-	if customNotes != "" {
-		customNotes = "\n追加の要望: " + customNotes
-	}
+func buildEnhancementPrompt(ctx context.Context, input EnhancementInput) (string, string, error) {
+	return prompts.Default().RenderContext(ctx, prompts.EnhancementAnnotated, enhancementData(ctx, input))
+}
 
-	return fmt.Sprintf("あなたはプロの写真レタッチャー兼講師です。元写真の内容は維持したまま、自然で高品質な改善を行い、コンテスト受賞レベルの仕上がりにしてください。\n\n採点結果と改善提案: %s%s\n\n重要: 生成される画像は「改善後の美しい写真」ですが、そこに「赤ペン先生」のように、改善ポイントや良くなった部分に赤丸や矢印をつけ、手書き風の文字で短いコメント（例:「ここを明るく」「構図を整理」など）を書き込んでください。改善された写真そのものに、直接赤ペンで書き込みが入っている状態の画像を出力してください。\n\n改善ルール:\n1. 被写道の基礎は維持しつつ、プロレベルに仕上げる\n2. 改善ポイントに赤ペンでマルや矢印を入れる\n3. 手書き風の文字でコメントを入れる\n4. 露出、色彩、ライティングを最適化\n\n変換後の写真（赤ペン添削付き）を生成し、変更点を簡潔に説明してください。", analysisDetails, customNotes)
+func buildCleanEnhancementPrompt(ctx context.Context, input EnhancementInput) (string, string, error) {
+	return prompts.Default().RenderContext(ctx, prompts.EnhancementClean, enhancementData(ctx, input))
 }
 
-func buildCleanEnhancementPrompt(input EnhancementInput) string {
-	analysisDetails := formatEnhancementAnalysis(input.Analysis)
+func enhancementData(ctx context.Context, input EnhancementInput) prompts.EnhancementData {
+	settings := locale.FromContext(ctx)
+	target := purpose.FromContext(ctx)
+	analysisDetails := formatEnhancementAnalysis(settings, input.Analysis)
 	if analysisDetails == "" {
-		analysisDetails = "構図・露出・色彩・ライティングをより洗練されたコンテスト受賞レベルに高めてください。"
+		analysisDetails = settings.T("enhance.default." + string(target))
 	}
-
-	customNotes := strings.TrimSpace(input.CustomNotes)
-	if customNotes != "" {
-		customNotes = "\n追加の要望: " + customNotes
+	return prompts.EnhancementData{
+		Analysis:    analysisDetails,
+		CustomNotes: strings.TrimSpace(input.CustomNotes),
+		Strict:      input.Strict,
+		Purpose:     string(target),
 	}
-
-	return fmt.Sprintf("あなたはプロの写真レタッチャー兼講師です。元写真の内容は維持したまま、自然で高品質な改善を行い、コンテスト受賞レベルの仕上がりにしてください。\n\n採点結果と改善提案: %s%s\n\n重要: 注釈・文字・矢印・赤ペンなどの書き込みは一切行わないでください。改善された美しい写真のみを出力してください。クリーンで美しい仕上がりの写真だけを生成してください。\n\n改善ルール:\n1. 被写体の基礎は維持しつつ、プロレベルに仕上げる\n2. 露出、色彩、ライティングを最適化\n3. 構図の改善を反映\n4. 文字や注釈は一切入れない\n\n変換後の写真（クリーンな改善版）を生成し、変更点を簡潔に説明してください。", analysisDetails, customNotes)
 }
 
-func formatEnhancementAnalysis(analysis *AnalysisResult) string {
+func formatEnhancementAnalysis(settings locale.Settings, analysis *AnalysisResult) string {
 	if analysis == nil {
 		return ""
 	}
 
 	parts := []string{}
 	if summary := strings.TrimSpace(analysis.Summary); summary != "" {
-		parts = append(parts, fmt.Sprintf("サマリー: %s", summary))
+		parts = append(parts, settings.T("enhance.summary", summary))
 	}
 	if overall := strings.TrimSpace(analysis.OverallComment); overall != "" {
-		parts = append(parts, fmt.Sprintf("総合コメント: %s", overall))
+		parts = append(parts, settings.T("enhance.overall", overall))
 	}
 	if analysis.OverallScore > 0 {
-		parts = append(parts, fmt.Sprintf("総合スコア: %d/10", analysis.OverallScore))
-	}
-
-	categoryLines := buildCategoryLines([]categorySummary{
-		{name: "構図", score: analysis.Composition.Score, comment: analysis.Composition.Comment, improvement: analysis.Composition.Improvement},
-		{name: "露出", score: analysis.Exposure.Score, comment: analysis.Exposure.Comment, improvement: analysis.Exposure.Improvement},
-		{name: "色彩", score: analysis.Color.Score, comment: analysis.Color.Comment, improvement: analysis.Color.Improvement},
-		{name: "ライティング", score: analysis.Lighting.Score, comment: analysis.Lighting.Comment, improvement: analysis.Lighting.Improvement},
-		{name: "ピント", score: analysis.Focus.Score, comment: analysis.Focus.Comment, improvement: analysis.Focus.Improvement},
-		{name: "現像", score: analysis.Development.Score, comment: analysis.Development.Comment, improvement: analysis.Development.Improvement},
-		{name: "距離感", score: analysis.Distance.Score, comment: analysis.Distance.Comment, improvement: analysis.Distance.Improvement},
-		{name: "意図の明確さ", score: analysis.IntentClarity.Score, comment: analysis.IntentClarity.Comment, improvement: analysis.IntentClarity.Improvement},
-	})
+		parts = append(parts, settings.T("enhance.score", analysis.OverallScore, analysis.MaxScore()))
+	}
+
+	categories := analysis.Categories()
+	analysisRubric := resultRubric(analysis)
+	summaries := make([]categorySummary, 0, len(analysisRubric.Categories))
+	for _, definition := range analysisRubric.Categories {
+		category, ok := categories[definition.Key]
+		if !ok {
+			continue
+		}
+		summaries = append(summaries, categorySummary{
+			name:        definition.Name.In(settings.Locale),
+			score:       category.Score,
+			comment:     category.Comment,
+			improvement: category.Improvement,
+		})
+	}
+	categoryLines := buildCategoryLines(settings, summaries, analysis.MaxScore())
 	if len(categoryLines) > 0 {
-		parts = append(parts, "項目別の改善提案:\n"+strings.Join(categoryLines, "\n"))
+		parts = append(parts, settings.T("enhance.categories")+"\n"+strings.Join(categoryLines, "\n"))
 	}
 
 	return strings.TrimSpace(strings.Join(parts, "\n"))
@@ -436,17 +630,17 @@ type categorySummary struct {
 	improvement string
 }
 
-func buildCategoryLines(categories []categorySummary) []string {
+func buildCategoryLines(settings locale.Settings, categories []categorySummary, maxScore int) []string {
 	lines := make([]string, 0, len(categories))
 	for _, category := range categories {
 		comment := strings.TrimSpace(category.comment)
 		improvement := strings.TrimSpace(category.improvement)
-		lineParts := []string{fmt.Sprintf("- %s: %d/10", category.name, category.score)}
+		lineParts := []string{fmt.Sprintf("- %s: %d/%d", category.name, category.score, maxScore)}
 		if comment != "" {
-			lineParts = append(lineParts, fmt.Sprintf("講評: %s", comment))
+			lineParts = append(lineParts, settings.T("enhance.comment", comment))
 		}
 		if improvement != "" {
-			lineParts = append(lineParts, fmt.Sprintf("改善提案: %s", improvement))
+			lineParts = append(lineParts, settings.T("enhance.improvement", improvement))
 		}
 		lines = append(lines, strings.Join(lineParts, " / "))
 	}
@@ -460,88 +654,167 @@ func modelName() string {
 	return "gemini-3-flash-preview"
 }
 
-func analysisResponseSchema() *genai.Schema {
-	minScore := float64(0)
-	maxScore := float64(10)
-	categorySchema := &genai.Schema{
+// analysisResponseSchema returns the analysis schema for a rubric with descriptions in l.
+func analysisResponseSchema(l locale.Locale, r rubric.Rubric) *genai.Schema {
+	describe := func(key string, args ...any) string { return locale.T(l, "schema."+key, args...) }
+	minScore := float64(r.Scale.Min)
+	maxScore := float64(r.Scale.Max)
+	keys := r.Keys()
+	scores := &genai.Schema{
+		Type:             genai.TypeObject,
+		Properties:       map[string]*genai.Schema{},
+		Required:         keys,
+		PropertyOrdering: keys,
+		Description:      describe("scores"),
+	}
+	for _, category := range r.Categories {
+		description := category.Name.In(l)
+		if detail := category.Description.In(l); detail != "" {
+			description += ": " + detail
+		}
+		scores.Properties[category.Key] = &genai.Schema{
+			Type:        genai.TypeObject,
+			Description: description,
+			Properties: map[string]*genai.Schema{
+				"score": {
+					Type:        genai.TypeInteger,
+					Minimum:     &minScore,
+					Maximum:     &maxScore,
+					Description: describe("score", r.Scale.Min, r.Scale.Max),
+				},
+				"comment": {
+					Type:        genai.TypeString,
+					Description: describe("comment"),
+				},
+				"improvement": {
+					Type:        genai.TypeString,
+					Description: describe("improvement"),
+				},
+			},
+			Required: []string{"score", "comment", "improvement"},
+		}
+	}
+
+	minCoord := float64(0)
+	maxCoord := float64(1)
+	coordinate := func(description string) *genai.Schema {
+		return &genai.Schema{Type: genai.TypeNumber, Minimum: &minCoord, Maximum: &maxCoord, Description: description}
+	}
+	pointSchema := &genai.Schema{
 		Type: genai.TypeObject,
 		Properties: map[string]*genai.Schema{
-			"score": {
-				Type:        genai.TypeInteger,
-				Minimum:     &minScore,
-				Maximum:     &maxScore,
-				Description: "0から10の整数で評価する",
+			"x": coordinate(describe("pointX")),
+			"y": coordinate(describe("pointY")),
+		},
+		Required: []string{"x", "y"},
+	}
+	boxSchema := func(description string) *genai.Schema {
+		return &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"x":      coordinate(describe("boxX")),
+				"y":      coordinate(describe("boxY")),
+				"width":  coordinate(describe("boxWidth")),
+				"height": coordinate(describe("boxHeight")),
 			},
-			"comment": {
+			Required:    []string{"x", "y", "width", "height"},
+			Description: description,
+		}
+	}
+	annotationSchema := &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"type": {
+				Type:        genai.TypeString,
+				Enum:        []string{AnnotationBox, AnnotationPoint, AnnotationArrow},
+				Description: describe("annotationType"),
+			},
+			"category": {
+				Type:        genai.TypeString,
+				Enum:        keys,
+				Description: describe("annotationCategory"),
+			},
+			"label": {
+				Type:        genai.TypeString,
+				Description: describe("annotationLabel"),
+			},
+			"box":   boxSchema(describe("annotationBox")),
+			"point": pointSchema,
+			"from":  pointSchema,
+			"to":    pointSchema,
+		},
+		Required: []string{"type", "category", "label"},
+	}
+	regionSchema := &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"box": boxSchema(describe("regionBox")),
+			"category": {
+				Type:        genai.TypeString,
+				Enum:        keys,
+				Description: describe("regionCategory"),
+			},
+			"severity": {
 				Type:        genai.TypeString,
-				Description: "現状に対する講評コメント",
+				Enum:        []string{SeverityLow, SeverityMedium, SeverityHigh},
+				Description: describe("regionSeverity"),
 			},
-			"improvement": {
+			"comment": {
 				Type:        genai.TypeString,
-				Description: "具体的な改善提案",
+				Description: describe("regionComment"),
 			},
 		},
-		Required: []string{"score", "comment", "improvement"},
+		Required: []string{"box", "category", "severity", "comment"},
 	}
 
+	overallDescription := describe("overallScore")
+	if r.Weighted() {
+		overallDescription = describe("overallWeighted")
+	}
+	properties := []string{
+		"photoSummary",
+		"summary",
+		"overallComment",
+		"overallScore",
+		"scores",
+		"annotations",
+		"regions",
+	}
 	return &genai.Schema{
 		Type: genai.TypeObject,
 		Properties: map[string]*genai.Schema{
 			"photoSummary": {
 				Type:        genai.TypeString,
-				Description: "写真の内容を一言でまとめたタイトル",
+				Description: describe("photoSummary"),
 			},
 			"summary": {
 				Type:        genai.TypeString,
-				Description: "全体サマリー",
+				Description: describe("summary"),
 			},
 			"overallComment": {
 				Type:        genai.TypeString,
-				Description: "総合的なコメント",
+				Description: describe("overallComment"),
 			},
 			"overallScore": {
 				Type:        genai.TypeInteger,
 				Minimum:     &minScore,
 				Maximum:     &maxScore,
-				Description: "8項目の平均点(0-10の整数)",
+				Description: overallDescription,
+			},
+			"scores": scores,
+			"annotations": {
+				Type:        genai.TypeArray,
+				Items:       annotationSchema,
+				Description: describe("annotations"),
+			},
+			"regions": {
+				Type:        genai.TypeArray,
+				Items:       regionSchema,
+				Description: describe("regions"),
 			},
-			"composition":   categorySchema,
-			"exposure":      categorySchema,
-			"color":         categorySchema,
-			"lighting":      categorySchema,
-			"focus":         categorySchema,
-			"development":   categorySchema,
-			"distance":      categorySchema,
-			"intentClarity": categorySchema,
-		},
-		Required: []string{
-			"photoSummary",
-			"summary",
-			"overallComment",
-			"overallScore",
-			"composition",
-			"exposure",
-			"color",
-			"lighting",
-			"focus",
-			"development",
-			"distance",
-			"intentClarity",
-		},
-		PropertyOrdering: []string{
-			"photoSummary",
-			"summary",
-			"overallComment",
-			"overallScore",
-			"composition",
-			"exposure",
-			"color",
-			"lighting",
-			"focus",
-			"development",
-			"distance",
-			"intentClarity",
 		},
+		Required:         properties,
+		PropertyOrdering: properties,
 	}
 }
 
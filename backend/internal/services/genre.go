@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"google.golang.org/genai"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/prompts"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+)
+
+// GenreResult is the answer of the genre classification pass.
+type GenreResult struct {
+	Genre      string  `json:"genre"`
+	Confidence float64 `json:"confidence"`
+}
+
+// genreNames returns the genre names offered to the classifier.
+func genreNames() []string {
+	names := make([]string, 0, len(rubric.Genres))
+	for _, genre := range rubric.Genres {
+		names = append(names, string(genre))
+	}
+	return names
+}
+
+// analysisRubric returns the rubric for a photo and the genre it is judged
+// as: the user's choice if any, otherwise the detected genre. Classification
+// failures fall back to the general rubric rather than failing the analysis.
+func (g *GeminiClient) analysisRubric(ctx context.Context, imageData []byte, mimeType string) (rubric.Rubric, rubric.Genre, bool) {
+	if chosen, genre, ok := chosenRubric(ctx); ok {
+		return chosen, genre, false
+	}
+	result, err := g.classifyGenre(ctx, imageData, mimeType)
+	if err != nil {
+		log.Printf("WARN: Genre classification failed, using the general rubric: %v", err)
+		return rubric.Default().ForGenre(rubric.General), rubric.General, true
+	}
+	genre, _ := rubric.ParseGenre(result.Genre)
+	log.Printf("INFO: Detected genre %s (confidence %.2f)", genre, result.Confidence)
+	return rubric.Default().ForGenre(genre), genre, true
+}
+
+func (g *GeminiClient) classifyGenre(ctx context.Context, imageData []byte, mimeType string) (*GenreResult, error) {
+	genrePrompt, promptVersion, err := prompts.Default().RenderContext(ctx, prompts.Genre, prompts.GenreData{Genres: genreNames()})
+	if err != nil {
+		return nil, err
+	}
+	contents := []*genai.Content{
+		genai.NewContentFromParts([]*genai.Part{
+			genai.NewPartFromText(genrePrompt),
+			genai.NewPartFromBytes(imageData, mimeType),
+		}, genai.RoleUser),
+	}
+
+	chain := ModelChain(CapabilityAnalysis)
+	cacheKey := resultCacheKey(StepGenre, imageData, chain, promptVersion)
+	var result GenreResult
+	if g.lookupCached(ctx, StepGenre, cacheKey, &result) {
+		return &result, nil
+	}
+
+	call, err := g.generateWithFallback(ctx, StepGenre, chain, contents, &genai.GenerateContentConfig{
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   genreResponseSchema(locale.FromContext(ctx).Locale),
+	}, func(response *genai.GenerateContentResponse) error {
+		text := strings.TrimSpace(response.Text())
+		if text == "" {
+			return errors.New("empty genre response")
+		}
+		result = GenreResult{}
+		if err := json.Unmarshal([]byte(text), &result); err != nil {
+			return fmt.Errorf("genre response parse failed: %w", err)
+		}
+		if _, ok := rubric.ParseGenre(result.Genre); !ok {
+			return fmt.Errorf("unknown genre %q", result.Genre)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	g.storeCached(ctx, StepGenre, cacheKey, call, &result)
+	return &result, nil
+}
+
+func genreResponseSchema(l locale.Locale) *genai.Schema {
+	minConfidence := float64(0)
+	maxConfidence := float64(1)
+	return &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"genre": {
+				Type:        genai.TypeString,
+				Enum:        genreNames(),
+				Description: locale.T(l, "schema.genre"),
+			},
+			"confidence": {
+				Type:        genai.TypeNumber,
+				Minimum:     &minConfidence,
+				Maximum:     &maxConfidence,
+				Description: locale.T(l, "schema.genreConfidence"),
+			},
+		},
+		Required:         []string{"genre", "confidence"},
+		PropertyOrdering: []string{"genre", "confidence"},
+	}
+}
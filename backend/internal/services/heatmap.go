@@ -0,0 +1,118 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	// heatmapAlignEdge is the long edge used when searching for the best offset.
+	heatmapAlignEdge = 128
+	// heatmapMaxShift is the largest offset tried, in alignment-sample pixels.
+	heatmapMaxShift = 4
+	// heatmapFullScale is the color difference mapped to the hottest color.
+	heatmapFullScale = 0.35
+	// heatmapBaseWeight is how much of the grayscale original shows through.
+	heatmapBaseWeight = 0.3
+)
+
+// RenderDifferenceHeatmap aligns enhanced onto original and renders the
+// per-pixel color difference as a false-color map over a dimmed grayscale
+// copy of the original. Blue means unchanged, red means heavily retouched.
+func RenderDifferenceHeatmap(original, enhanced image.Image) *image.RGBA {
+	bounds := original.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	base := resampleRGBA(original, width, height)
+	aligned := resampleRGBA(enhanced, width, height)
+	dx, dy := estimateOffset(base, aligned)
+
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := base.PixOffset(x, y)
+			ex := min(max(x+dx, 0), width-1)
+			ey := min(max(y+dy, 0), height-1)
+			j := aligned.PixOffset(ex, ey)
+
+			y1, cb1, cr1 := color.RGBToYCbCr(base.Pix[i], base.Pix[i+1], base.Pix[i+2])
+			y2, cb2, cr2 := color.RGBToYCbCr(aligned.Pix[j], aligned.Pix[j+1], aligned.Pix[j+2])
+			dLuma := (float64(y2) - float64(y1)) / 255
+			dCb := (float64(cb2) - float64(cb1)) / 255
+			dCr := (float64(cr2) - float64(cr1)) / 255
+			diff := math.Sqrt(dLuma*dLuma + dCb*dCb + dCr*dCr)
+
+			heat := heatColor(diff / heatmapFullScale)
+			gray := float64(y1) * heatmapBaseWeight
+			o := out.PixOffset(x, y)
+			out.Pix[o] = uint8(gray + float64(heat.R)*(1-heatmapBaseWeight))
+			out.Pix[o+1] = uint8(gray + float64(heat.G)*(1-heatmapBaseWeight))
+			out.Pix[o+2] = uint8(gray + float64(heat.B)*(1-heatmapBaseWeight))
+			out.Pix[o+3] = 255
+		}
+	}
+	return out
+}
+
+// estimateOffset finds the integer translation of enhanced (in full-resolution
+// pixels) that best matches original, compensating for small shifts the image
+// model introduces when it re-renders the frame.
+func estimateOffset(original, enhanced *image.RGBA) (int, int) {
+	width, height := sampleDimensions(original.Bounds(), heatmapAlignEdge)
+	if width <= 2*heatmapMaxShift || height <= 2*heatmapMaxShift {
+		return 0, 0
+	}
+	a := normalizedLuminance(resampleRGBA(original, width, height))
+	b := normalizedLuminance(resampleRGBA(enhanced, width, height))
+
+	bestX, bestY := 0, 0
+	bestCost := math.Inf(1)
+	for sy := -heatmapMaxShift; sy <= heatmapMaxShift; sy++ {
+		for sx := -heatmapMaxShift; sx <= heatmapMaxShift; sx++ {
+			cost := 0.0
+			for y := heatmapMaxShift; y < height-heatmapMaxShift; y++ {
+				for x := heatmapMaxShift; x < width-heatmapMaxShift; x++ {
+					cost += math.Abs(a[y*width+x] - b[(y+sy)*width+x+sx])
+				}
+			}
+			// Prefer the zero offset on ties so clean outputs stay untouched.
+			if cost < bestCost-1e-9 || (cost <= bestCost+1e-9 && sx == 0 && sy == 0) {
+				bestCost = cost
+				bestX, bestY = sx, sy
+			}
+		}
+	}
+
+	scaleX := float64(original.Bounds().Dx()) / float64(width)
+	scaleY := float64(original.Bounds().Dy()) / float64(height)
+	return int(math.Round(float64(bestX) * scaleX)), int(math.Round(float64(bestY) * scaleY))
+}
+
+// heatColor maps t in [0,1] onto a blue-cyan-green-yellow-red ramp.
+func heatColor(t float64) color.RGBA {
+	t = clampUnit(t)
+	stops := []struct {
+		at      float64
+		r, g, b float64
+	}{
+		{0.00, 20, 30, 140},
+		{0.25, 0, 170, 230},
+		{0.50, 40, 200, 80},
+		{0.75, 250, 220, 0},
+		{1.00, 230, 20, 20},
+	}
+	for i := 1; i < len(stops); i++ {
+		if t <= stops[i].at {
+			lo, hi := stops[i-1], stops[i]
+			f := (t - lo.at) / (hi.at - lo.at)
+			return color.RGBA{
+				R: uint8(lo.r + (hi.r-lo.r)*f),
+				G: uint8(lo.g + (hi.g-lo.g)*f),
+				B: uint8(lo.b + (hi.b-lo.b)*f),
+				A: 255,
+			}
+		}
+	}
+	last := stops[len(stops)-1]
+	return color.RGBA{uint8(last.r), uint8(last.g), uint8(last.b), 255}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// hotShare is the share of pixels inside rect that the heatmap paints
+// warmer than cyan, i.e. more red than blue.
+func hotShare(heatmap *image.RGBA, rect image.Rectangle) float64 {
+	hot, total := 0, 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := heatmap.RGBAAt(x, y)
+			if c.R > c.B {
+				hot++
+			}
+			total++
+		}
+	}
+	return float64(hot) / float64(total)
+}
+
+// shiftedScene copies img moved right by dx and down by dy, repeating the edges.
+func shiftedScene(img *image.RGBA, dx, dy int) *image.RGBA {
+	bounds := img.Bounds()
+	out := image.NewRGBA(bounds)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			out.SetRGBA(x, y, img.RGBAAt(min(max(x-dx, 0), bounds.Dx()-1), min(max(y-dy, 0), bounds.Dy()-1)))
+		}
+	}
+	return out
+}
+
+func TestRenderDifferenceHeatmap(t *testing.T) {
+	original := syntheticScene(256, 192)
+	edited := image.NewRGBA(original.Bounds())
+	copy(edited.Pix, original.Pix)
+	edit := image.Rect(20, 20, 80, 60)
+	for y := edit.Min.Y; y < edit.Max.Y; y++ {
+		for x := edit.Min.X; x < edit.Max.X; x++ {
+			edited.SetRGBA(x, y, color.RGBA{250, 250, 20, 255})
+		}
+	}
+	// The difference heat is only meaningful away from the edit's blurred border.
+	inside := edit.Inset(4)
+	outside := image.Rect(120, 80, 256, 192)
+
+	tests := []struct {
+		name        string
+		enhanced    *image.RGBA
+		wantInside  float64
+		wantOutside float64
+	}{
+		{name: "identical", enhanced: original},
+		{name: "localized edit", enhanced: edited, wantInside: 1},
+		// A re-rendered frame that moved a few pixels is aligned, not heat.
+		{name: "shifted", enhanced: shiftedScene(original, 4, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			heatmap := RenderDifferenceHeatmap(original, tt.enhanced)
+			if heatmap.Bounds() != original.Bounds() {
+				t.Fatalf("bounds = %v, want %v", heatmap.Bounds(), original.Bounds())
+			}
+			if got := hotShare(heatmap, inside); got != tt.wantInside {
+				t.Errorf("hot share inside the edit = %.2f, want %.2f", got, tt.wantInside)
+			}
+			if got := hotShare(heatmap, outside); got != tt.wantOutside {
+				t.Errorf("hot share outside the edit = %.2f, want %.2f", got, tt.wantOutside)
+			}
+		})
+	}
+}
+
+func TestEstimateOffset(t *testing.T) {
+	original := syntheticScene(256, 192)
+	tests := []struct {
+		name           string
+		dx, dy         int
+		wantDX, wantDY int
+	}{
+		{name: "none", dx: 0, dy: 0, wantDX: 0, wantDY: 0},
+		{name: "right and down", dx: 4, dy: 2, wantDX: 4, wantDY: 2},
+		{name: "left and up", dx: -6, dy: -4, wantDX: -6, wantDY: -4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dx, dy := estimateOffset(original, shiftedScene(original, tt.dx, tt.dy))
+			if dx != tt.wantDX || dy != tt.wantDY {
+				t.Errorf("offset = (%d, %d), want (%d, %d)", dx, dy, tt.wantDX, tt.wantDY)
+			}
+		})
+	}
+}
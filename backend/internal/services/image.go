@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"fmt"
 	"image"
+	"image/color"
 	"image/jpeg"
 	"image/png"
 	"io"
+	"math"
+	"net/http"
 	"strings"
 
 	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
 )
 
 const maxImageEdge = 1024
@@ -26,8 +30,16 @@ func (p *ImageProcessor) ResizeToMaxEdge(reader io.Reader, contentType string) (
 		return nil, "", err
 	}
 
-	width := decoded.Bounds().Dx()
-	height := decoded.Bounds().Dy()
+	if strings.ToLower(format) == "png" {
+		contentType = "image/png"
+	}
+	return p.EncodeWithMaxEdge(decoded, contentType)
+}
+
+// EncodeWithMaxEdge scales img down so its long edge is at most maxImageEdge and encodes it.
+func (p *ImageProcessor) EncodeWithMaxEdge(img image.Image, contentType string) ([]byte, string, error) {
+	width := img.Bounds().Dx()
+	height := img.Bounds().Dy()
 	if width == 0 || height == 0 {
 		return nil, "", fmt.Errorf("invalid image dimensions")
 	}
@@ -50,29 +62,166 @@ func (p *ImageProcessor) ResizeToMaxEdge(reader io.Reader, contentType string) (
 		}
 	}
 
-	resized := decoded
+	resized := img
 	if newWidth != width || newHeight != height {
 		canvas := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
-		draw.CatmullRom.Scale(canvas, canvas.Bounds(), decoded, decoded.Bounds(), draw.Over, nil)
+		draw.CatmullRom.Scale(canvas, canvas.Bounds(), img, img.Bounds(), draw.Over, nil)
 		resized = canvas
 	}
 
+	return encodeImage(resized, contentType)
+}
+
+// ResizeToMaxEdgeFromBytes is like ResizeToMaxEdge but accepts a byte slice
+func (p *ImageProcessor) ResizeToMaxEdgeFromBytes(data []byte, contentType string) ([]byte, string, error) {
+	return p.ResizeToMaxEdge(bytes.NewReader(data), contentType)
+}
+
+// aspectRatios are the output ratios supported by the image generation config.
+var aspectRatios = []string{"1:1", "2:3", "3:2", "3:4", "4:3", "9:16", "16:9", "21:9"}
+
+// aspectMismatchTolerance is the relative ratio difference still fixed by cropping;
+// larger mismatches are letterboxed so no content is cut away.
+const aspectMismatchTolerance = 0.03
+
+// NearestAspectRatio returns the supported generation aspect ratio closest to width:height.
+func NearestAspectRatio(width, height int) string {
+	if width <= 0 || height <= 0 {
+		return ""
+	}
+	target := math.Log(float64(width) / float64(height))
+	best := ""
+	bestDistance := math.Inf(1)
+	for _, ratio := range aspectRatios {
+		var w, h float64
+		if _, err := fmt.Sscanf(ratio, "%g:%g", &w, &h); err != nil {
+			continue
+		}
+		if distance := math.Abs(math.Log(w/h) - target); distance < bestDistance {
+			best = ratio
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+// DetectImageMIMEType sniffs the real image format from the data itself.
+func DetectImageMIMEType(data []byte) string {
+	return http.DetectContentType(data)
+}
+
+// NormalizeGenerated decodes a generated image whatever format it came back in,
+// fits it to exactly width x height and re-encodes it as contentType, so the
+// result lines up pixel for pixel with the original upload.
+func (p *ImageProcessor) NormalizeGenerated(data []byte, width, height int, contentType string) ([]byte, string, error) {
+	if width <= 0 || height <= 0 {
+		return nil, "", fmt.Errorf("invalid target dimensions %dx%d", width, height)
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode generated image (%s): %w", DetectImageMIMEType(data), err)
+	}
+	return encodeImage(FitToDimensions(decoded, width, height), contentType)
+}
+
+// FitToDimensions scales src to width x height. Small aspect differences are
+// removed by a centered crop; larger ones are letterboxed.
+func FitToDimensions(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	if bounds.Dx() == width && bounds.Dy() == height {
+		return src
+	}
+
+	srcRatio := float64(bounds.Dx()) / float64(bounds.Dy())
+	dstRatio := float64(width) / float64(height)
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	if math.Abs(srcRatio/dstRatio-1) <= aspectMismatchTolerance {
+		// Cover: crop the source to the target ratio around its center.
+		crop := bounds
+		if srcRatio > dstRatio {
+			cropWidth := int(math.Round(float64(bounds.Dy()) * dstRatio))
+			offset := (bounds.Dx() - cropWidth) / 2
+			crop = image.Rect(bounds.Min.X+offset, bounds.Min.Y, bounds.Min.X+offset+cropWidth, bounds.Max.Y)
+		} else if srcRatio < dstRatio {
+			cropHeight := int(math.Round(float64(bounds.Dx()) / dstRatio))
+			offset := (bounds.Dy() - cropHeight) / 2
+			crop = image.Rect(bounds.Min.X, bounds.Min.Y+offset, bounds.Max.X, bounds.Min.Y+offset+cropHeight)
+		}
+		draw.CatmullRom.Scale(canvas, canvas.Bounds(), src, crop, draw.Src, nil)
+		return canvas
+	}
+
+	// Contain: fit the whole source inside the target on a black background.
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	target := canvas.Bounds()
+	if srcRatio > dstRatio {
+		fitHeight := int(math.Round(float64(width) / srcRatio))
+		offset := (height - fitHeight) / 2
+		target = image.Rect(0, offset, width, offset+fitHeight)
+	} else {
+		fitWidth := int(math.Round(float64(height) * srcRatio))
+		offset := (width - fitWidth) / 2
+		target = image.Rect(offset, 0, offset+fitWidth, height)
+	}
+	draw.CatmullRom.Scale(canvas, target, src, bounds, draw.Src, nil)
+	return canvas
+}
+
+// Encode encodes img as PNG when contentType asks for it and as JPEG otherwise.
+func (p *ImageProcessor) Encode(img image.Image, contentType string) ([]byte, string, error) {
+	return encodeImage(img, contentType)
+}
+
+// encodeImage encodes img as PNG when contentType asks for it and as JPEG otherwise.
+func encodeImage(img image.Image, contentType string) ([]byte, string, error) {
 	buffer := &bytes.Buffer{}
-	encodeFormat := strings.ToLower(format)
-	if encodeFormat == "png" || strings.Contains(contentType, "png") {
-		if err := png.Encode(buffer, resized); err != nil {
+	if strings.Contains(contentType, "png") {
+		if err := png.Encode(buffer, img); err != nil {
 			return nil, "", err
 		}
 		return buffer.Bytes(), "image/png", nil
 	}
 
-	if err := jpeg.Encode(buffer, resized, &jpeg.Options{Quality: 90}); err != nil {
+	if err := jpeg.Encode(buffer, img, &jpeg.Options{Quality: 90}); err != nil {
 		return nil, "", err
 	}
 	return buffer.Bytes(), "image/jpeg", nil
 }
 
-// ResizeToMaxEdgeFromBytes is like ResizeToMaxEdge but accepts a byte slice
-func (p *ImageProcessor) ResizeToMaxEdgeFromBytes(data []byte, contentType string) ([]byte, string, error) {
-	return p.ResizeToMaxEdge(bytes.NewReader(data), contentType)
+// Validate reports whether the box is non-empty and lies inside the unit square.
+func (b NormalizedBox) Validate() error {
+	if b.X < 0 || b.Y < 0 || b.Width <= 0 || b.Height <= 0 || b.X+b.Width > 1.0001 || b.Y+b.Height > 1.0001 {
+		return fmt.Errorf("invalid normalized box: %+v", b)
+	}
+	return nil
+}
+
+// Clamp trims the box to the unit square; a box entirely outside it
+// becomes empty and fails Validate.
+func (b NormalizedBox) Clamp() NormalizedBox {
+	x0, y0 := clampUnit(b.X), clampUnit(b.Y)
+	x1, y1 := clampUnit(b.X+b.Width), clampUnit(b.Y+b.Height)
+	return NormalizedBox{X: x0, Y: y0, Width: max(x1-x0, 0), Height: max(y1-y0, 0)}
+}
+
+// CropNormalized copies the area of img described by box.
+func CropNormalized(img image.Image, box NormalizedBox) (image.Image, error) {
+	if err := box.Validate(); err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	rect := image.Rect(
+		bounds.Min.X+int(math.Floor(box.X*float64(bounds.Dx()))),
+		bounds.Min.Y+int(math.Floor(box.Y*float64(bounds.Dy()))),
+		bounds.Min.X+int(math.Ceil((box.X+box.Width)*float64(bounds.Dx()))),
+		bounds.Min.Y+int(math.Ceil((box.Y+box.Height)*float64(bounds.Dy()))),
+	).Intersect(bounds)
+	if rect.Empty() {
+		return nil, fmt.Errorf("crop area is empty: %+v", box)
+	}
+
+	crop := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(crop, crop.Bounds(), img, rect.Min, draw.Src)
+	return crop, nil
 }
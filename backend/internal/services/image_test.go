@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestNearestAspectRatio(t *testing.T) {
+	tests := []struct {
+		width, height int
+		expected      string
+	}{
+		{1024, 1024, "1:1"},
+		{1024, 683, "3:2"},
+		{768, 1024, "3:4"},
+		{1024, 576, "16:9"},
+		{1024, 820, "4:3"},
+		{0, 100, ""},
+	}
+
+	for _, tt := range tests {
+		if got := NearestAspectRatio(tt.width, tt.height); got != tt.expected {
+			t.Errorf("NearestAspectRatio(%d, %d) = %q, want %q", tt.width, tt.height, got, tt.expected)
+		}
+	}
+}
+
+func TestNormalizeGenerated(t *testing.T) {
+	tests := []struct {
+		name                string
+		srcWidth, srcHeight int
+		dstWidth, dstHeight int
+		expectLetterbox     bool
+	}{
+		{name: "same size", srcWidth: 300, srcHeight: 200, dstWidth: 300, dstHeight: 200},
+		{name: "upscale same ratio", srcWidth: 150, srcHeight: 100, dstWidth: 300, dstHeight: 200},
+		{name: "slight mismatch is cropped", srcWidth: 1024, srcHeight: 680, dstWidth: 1024, dstHeight: 694},
+		{name: "large mismatch is letterboxed", srcWidth: 512, srcHeight: 512, dstWidth: 1024, dstHeight: 576, expectLetterbox: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := image.NewRGBA(image.Rect(0, 0, tt.srcWidth, tt.srcHeight))
+			for i := range src.Pix {
+				src.Pix[i] = 200
+			}
+			var encoded bytes.Buffer
+			if err := png.Encode(&encoded, src); err != nil {
+				t.Fatal(err)
+			}
+
+			data, contentType, err := NewImageProcessor().NormalizeGenerated(encoded.Bytes(), tt.dstWidth, tt.dstHeight, "image/jpeg")
+			if err != nil {
+				t.Fatalf("NormalizeGenerated() error = %v", err)
+			}
+			if contentType != "image/jpeg" || DetectImageMIMEType(data) != "image/jpeg" {
+				t.Errorf("content type = %q (sniffed %q), want image/jpeg", contentType, DetectImageMIMEType(data))
+			}
+
+			decoded, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Bounds().Dx() != tt.dstWidth || decoded.Bounds().Dy() != tt.dstHeight {
+				t.Errorf("dimensions = %v, want %dx%d", decoded.Bounds().Size(), tt.dstWidth, tt.dstHeight)
+			}
+			r, _, _, _ := decoded.At(0, tt.dstHeight/2).RGBA()
+			if letterboxed := r>>8 < 40; letterboxed != tt.expectLetterbox {
+				t.Errorf("left edge letterboxed = %v, want %v", letterboxed, tt.expectLetterbox)
+			}
+		})
+	}
+}
+
+func TestNormalizedBoxValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		box     NormalizedBox
+		wantErr bool
+	}{
+		{name: "whole frame", box: NormalizedBox{Width: 1, Height: 1}},
+		{name: "inner area", box: NormalizedBox{X: 0.25, Y: 0.5, Width: 0.5, Height: 0.25}},
+		{name: "rounding at the edge", box: NormalizedBox{X: 0.7, Y: 0.7, Width: 0.30005, Height: 0.3}},
+		{name: "negative origin", box: NormalizedBox{X: -0.1, Width: 0.5, Height: 0.5}, wantErr: true},
+		{name: "empty", box: NormalizedBox{X: 0.2, Y: 0.2, Height: 0.5}, wantErr: true},
+		{name: "past the right edge", box: NormalizedBox{X: 0.8, Width: 0.3, Height: 0.5}, wantErr: true},
+		{name: "past the bottom edge", box: NormalizedBox{Y: 0.9, Width: 0.5, Height: 0.2}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.box.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCropNormalized(t *testing.T) {
+	// The source is offset so crops must honor a non-zero bounds origin.
+	src := image.NewRGBA(image.Rect(10, 20, 210, 120))
+	tests := []struct {
+		name    string
+		box     NormalizedBox
+		want    image.Rectangle
+		wantErr bool
+	}{
+		{name: "whole frame", box: NormalizedBox{Width: 1, Height: 1}, want: image.Rect(0, 0, 200, 100)},
+		{name: "quarter", box: NormalizedBox{X: 0.5, Y: 0.5, Width: 0.5, Height: 0.5}, want: image.Rect(0, 0, 100, 50)},
+		{name: "fractional edges round outward", box: NormalizedBox{X: 0.101, Y: 0.101, Width: 0.2, Height: 0.2}, want: image.Rect(0, 0, 41, 21)},
+		{name: "invalid box", box: NormalizedBox{X: 0.9, Width: 0.5, Height: 0.5}, wantErr: true},
+	}
+	for _, tt := range tests {
+		crop, err := CropNormalized(src, tt.box)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && crop.Bounds() != tt.want {
+			t.Errorf("%s: bounds = %v, want %v", tt.name, crop.Bounds(), tt.want)
+		}
+	}
+
+	// The crop carries the pixels of the selected area.
+	marked := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	marked.Pix[marked.PixOffset(60, 70)] = 255
+	crop, err := CropNormalized(marked, NormalizedBox{X: 0.5, Y: 0.5, Width: 0.5, Height: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := crop.At(10, 20).RGBA(); r == 0 {
+		t.Error("crop lost the marked pixel at (60,70)")
+	}
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// DefaultLUTSize is the lattice size used for exported .cube files.
+	DefaultLUTSize = 33
+	// lutSampleEdge caps the long edge of the images used for fitting.
+	lutSampleEdge = 512
+	// lutSmoothness controls how strongly sparsely populated lattice cells
+	// are pulled towards their neighbours (and ultimately the identity).
+	lutSmoothness = 0.5
+	lutIterations = 60
+)
+
+// ColorLUT is a 3D color lookup table on a uniform RGB lattice.
+// Values are stored in .cube order: red changes fastest, then green, then blue.
+type ColorLUT struct {
+	Size int
+	Data [][3]float64
+}
+
+// FitColorLUT fits a size³ lookup table that maps the colors of original
+// onto the colors of enhanced. Both images are resampled to a common grid, so
+// they may differ in resolution but should share the same framing.
+func FitColorLUT(original, enhanced image.Image, size int) (*ColorLUT, error) {
+	if size < 2 {
+		return nil, fmt.Errorf("invalid lut size: %d", size)
+	}
+	width, height := sampleDimensions(original.Bounds(), lutSampleEdge)
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("invalid image dimensions")
+	}
+	src := resampleRGBA(original, width, height)
+	dst := resampleRGBA(enhanced, width, height)
+
+	cells := size * size * size
+	numer := make([][3]float64, cells)
+	denom := make([]float64, cells)
+	scale := float64(size - 1)
+
+	// Splat the per-pixel color offsets onto the 8 surrounding lattice cells.
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := src.PixOffset(x, y)
+			in := [3]float64{
+				float64(src.Pix[i]) / 255,
+				float64(src.Pix[i+1]) / 255,
+				float64(src.Pix[i+2]) / 255,
+			}
+			delta := [3]float64{
+				float64(dst.Pix[i])/255 - in[0],
+				float64(dst.Pix[i+1])/255 - in[1],
+				float64(dst.Pix[i+2])/255 - in[2],
+			}
+			forEachCorner(in, scale, size, func(idx int, weight float64) {
+				denom[idx] += weight
+				for c := 0; c < 3; c++ {
+					numer[idx][c] += weight * delta[c]
+				}
+			})
+		}
+	}
+
+	// Solve for smooth offsets with Gauss-Seidel iterations: observed cells
+	// follow their data, empty cells diffuse from neighbours towards identity.
+	offsets := make([][3]float64, cells)
+	for iter := 0; iter < lutIterations; iter++ {
+		for b := 0; b < size; b++ {
+			for g := 0; g < size; g++ {
+				for r := 0; r < size; r++ {
+					idx := lutIndex(r, g, b, size)
+					var sum [3]float64
+					count := 0
+					for _, n := range [][3]int{{r - 1, g, b}, {r + 1, g, b}, {r, g - 1, b}, {r, g + 1, b}, {r, g, b - 1}, {r, g, b + 1}} {
+						if n[0] < 0 || n[1] < 0 || n[2] < 0 || n[0] >= size || n[1] >= size || n[2] >= size {
+							continue
+						}
+						neighbour := offsets[lutIndex(n[0], n[1], n[2], size)]
+						for c := 0; c < 3; c++ {
+							sum[c] += neighbour[c]
+						}
+						count++
+					}
+					for c := 0; c < 3; c++ {
+						offsets[idx][c] = (numer[idx][c] + lutSmoothness*sum[c]/float64(count)) / (denom[idx] + lutSmoothness)
+					}
+				}
+			}
+		}
+	}
+
+	lut := &ColorLUT{Size: size, Data: make([][3]float64, cells)}
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				idx := lutIndex(r, g, b, size)
+				base := [3]float64{float64(r) / scale, float64(g) / scale, float64(b) / scale}
+				for c := 0; c < 3; c++ {
+					lut.Data[idx][c] = clampUnit(base[c] + offsets[idx][c])
+				}
+			}
+		}
+	}
+	return lut, nil
+}
+
+// Apply maps an RGB triple in [0,1] through the table with trilinear interpolation.
+func (l *ColorLUT) Apply(rgb [3]float64) [3]float64 {
+	var out [3]float64
+	forEachCorner(rgb, float64(l.Size-1), l.Size, func(idx int, weight float64) {
+		for c := 0; c < 3; c++ {
+			out[c] += weight * l.Data[idx][c]
+		}
+	})
+	return out
+}
+
+// WriteCube writes the table in the Adobe/Resolve .cube text format.
+func (l *ColorLUT) WriteCube(w io.Writer, title string) error {
+	bw := bufio.NewWriter(w)
+	title = strings.NewReplacer(`"`, "'", "\n", " ", "\r", " ").Replace(strings.TrimSpace(title))
+	if title != "" {
+		fmt.Fprintf(bw, "TITLE \"%s\"\n", title)
+	}
+	fmt.Fprintf(bw, "LUT_3D_SIZE %d\n", l.Size)
+	fmt.Fprintln(bw, "DOMAIN_MIN 0.0 0.0 0.0")
+	fmt.Fprintln(bw, "DOMAIN_MAX 1.0 1.0 1.0")
+	for _, entry := range l.Data {
+		fmt.Fprintf(bw, "%.6f %.6f %.6f\n", entry[0], entry[1], entry[2])
+	}
+	return bw.Flush()
+}
+
+// forEachCorner visits the 8 lattice cells surrounding rgb with their trilinear weights.
+func forEachCorner(rgb [3]float64, scale float64, size int, visit func(idx int, weight float64)) {
+	var lo [3]int
+	var frac [3]float64
+	for c := 0; c < 3; c++ {
+		p := clampUnit(rgb[c]) * scale
+		lo[c] = int(math.Floor(p))
+		if lo[c] >= size-1 {
+			lo[c] = size - 2
+		}
+		frac[c] = p - float64(lo[c])
+	}
+	for corner := 0; corner < 8; corner++ {
+		weight := 1.0
+		var pos [3]int
+		for c := 0; c < 3; c++ {
+			if corner&(1<<c) != 0 {
+				pos[c] = lo[c] + 1
+				weight *= frac[c]
+			} else {
+				pos[c] = lo[c]
+				weight *= 1 - frac[c]
+			}
+		}
+		if weight == 0 {
+			continue
+		}
+		visit(lutIndex(pos[0], pos[1], pos[2], size), weight)
+	}
+}
+
+func lutIndex(r, g, b, size int) int {
+	return r + g*size + b*size*size
+}
+
+func clampUnit(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// sampleDimensions returns bounds scaled down so the long edge is at most maxEdge.
+func sampleDimensions(bounds image.Rectangle, maxEdge int) (int, int) {
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 {
+		return 0, 0
+	}
+	longEdge := max(width, height)
+	if longEdge <= maxEdge {
+		return width, height
+	}
+	scale := float64(maxEdge) / float64(longEdge)
+	return max(1, int(float64(width)*scale)), max(1, int(float64(height)*scale))
+}
+
+// resampleRGBA scales src to exactly width x height.
+func resampleRGBA(src image.Image, width, height int) *image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(canvas, canvas.Bounds(), src, src.Bounds(), draw.Src, nil)
+	return canvas
+}
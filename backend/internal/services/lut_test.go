@@ -0,0 +1,70 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFitColorLUT(t *testing.T) {
+	grade := func(c [3]float64) [3]float64 {
+		return [3]float64{
+			clampUnit(c[0]*1.1 + 0.05),
+			clampUnit(math.Pow(c[1], 0.8)),
+			clampUnit(c[2] * 0.9),
+		}
+	}
+
+	sample := func(x, y int) [3]float64 {
+		return [3]float64{float64(x) / 127, float64(y) / 127, float64((x+y)%128) / 127}
+	}
+
+	original := image.NewRGBA(image.Rect(0, 0, 128, 128))
+	enhanced := image.NewRGBA(image.Rect(0, 0, 128, 128))
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			in := sample(x, y)
+			original.Set(x, y, toRGBA(in))
+			enhanced.Set(x, y, toRGBA(grade(in)))
+		}
+	}
+
+	lut, err := FitColorLUT(original, enhanced, DefaultLUTSize)
+	if err != nil {
+		t.Fatalf("FitColorLUT() error = %v", err)
+	}
+
+	for _, in := range [][3]float64{sample(25, 38), sample(90, 20), sample(64, 100)} {
+		got := lut.Apply(in)
+		want := grade(in)
+		for c := 0; c < 3; c++ {
+			if math.Abs(got[c]-want[c]) > 0.03 {
+				t.Errorf("Apply(%v)[%d] = %.3f, want %.3f", in, c, got[c], want[c])
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := lut.WriteCube(&buffer, `my "look"`); err != nil {
+		t.Fatalf("WriteCube() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if lines[0] != `TITLE "my 'look'"` || lines[1] != "LUT_3D_SIZE 33" {
+		t.Errorf("unexpected header: %q", lines[:2])
+	}
+	if got, want := len(lines), 4+DefaultLUTSize*DefaultLUTSize*DefaultLUTSize; got != want {
+		t.Errorf("line count = %d, want %d", got, want)
+	}
+}
+
+func toRGBA(c [3]float64) color.RGBA {
+	return color.RGBA{
+		R: uint8(math.Round(c[0] * 255)),
+		G: uint8(math.Round(c[1] * 255)),
+		B: uint8(math.Round(c[2] * 255)),
+		A: 255,
+	}
+}
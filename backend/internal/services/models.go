@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Capability identifies which fallback chain a model call uses.
+type Capability string
+
+const (
+	CapabilityAnalysis    Capability = "analysis"
+	CapabilityComparison  Capability = "comparison"
+	CapabilityEnhancement Capability = "enhancement"
+	CapabilityAgent       Capability = "agent"
+)
+
+// Steps whose serving model is recorded on the CallTrace.
+const (
+	StepGenre                = "genre"
+	StepAnalysis             = "analysis"
+	StepAnalysisRepair       = "analysisRepair"
+	StepComparison           = "comparison"
+	StepAnnotatedEnhancement = "annotatedEnhancement"
+	StepCleanEnhancement     = "cleanEnhancement"
+	StepGeneration           = "generation"
+	StepChat                 = "chat"
+)
+
+// modelChainEnv maps each capability to the comma-separated env override of its chain.
+var modelChainEnv = map[Capability]string{
+	CapabilityAnalysis:    "GEMINI_ANALYSIS_MODELS",
+	CapabilityComparison:  "GEMINI_COMPARE_MODELS",
+	CapabilityEnhancement: "GEMINI_ENHANCE_MODELS",
+	CapabilityAgent:       "GEMINI_AGENT_MODELS",
+}
+
+// ModelChain returns the ordered models tried for a capability: the env
+// override if set, otherwise the primary model followed by a stable fallback.
+func ModelChain(capability Capability) []string {
+	if raw := strings.TrimSpace(os.Getenv(modelChainEnv[capability])); raw != "" {
+		var chain []string
+		for _, name := range strings.Split(raw, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				chain = append(chain, name)
+			}
+		}
+		if len(chain) > 0 {
+			return chain
+		}
+	}
+	if capability == CapabilityEnhancement {
+		return []string{"gemini-3-pro-image-preview", "gemini-2.5-flash-image"}
+	}
+	return uniqueModels(modelName(), "gemini-2.5-flash")
+}
+
+func uniqueModels(names ...string) []string {
+	var chain []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			chain = append(chain, name)
+		}
+	}
+	return chain
+}
+
+// modelCall describes the accepted call of a fallback chain.
+type modelCall struct {
+	Model string
+	Usage UsageTotals
+}
+
+// generateWithFallback tries each model in chain until one answers with a
+// response that accept considers usable. The serving model is recorded on the
+// context's CallTrace under step.
+func (g *GeminiClient) generateWithFallback(
+	ctx context.Context,
+	step string,
+	chain []string,
+	contents []*genai.Content,
+	config *genai.GenerateContentConfig,
+	accept func(*genai.GenerateContentResponse) error,
+) (modelCall, error) {
+	var lastErr error
+	for i, model := range chain {
+		var usage UsageTotals
+		response, err := g.generateContent(ctx, model, contents, config)
+		if err == nil {
+			// Unusable responses are billed too, so record usage before accepting.
+			usage = Prices().Measure(model, response.UsageMetadata, countImages(response))
+			CallTraceFrom(ctx).RecordUsage(step, model, usage)
+			err = accept(response)
+		}
+		if err == nil {
+			if i > 0 {
+				log.Printf("INFO: %s served by fallback model %s", step, model)
+			}
+			CallTraceFrom(ctx).RecordModel(step, model)
+			return modelCall{Model: model, Usage: usage}, nil
+		}
+		lastErr = fmt.Errorf("%s: %w", model, err)
+		if ctx.Err() != nil {
+			break
+		}
+		if i < len(chain)-1 {
+			log.Printf("WARN: %s failed on %s, falling back to %s: %v", step, model, chain[i+1], err)
+		}
+	}
+	if lastErr == nil {
+		return modelCall{}, fmt.Errorf("no models configured for %s", step)
+	}
+	return modelCall{}, lastErr
+}
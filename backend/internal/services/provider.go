@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"io"
+)
+
+// Analyzer scores a photo and returns the structured critique.
+type Analyzer interface {
+	AnalyzeImage(ctx context.Context, imageURL string) (*AnalysisResult, error)
+}
+
+// Comparer explains the difference between an original and an improved photo.
+type Comparer interface {
+	CompareAndAdvise(ctx context.Context, originalURL, transformedURL, analysisJSON string) (string, error)
+}
+
+// Enhancer generates images: improved versions of a photo or free-form images.
+type Enhancer interface {
+	EnhancePhoto(ctx context.Context, input EnhancementInput) (*ImageGenerationResult, error)
+	EnhancePhotoClean(ctx context.Context, input EnhancementInput) (*ImageGenerationResult, error)
+	GenerateImage(ctx context.Context, prompt string) (*ImageGenerationResult, error)
+}
+
+// ModelProvider bundles every model capability the backend uses.
+type ModelProvider interface {
+	Analyzer
+	Comparer
+	Enhancer
+}
+
+// ObjectStore stores and serves uploaded and generated images.
+type ObjectStore interface {
+	UploadImage(ctx context.Context, data []byte, contentType string) (string, error)
+	UploadImageWithPrefix(ctx context.Context, data []byte, contentType, prefix string) (string, string, error)
+	OpenObject(ctx context.Context, objectName string) (io.ReadCloser, string, int64, error)
+}
+
+var (
+	_ ModelProvider = (*GeminiClient)(nil)
+	_ ObjectStore   = (*StorageClient)(nil)
+)
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// ErrorClass groups model call failures by how they should be handled.
+type ErrorClass string
+
+const (
+	// ErrorClassRetryable covers transient upstream failures: 5xx, timeouts, dropped connections.
+	ErrorClassRetryable ErrorClass = "retryable"
+	// ErrorClassQuota covers rate limiting and exhausted quota (429).
+	ErrorClassQuota ErrorClass = "quota"
+	// ErrorClassFatal covers failures a retry cannot fix, such as invalid requests.
+	ErrorClassFatal ErrorClass = "fatal"
+)
+
+// ErrCircuitOpen is returned without calling the model while its circuit breaker is open.
+var ErrCircuitOpen = errors.New("model temporarily unavailable (circuit open)")
+
+// ClassifyError maps a model call error onto an ErrorClass.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return ErrorClassRetryable
+	}
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusTooManyRequests:
+			return ErrorClassQuota
+		case http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return ErrorClassRetryable
+		}
+		return ErrorClassFatal
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassFatal
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorClassRetryable
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorClassRetryable
+	}
+	return ErrorClassFatal
+}
+
+// ResiliencePolicy configures retries and circuit breaking for model calls.
+type ResiliencePolicy struct {
+	// MaxAttempts is the total number of calls per request, including the first.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles per retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// QuotaDelayFactor stretches the backoff after 429 responses.
+	QuotaDelayFactor int
+	// BreakerThreshold consecutive upstream failures open a model's circuit for BreakerCooldown.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// DefaultResiliencePolicy returns the policy for Gemini calls, overridable
+// with GEMINI_MAX_ATTEMPTS, CIRCUIT_BREAKER_THRESHOLD and CIRCUIT_BREAKER_COOLDOWN.
+func DefaultResiliencePolicy() ResiliencePolicy {
+	policy := ResiliencePolicy{
+		MaxAttempts:      4,
+		BaseDelay:        time.Second,
+		MaxDelay:         16 * time.Second,
+		QuotaDelayFactor: 4,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+	if value, ok := positiveIntEnv("GEMINI_MAX_ATTEMPTS"); ok {
+		policy.MaxAttempts = value
+	}
+	if value, ok := positiveIntEnv("CIRCUIT_BREAKER_THRESHOLD"); ok {
+		policy.BreakerThreshold = value
+	}
+	if raw := strings.TrimSpace(os.Getenv("CIRCUIT_BREAKER_COOLDOWN")); raw != "" {
+		if value, err := time.ParseDuration(raw); err == nil && value > 0 {
+			policy.BreakerCooldown = value
+		} else {
+			log.Printf("WARN: Invalid CIRCUIT_BREAKER_COOLDOWN %q, using %s", raw, policy.BreakerCooldown)
+		}
+	}
+	return policy
+}
+
+func positiveIntEnv(key string) (int, bool) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return 0, false
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 {
+		log.Printf("WARN: Invalid %s %q, using default", key, raw)
+		return 0, false
+	}
+	return value, true
+}
+
+// Resilience retries model calls with jittered exponential backoff and keeps
+// one circuit breaker per model.
+type Resilience struct {
+	policy   ResiliencePolicy
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+// NewResilience creates a resilience layer with the given policy.
+func NewResilience(policy ResiliencePolicy) *Resilience {
+	return &Resilience{policy: policy, breakers: map[string]*circuitBreaker{}}
+}
+
+// Do runs call against model, retrying retryable and quota errors while
+// attempts remain and the backoff fits inside the context deadline. Retries
+// and failure classes are recorded on the context's CallTrace, if any.
+func (r *Resilience) Do(ctx context.Context, model string, call func(context.Context) error) error {
+	breaker := r.breaker(model)
+	trace := CallTraceFrom(ctx)
+
+	var lastErr error
+	for attempt := 0; attempt < r.policy.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := r.backoff(attempt, ClassifyError(lastErr))
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+				log.Printf("WARN: %s: no time left for retry %d before the deadline", model, attempt)
+				break
+			}
+			log.Printf("WARN: %s call failed (%s), retrying in %s: %v", model, ClassifyError(lastErr), delay.Round(time.Millisecond), lastErr)
+			select {
+			case <-ctx.Done():
+				return lastErr
+			case <-time.After(delay):
+			}
+			trace.addRetry()
+		}
+
+		if !breaker.allow() {
+			lastErr = fmt.Errorf("%w: %s", ErrCircuitOpen, model)
+			trace.recordFailure(ErrorClassRetryable)
+			return lastErr
+		}
+
+		err := call(ctx)
+		class := ClassifyError(err)
+		if err == nil || class == ErrorClassFatal {
+			// The upstream answered; a fatal error is about the request, not its health.
+			breaker.recordSuccess()
+		} else {
+			breaker.recordFailure(r.policy.BreakerThreshold, r.policy.BreakerCooldown)
+		}
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+		trace.recordFailure(class)
+		if class == ErrorClassFatal || ctx.Err() != nil {
+			return err
+		}
+	}
+	return lastErr
+}
+
+// backoff returns the jittered delay before the given retry: half fixed, half random.
+func (r *Resilience) backoff(attempt int, class ErrorClass) time.Duration {
+	delay := r.policy.BaseDelay << (attempt - 1)
+	if class == ErrorClassQuota && r.policy.QuotaDelayFactor > 1 {
+		delay *= time.Duration(r.policy.QuotaDelayFactor)
+	}
+	if delay <= 0 || delay > r.policy.MaxDelay {
+		delay = r.policy.MaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+func (r *Resilience) breaker(model string) *circuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	breaker, ok := r.breakers[model]
+	if !ok {
+		breaker = &circuitBreaker{}
+		r.breakers[model] = breaker
+	}
+	return breaker
+}
+
+// circuitBreaker opens after consecutive upstream failures, rejects calls
+// until the cooldown has passed, then lets a single probe through.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openUntil.IsZero() {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *circuitBreaker) recordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+	b.probing = false
+}
+
+func (b *circuitBreaker) recordFailure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.probing || b.failures >= threshold {
+		b.openUntil = time.Now().Add(cooldown)
+		b.probing = false
+	}
+}
+
+// CallTrace collects retry statistics, serving models and usage for the model
+// calls made under one context.
+type CallTrace struct {
+	mu         sync.Mutex
+	retries    int
+	errorClass ErrorClass
+	models     map[string]string
+	usage      UsageReport
+	cacheHits  []string
+	cacheSaved float64
+}
+
+type callTraceKey struct{}
+
+// WithCallTrace returns a context that records model call retries into the returned trace.
+func WithCallTrace(ctx context.Context) (context.Context, *CallTrace) {
+	trace := &CallTrace{}
+	return context.WithValue(ctx, callTraceKey{}, trace), trace
+}
+
+// CallTraceFrom returns the context's trace, or nil.
+func CallTraceFrom(ctx context.Context) *CallTrace {
+	trace, _ := ctx.Value(callTraceKey{}).(*CallTrace)
+	return trace
+}
+
+// Retries returns how many times calls were retried.
+func (t *CallTrace) Retries() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.retries
+}
+
+// ErrorClass returns the class of the most recent failed call, or "" if none failed.
+func (t *CallTrace) ErrorClass() ErrorClass {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.errorClass
+}
+
+func (t *CallTrace) addRetry() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.retries++
+	t.mu.Unlock()
+}
+
+func (t *CallTrace) recordFailure(class ErrorClass) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.errorClass = class
+	t.mu.Unlock()
+}
+
+// RecordModel notes the model that served a step.
+func (t *CallTrace) RecordModel(step, model string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.models == nil {
+		t.models = map[string]string{}
+	}
+	t.models[step] = model
+}
+
+// Models returns the serving model per step.
+func (t *CallTrace) Models() map[string]string {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.models) == 0 {
+		return nil
+	}
+	models := make(map[string]string, len(t.models))
+	for step, model := range t.models {
+		models[step] = model
+	}
+	return models
+}
+
+// RecordUsage adds the priced usage of one call made for step on model.
+func (t *CallTrace) RecordUsage(step, model string, totals UsageTotals) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage.Record(step, model, totals)
+}
+
+// Usage returns a copy of the usage recorded so far.
+func (t *CallTrace) Usage() UsageReport {
+	var report UsageReport
+	if t == nil {
+		return report
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report.Merge(t.usage)
+	return report
+}
+
+// RecordCacheHit notes that step was served from the result cache, saving costUSD.
+func (t *CallTrace) RecordCacheHit(step string, costUSD float64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cacheHits = append(t.cacheHits, step)
+	t.cacheSaved += costUSD
+}
+
+// CacheHits returns the steps served from the result cache and the cost they saved.
+func (t *CallTrace) CacheHits() ([]string, float64) {
+	if t == nil {
+		return nil, 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.cacheHits...), t.cacheSaved
+}
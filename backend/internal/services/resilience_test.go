@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func testPolicy() ResiliencePolicy {
+	return ResiliencePolicy{
+		MaxAttempts:      4,
+		BaseDelay:        time.Millisecond,
+		MaxDelay:         4 * time.Millisecond,
+		QuotaDelayFactor: 2,
+		BreakerThreshold: 3,
+		BreakerCooldown:  time.Hour,
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"nil", nil, ""},
+		{"rate limited", genai.APIError{Code: 429}, ErrorClassQuota},
+		{"unavailable", fmt.Errorf("wrapped: %w", genai.APIError{Code: 503}), ErrorClassRetryable},
+		{"gateway timeout", genai.APIError{Code: 504}, ErrorClassRetryable},
+		{"bad request", genai.APIError{Code: 400}, ErrorClassFatal},
+		{"deadline", context.DeadlineExceeded, ErrorClassRetryable},
+		{"canceled", context.Canceled, ErrorClassFatal},
+		{"circuit open", fmt.Errorf("%w: model", ErrCircuitOpen), ErrorClassRetryable},
+		{"other", errors.New("boom"), ErrorClassFatal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResilienceRetriesTransientErrors(t *testing.T) {
+	resilience := NewResilience(testPolicy())
+	ctx, trace := WithCallTrace(context.Background())
+
+	calls := 0
+	err := resilience.Do(ctx, "model-a", func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return genai.APIError{Code: 503}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if calls != 3 || trace.Retries() != 2 {
+		t.Errorf("calls = %d, retries = %d; want 3 and 2", calls, trace.Retries())
+	}
+	if trace.ErrorClass() != ErrorClassRetryable {
+		t.Errorf("error class = %q", trace.ErrorClass())
+	}
+}
+
+func TestResilienceDoesNotRetryFatalErrors(t *testing.T) {
+	resilience := NewResilience(testPolicy())
+	calls := 0
+	err := resilience.Do(context.Background(), "model-a", func(context.Context) error {
+		calls++
+		return genai.APIError{Code: 400}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("err = %v, calls = %d; want an error after 1 call", err, calls)
+	}
+}
+
+func TestResilienceStopsAtDeadline(t *testing.T) {
+	policy := testPolicy()
+	policy.BaseDelay = time.Second
+	policy.MaxDelay = time.Second
+	resilience := NewResilience(policy)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+	_ = resilience.Do(ctx, "model-a", func(context.Context) error {
+		calls++
+		return genai.APIError{Code: 503}
+	})
+	if calls != 1 || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("calls = %d after %s; want a single call without waiting", calls, time.Since(start))
+	}
+}
+
+func TestCircuitBreakerFailsFast(t *testing.T) {
+	policy := testPolicy()
+	policy.MaxAttempts = 1
+	resilience := NewResilience(policy)
+	failing := func(context.Context) error { return genai.APIError{Code: 503} }
+
+	for i := 0; i < policy.BreakerThreshold; i++ {
+		_ = resilience.Do(context.Background(), "model-a", failing)
+	}
+
+	called := false
+	err := resilience.Do(context.Background(), "model-a", func(context.Context) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, ErrCircuitOpen) || called {
+		t.Errorf("err = %v, called = %v; want ErrCircuitOpen without calling", err, called)
+	}
+
+	if err := resilience.Do(context.Background(), "model-b", func(context.Context) error { return nil }); err != nil {
+		t.Errorf("other model should be unaffected: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/prompts"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/purpose"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/skill"
+)
+
+// chosenRubric returns the rubric the user picked by ID or by genre; ok is
+// false when the genre still has to be detected.
+func chosenRubric(ctx context.Context) (rubric.Rubric, rubric.Genre, bool) {
+	genre, genreChosen := rubric.GenreFromContext(ctx)
+	if id, ok := rubric.IDFromContext(ctx); ok {
+		if chosen, found := rubric.Default().Get(id); found {
+			if !genreChosen {
+				genre = chosen.Genre
+			}
+			return chosen, genre, true
+		}
+		log.Printf("WARN: Unknown rubric %s requested, selecting by genre", id)
+	}
+	if genreChosen {
+		return rubric.Default().ForGenre(genre), genre, true
+	}
+	return rubric.Rubric{}, "", false
+}
+
+// resultRubric returns the rubric an analysis was scored with, or the
+// general rubric for results stored before rubrics existed.
+func resultRubric(analysis *AnalysisResult) rubric.Rubric {
+	if r, ok := rubric.Default().Get(analysis.RubricID); ok {
+		return r
+	}
+	return rubric.Default().ForGenre(rubric.General)
+}
+
+// rubricFingerprint identifies a rubric's content so edits to a custom
+// rubric invalidate cached analyses.
+func rubricFingerprint(r rubric.Rubric) string {
+	encoded, _ := json.Marshal(r)
+	return string(encoded)
+}
+
+// applyRubric records the rubric on a parsed result, fills the core
+// category fields from Scores and computes the overall score locally.
+func (a *AnalysisResult) applyRubric(r rubric.Rubric, genre rubric.Genre, detected bool) {
+	scale := r.Scale
+	a.Scale = &scale
+	a.Genre = string(genre)
+	a.GenreDetected = detected
+	a.RubricID = r.ID
+	a.syncCoreCategories()
+	a.ModelOverallScore = a.OverallScore
+	if overall, ok := weightedOverallScore(a, r); ok {
+		a.OverallScore = overall
+	}
+}
+
+// analysisData fills the analysis template from a rubric in the context's locale.
+func analysisData(ctx context.Context, r rubric.Rubric, genre rubric.Genre) prompts.AnalysisData {
+	settings := locale.FromContext(ctx)
+	data := prompts.AnalysisData{
+		ScaleMin: r.Scale.Min,
+		ScaleMax: r.Scale.Max,
+		Weighted: r.Weighted(),
+		// The level changes the strictness of the scale, not only the tone.
+		SkillLevel: string(skill.FromContext(ctx)),
+		Purpose:    string(purpose.FromContext(ctx)),
+		Brief:      BriefFromContext(ctx),
+	}
+	if data.Purpose == string(purpose.Print) {
+		if size, ok := purpose.SourceSizeFromContext(ctx); ok {
+			data.PrintSize = purpose.PrintSize(size.Width, size.Height)
+		}
+	}
+	if genre != "" && genre != rubric.General {
+		data.Genre = settings.T("genre." + string(genre))
+	}
+	if r.Genre == "" {
+		data.Rubric = r.Name.In(settings.Locale)
+	}
+	for _, category := range r.Categories {
+		weight := ""
+		if w := r.Weight(category.Key); w != 1 {
+			weight = strconv.FormatFloat(w, 'g', -1, 64)
+		}
+		data.Categories = append(data.Categories, prompts.CategoryData{
+			Key:         category.Key,
+			Name:        category.Name.In(settings.Locale),
+			Description: category.Description.In(settings.Locale),
+			Weight:      weight,
+		})
+	}
+	return data
+}
+
+// rescaleScore maps a 0-10 score onto a rubric scale.
+func rescaleScore(score int, scale rubric.Scale) int {
+	return scale.Min + int(math.Round(float64(score*(scale.Max-scale.Min))/10))
+}
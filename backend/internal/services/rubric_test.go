@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/prompts"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+)
+
+func TestRubricShapesPromptAndSchema(t *testing.T) {
+	club, err := rubric.Parse([]byte(`{"id": "club", "name": "写真部審査シート", "scale": {"min": 1, "max": 5},
+		"categories": [{"key": "impact", "name": "インパクト", "weight": 2}, {"key": "technique", "name": "技術"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		rubric     rubric.Rubric
+		genre      rubric.Genre
+		wantKeys   []string
+		wantPrompt []string
+	}{
+		{name: "general", rubric: rubric.Default().ForGenre(rubric.General), genre: rubric.General, wantKeys: []string{"composition", "intentClarity"}, wantPrompt: []string{"0〜10点"}},
+		{name: "portrait", rubric: rubric.Default().ForGenre(rubric.Portrait), genre: rubric.Portrait, wantKeys: []string{"focus", "eyeSharpness", "skinTone"}, wantPrompt: []string{"ポートレート", "[重み ×1.5]"}},
+		{name: "club", rubric: club, wantKeys: []string{"impact", "technique"}, wantPrompt: []string{"写真部審査シート", "1〜5点", "impact(インパクト) [重み ×2]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, _, err := prompts.Default().RenderContext(context.Background(), prompts.Analysis, analysisData(context.Background(), tt.rubric, tt.genre))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.wantPrompt {
+				if !strings.Contains(text, want) {
+					t.Errorf("prompt lacks %q:\n%s", want, text)
+				}
+			}
+
+			schema := analysisResponseSchema(locale.Japanese, tt.rubric)
+			scores := schema.Properties["scores"]
+			if len(scores.Properties) != len(tt.rubric.Categories) {
+				t.Errorf("schema has %d categories, want %d", len(scores.Properties), len(tt.rubric.Categories))
+			}
+			for _, key := range tt.wantKeys {
+				if _, ok := scores.Properties[key]; !ok {
+					t.Errorf("schema lacks category %s", key)
+				}
+				if !strings.Contains(text, key) {
+					t.Errorf("prompt lacks category %s", key)
+				}
+			}
+			if max := *schema.Properties["overallScore"].Maximum; max != float64(tt.rubric.Scale.Max) {
+				t.Errorf("overall maximum = %v", max)
+			}
+		})
+	}
+}
+
+func TestApplyRubricSyncsCoreCategories(t *testing.T) {
+	result := &AnalysisResult{Scores: map[string]CategoryScore{
+		"composition":  {Score: 8, Comment: "良い"},
+		"eyeSharpness": {Score: 4},
+	}}
+	result.applyRubric(rubric.Default().ForGenre(rubric.Portrait), rubric.Portrait, true)
+	if result.Composition.Score != 8 || result.RubricID != "portrait-v1" || result.MaxScore() != 10 {
+		t.Errorf("result = %+v", result)
+	}
+	if categories := result.Categories(); categories["eyeSharpness"].Score != 4 || len(categories) != 2 {
+		t.Errorf("categories = %v", categories)
+	}
+
+	legacy := &AnalysisResult{Exposure: CategoryScore{Score: 7}}
+	if categories := legacy.Categories(); len(categories) != 8 || categories["exposure"].Score != 7 {
+		t.Errorf("legacy categories = %v", categories)
+	}
+}
@@ -93,7 +93,7 @@ func (s *StorageClient) UploadFromReader(ctx context.Context, reader io.Reader,
 	return fmt.Sprintf("gs://%s/%s", s.bucketName, objectName), nil
 }
 
-func (s *StorageClient) OpenObject(ctx context.Context, objectName string) (*storage.Reader, string, int64, error) {
+func (s *StorageClient) OpenObject(ctx context.Context, objectName string) (io.ReadCloser, string, int64, error) {
 	if s.bucketName == "" {
 		return nil, "", 0, fmt.Errorf("BUCKET_NAME is required")
 	}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// maxUpscalePixels caps the output size to keep memory bounded on Cloud
+	// Run: the transfer holds two full-size RGBA buffers (64MB each at the
+	// cap) next to the decoded original and the encoded result.
+	maxUpscalePixels = 16_000_000
+	// guidedRadius is the guided filter window radius at the enhanced resolution.
+	guidedRadius = 2
+	// guidedEpsilon regularizes the local linear model; in squared [0,1] units.
+	guidedEpsilon = 0.0001
+)
+
+// UpscaleWithDetail brings enhanced up to the resolution of original while
+// keeping the original's high-frequency detail. It fits a guided-filter linear
+// model (enhanced ≈ a·original + b) per channel at the enhanced resolution,
+// upsamples the smooth a/b coefficient maps and applies them to the
+// full-resolution original: tones and colors come from the enhancement,
+// edges and texture from the camera file.
+func UpscaleWithDetail(enhanced, original image.Image) *image.RGBA {
+	width, height := original.Bounds().Dx(), original.Bounds().Dy()
+	if pixels := width * height; pixels > maxUpscalePixels {
+		scale := math.Sqrt(float64(maxUpscalePixels) / float64(pixels))
+		width = int(float64(width) * scale)
+		height = int(float64(height) * scale)
+	}
+
+	lowWidth, lowHeight := enhanced.Bounds().Dx(), enhanced.Bounds().Dy()
+	lowInput := resampleRGBA(enhanced, lowWidth, lowHeight)
+	lowGuide := image.NewRGBA(image.Rect(0, 0, lowWidth, lowHeight))
+	draw.CatmullRom.Scale(lowGuide, lowGuide.Bounds(), original, original.Bounds(), draw.Src, nil)
+
+	fullGuide := image.NewRGBA(image.Rect(0, 0, width, height))
+	if width == original.Bounds().Dx() && height == original.Bounds().Dy() {
+		draw.Draw(fullGuide, fullGuide.Bounds(), original, original.Bounds().Min, draw.Src)
+	} else {
+		draw.CatmullRom.Scale(fullGuide, fullGuide.Bounds(), original, original.Bounds(), draw.Src, nil)
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	scaleX := float64(lowWidth) / float64(width)
+	scaleY := float64(lowHeight) / float64(height)
+	for c := 0; c < 3; c++ {
+		a, b := guidedCoefficients(lowGuide, lowInput, c)
+		for y := 0; y < height; y++ {
+			ly := (float64(y)+0.5)*scaleY - 0.5
+			for x := 0; x < width; x++ {
+				lx := (float64(x)+0.5)*scaleX - 0.5
+				i := fullGuide.PixOffset(x, y)
+				g := float64(fullGuide.Pix[i+c]) / 255
+				q := bilinearAt(a, lowWidth, lowHeight, lx, ly)*g + bilinearAt(b, lowWidth, lowHeight, lx, ly)
+				out.Pix[i+c] = uint8(math.Round(clampUnit(q) * 255))
+			}
+		}
+	}
+	for i := 3; i < len(out.Pix); i += 4 {
+		out.Pix[i] = 255
+	}
+	return out
+}
+
+// guidedCoefficients returns the box-averaged guided filter coefficients a and
+// b mapping channel c of guide onto channel c of input. The slope is
+// regularized towards the local gain rather than zero.
+func guidedCoefficients(guide, input *image.RGBA, c int) ([]float64, []float64) {
+	width, height := guide.Bounds().Dx(), guide.Bounds().Dy()
+	n := width * height
+	guideCh := make([]float64, n)
+	inputCh := make([]float64, n)
+	product := make([]float64, n)
+	square := make([]float64, n)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			g := float64(guide.Pix[guide.PixOffset(x, y)+c]) / 255
+			p := float64(input.Pix[input.PixOffset(x, y)+c]) / 255
+			guideCh[i] = g
+			inputCh[i] = p
+			product[i] = g * p
+			square[i] = g * g
+		}
+	}
+
+	meanI := boxMean(guideCh, width, height, guidedRadius)
+	meanP := boxMean(inputCh, width, height, guidedRadius)
+	corrIP := boxMean(product, width, height, guidedRadius)
+	corrII := boxMean(square, width, height, guidedRadius)
+
+	a := make([]float64, n)
+	b := make([]float64, n)
+	for i := 0; i < n; i++ {
+		variance := corrII[i] - meanI[i]*meanI[i]
+		covariance := corrIP[i] - meanI[i]*meanP[i]
+		// Where the downscaled guide is too flat to fit a slope (texture finer
+		// than the enhanced resolution), fall back to a local gain so the
+		// original's detail is still carried over instead of smoothed away.
+		gain := math.Min(meanP[i]/math.Max(meanI[i], 0.02), 4)
+		a[i] = (covariance + guidedEpsilon*gain) / (variance + guidedEpsilon)
+		b[i] = meanP[i] - a[i]*meanI[i]
+	}
+	return boxMean(a, width, height, guidedRadius), boxMean(b, width, height, guidedRadius)
+}
+
+// bilinearAt samples a width x height grid at fractional coordinates, clamping at the borders.
+func bilinearAt(grid []float64, width, height int, x, y float64) float64 {
+	x = math.Max(0, math.Min(x, float64(width-1)))
+	y = math.Max(0, math.Min(y, float64(height-1)))
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, width-1), min(y0+1, height-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+	top := grid[y0*width+x0]*(1-fx) + grid[y0*width+x1]*fx
+	bottom := grid[y1*width+x0]*(1-fx) + grid[y1*width+x1]*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// boxMean returns the mean of src over a (2r+1)² window, clipped at the borders.
+func boxMean(src []float64, width, height, radius int) []float64 {
+	stride := width + 1
+	integral := make([]float64, stride*(height+1))
+	for y := 0; y < height; y++ {
+		rowSum := 0.0
+		for x := 0; x < width; x++ {
+			rowSum += src[y*width+x]
+			integral[(y+1)*stride+x+1] = integral[y*stride+x+1] + rowSum
+		}
+	}
+
+	out := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		y0, y1 := max(y-radius, 0), min(y+radius+1, height)
+		for x := 0; x < width; x++ {
+			x0, x1 := max(x-radius, 0), min(x+radius+1, width)
+			sum := integral[y1*stride+x1] - integral[y0*stride+x1] - integral[y1*stride+x0] + integral[y0*stride+x0]
+			out[y*width+x] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	return out
+}
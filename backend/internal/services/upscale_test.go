@@ -0,0 +1,101 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+func TestUpscaleWithDetail(t *testing.T) {
+	tests := []struct {
+		name     string
+		original func(x, y int) color.RGBA
+		grade    func(c color.RGBA) color.RGBA
+	}{
+		{
+			name: "fine stripes with brightened shadows",
+			original: func(x, y int) color.RGBA {
+				v := uint8(60 + 80*((x/2+y/3)%2))
+				return color.RGBA{v, v / 2, 255 - v, 255}
+			},
+			grade: func(c color.RGBA) color.RGBA {
+				lift := func(v uint8) uint8 { return uint8(math.Min(255, 25+float64(v)*1.1)) }
+				return color.RGBA{lift(c.R), lift(c.G), lift(c.B), 255}
+			},
+		},
+		{
+			name: "noise texture with warmer white balance",
+			original: func(x, y int) color.RGBA {
+				v := uint8((x*7919 + y*104729 + x*y*31) % 200)
+				return color.RGBA{v + 20, v + 30, v + 40, 255}
+			},
+			grade: func(c color.RGBA) color.RGBA {
+				return color.RGBA{uint8(math.Min(255, float64(c.R)*1.15)), c.G, uint8(float64(c.B) * 0.85), 255}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := image.NewRGBA(image.Rect(0, 0, 640, 480))
+			ideal := image.NewRGBA(original.Bounds())
+			for y := 0; y < 480; y++ {
+				for x := 0; x < 640; x++ {
+					c := tt.original(x, y)
+					original.SetRGBA(x, y, c)
+					ideal.SetRGBA(x, y, tt.grade(c))
+				}
+			}
+
+			// The image model returns a ~4x smaller rendition of the graded photo.
+			enhanced := image.NewRGBA(image.Rect(0, 0, 160, 120))
+			draw.CatmullRom.Scale(enhanced, enhanced.Bounds(), ideal, ideal.Bounds(), draw.Src, nil)
+			naive := image.NewRGBA(original.Bounds())
+			draw.CatmullRom.Scale(naive, naive.Bounds(), enhanced, enhanced.Bounds(), draw.Src, nil)
+
+			upscaled := UpscaleWithDetail(enhanced, original)
+			if upscaled.Bounds() != original.Bounds() {
+				t.Fatalf("bounds = %v, want %v", upscaled.Bounds(), original.Bounds())
+			}
+
+			detailErr := meanAbsoluteError(upscaled, ideal)
+			naiveErr := meanAbsoluteError(naive, ideal)
+			if detailErr >= naiveErr*0.6 {
+				t.Errorf("detail transfer error %.2f not clearly below plain resize error %.2f", detailErr, naiveErr)
+			}
+		})
+	}
+}
+
+func TestUpscaleWithDetailCapsPixels(t *testing.T) {
+	if testing.Short() {
+		t.Skip("allocates full-size buffers at the pixel cap")
+	}
+	// A 24MP camera file, larger than the cap.
+	original := image.NewGray(image.Rect(0, 0, 6000, 4000))
+	enhanced := image.NewRGBA(image.Rect(0, 0, 150, 100))
+
+	upscaled := UpscaleWithDetail(enhanced, original)
+	width, height := upscaled.Bounds().Dx(), upscaled.Bounds().Dy()
+	if width*height > maxUpscalePixels || width*height < maxUpscalePixels*99/100 {
+		t.Errorf("upscaled to %dx%d = %d pixels, want just under the %d pixel cap", width, height, width*height, maxUpscalePixels)
+	}
+	if math.Abs(float64(width)/float64(height)-1.5) > 0.001 {
+		t.Errorf("upscaled to %dx%d, want the original 3:2 aspect ratio", width, height)
+	}
+}
+
+func meanAbsoluteError(a, b *image.RGBA) float64 {
+	total := 0.0
+	count := 0
+	for i := 0; i < len(a.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			total += math.Abs(float64(a.Pix[i+c]) - float64(b.Pix[i+c]))
+			count++
+		}
+	}
+	return total / float64(count)
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"google.golang.org/genai"
+)
+
+// ModelPrice is the list price of one model in USD.
+type ModelPrice struct {
+	InputPerMillion       float64 `json:"inputPerMillion"`
+	OutputPerMillion      float64 `json:"outputPerMillion"`
+	ImageOutputPerMillion float64 `json:"imageOutputPerMillion,omitempty"`
+	// PerImage is a flat charge per generated image, for models billed per image.
+	PerImage float64 `json:"perImage,omitempty"`
+}
+
+// PriceTable maps model names to prices.
+type PriceTable map[string]ModelPrice
+
+// defaultPrices are approximate list prices; override them with
+// PRICE_TABLE_PATH (a JSON file) or PRICE_TABLE_JSON.
+var defaultPrices = PriceTable{
+	"gemini-3-pro-preview":       {InputPerMillion: 2.00, OutputPerMillion: 12.00},
+	"gemini-3-flash-preview":     {InputPerMillion: 0.50, OutputPerMillion: 3.00},
+	"gemini-2.5-pro":             {InputPerMillion: 1.25, OutputPerMillion: 10.00},
+	"gemini-2.5-flash":           {InputPerMillion: 0.30, OutputPerMillion: 2.50},
+	"gemini-3-pro-image-preview": {InputPerMillion: 2.00, OutputPerMillion: 12.00, ImageOutputPerMillion: 120.00},
+	"gemini-2.5-flash-image":     {InputPerMillion: 0.30, OutputPerMillion: 2.50, ImageOutputPerMillion: 30.00},
+}
+
+var (
+	priceTableOnce sync.Once
+	priceTable     PriceTable
+	unpricedModels sync.Map
+)
+
+// Prices returns the configured price table, loaded once.
+func Prices() PriceTable {
+	priceTableOnce.Do(func() {
+		priceTable = PriceTable{}
+		for model, price := range defaultPrices {
+			priceTable[model] = price
+		}
+
+		raw := []byte(strings.TrimSpace(os.Getenv("PRICE_TABLE_JSON")))
+		if path := strings.TrimSpace(os.Getenv("PRICE_TABLE_PATH")); path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				log.Printf("WARN: Failed to read PRICE_TABLE_PATH %s, using default prices: %v", path, err)
+				return
+			}
+			raw = data
+		}
+		if len(raw) == 0 {
+			return
+		}
+		var overrides PriceTable
+		if err := json.Unmarshal(raw, &overrides); err != nil {
+			log.Printf("WARN: Invalid price table, using default prices: %v", err)
+			return
+		}
+		for model, price := range overrides {
+			priceTable[model] = price
+		}
+	})
+	return priceTable
+}
+
+// UsageTotals aggregates token counts and cost over one or more model calls.
+type UsageTotals struct {
+	Calls             int     `json:"calls"`
+	InputTokens       int64   `json:"inputTokens"`
+	OutputTokens      int64   `json:"outputTokens"`
+	ImageOutputTokens int64   `json:"imageOutputTokens,omitempty"`
+	Images            int     `json:"images,omitempty"`
+	CostUSD           float64 `json:"costUsd"`
+}
+
+// Add accumulates other into t.
+func (t *UsageTotals) Add(other UsageTotals) {
+	t.Calls += other.Calls
+	t.InputTokens += other.InputTokens
+	t.OutputTokens += other.OutputTokens
+	t.ImageOutputTokens += other.ImageOutputTokens
+	t.Images += other.Images
+	t.CostUSD += other.CostUSD
+}
+
+// Measure prices one call from its usage metadata and the number of images it returned.
+func (p PriceTable) Measure(model string, metadata *genai.GenerateContentResponseUsageMetadata, images int) UsageTotals {
+	totals := UsageTotals{Calls: 1, Images: images}
+	if metadata != nil {
+		totals.InputTokens = int64(metadata.PromptTokenCount) + int64(metadata.ToolUsePromptTokenCount)
+		for _, detail := range metadata.CandidatesTokensDetails {
+			if detail != nil && detail.Modality == genai.MediaModalityImage {
+				totals.ImageOutputTokens += int64(detail.TokenCount)
+			}
+		}
+		// Thinking tokens are billed as output.
+		totals.OutputTokens = int64(metadata.CandidatesTokenCount) + int64(metadata.ThoughtsTokenCount) - totals.ImageOutputTokens
+	}
+
+	price, ok := p[model]
+	if !ok {
+		if _, warned := unpricedModels.LoadOrStore(model, true); !warned {
+			log.Printf("WARN: No price configured for model %s; its cost is recorded as 0", model)
+		}
+		return totals
+	}
+	totals.CostUSD = float64(totals.InputTokens)*price.InputPerMillion/1e6 +
+		float64(totals.OutputTokens)*price.OutputPerMillion/1e6 +
+		float64(totals.ImageOutputTokens)*price.ImageOutputPerMillion/1e6 +
+		float64(images)*price.PerImage
+	return totals
+}
+
+// countImages returns how many inline images a response carries.
+func countImages(response *genai.GenerateContentResponse) int {
+	images := 0
+	for _, candidate := range response.Candidates {
+		if candidate == nil || candidate.Content == nil {
+			continue
+		}
+		for _, part := range candidate.Content.Parts {
+			if part.InlineData != nil && strings.HasPrefix(part.InlineData.MIMEType, "image/") {
+				images++
+			}
+		}
+	}
+	return images
+}
+
+// UsageReport breaks usage down by step and by model.
+type UsageReport struct {
+	Total   UsageTotals            `json:"total"`
+	ByStep  map[string]UsageTotals `json:"byStep,omitempty"`
+	ByModel map[string]UsageTotals `json:"byModel,omitempty"`
+}
+
+// Record adds one call's totals under step and model.
+func (r *UsageReport) Record(step, model string, totals UsageTotals) {
+	r.Total.Add(totals)
+	if r.ByStep == nil {
+		r.ByStep = map[string]UsageTotals{}
+	}
+	if r.ByModel == nil {
+		r.ByModel = map[string]UsageTotals{}
+	}
+	stepTotals := r.ByStep[step]
+	stepTotals.Add(totals)
+	r.ByStep[step] = stepTotals
+	modelTotals := r.ByModel[model]
+	modelTotals.Add(totals)
+	r.ByModel[model] = modelTotals
+}
+
+// Merge adds every total of other into r.
+func (r *UsageReport) Merge(other UsageReport) {
+	r.Total.Add(other.Total)
+	for step, totals := range other.ByStep {
+		if r.ByStep == nil {
+			r.ByStep = map[string]UsageTotals{}
+		}
+		merged := r.ByStep[step]
+		merged.Add(totals)
+		r.ByStep[step] = merged
+	}
+	for model, totals := range other.ByModel {
+		if r.ByModel == nil {
+			r.ByModel = map[string]UsageTotals{}
+		}
+		merged := r.ByModel[model]
+		merged.Add(totals)
+		r.ByModel[model] = merged
+	}
+}
+
+// Empty reports whether no calls were recorded.
+func (r UsageReport) Empty() bool {
+	return r.Total.Calls == 0
+}
+
+// ParseUsageReport decodes a report stored as JSON; an empty string yields an empty report.
+func ParseUsageReport(raw string) (UsageReport, error) {
+	var report UsageReport
+	if strings.TrimSpace(raw) == "" {
+		return report, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &report); err != nil {
+		return report, fmt.Errorf("invalid usage report: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestPriceTableMeasure(t *testing.T) {
+	prices := PriceTable{
+		"text-model":  {InputPerMillion: 1, OutputPerMillion: 10},
+		"image-model": {InputPerMillion: 2, OutputPerMillion: 12, ImageOutputPerMillion: 100},
+	}
+
+	tests := []struct {
+		name     string
+		model    string
+		metadata *genai.GenerateContentResponseUsageMetadata
+		images   int
+		want     UsageTotals
+	}{
+		{
+			name:     "text with thinking",
+			model:    "text-model",
+			metadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 1000, CandidatesTokenCount: 200, ThoughtsTokenCount: 300},
+			want:     UsageTotals{Calls: 1, InputTokens: 1000, OutputTokens: 500, CostUSD: 0.001 + 0.005},
+		},
+		{
+			name:  "image output",
+			model: "image-model",
+			metadata: &genai.GenerateContentResponseUsageMetadata{
+				PromptTokenCount:     500,
+				CandidatesTokenCount: 1390,
+				CandidatesTokensDetails: []*genai.ModalityTokenCount{
+					{Modality: genai.MediaModalityImage, TokenCount: 1290},
+					{Modality: genai.MediaModalityText, TokenCount: 100},
+				},
+			},
+			images: 1,
+			want:   UsageTotals{Calls: 1, InputTokens: 500, OutputTokens: 100, ImageOutputTokens: 1290, Images: 1, CostUSD: 0.001 + 0.0012 + 0.129},
+		},
+		{
+			name:     "unpriced model",
+			model:    "unknown-model",
+			metadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 10, CandidatesTokenCount: 5},
+			want:     UsageTotals{Calls: 1, InputTokens: 10, OutputTokens: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := prices.Measure(tt.model, tt.metadata, tt.images)
+			if math.Abs(got.CostUSD-tt.want.CostUSD) > 1e-9 {
+				t.Errorf("CostUSD = %v, want %v", got.CostUSD, tt.want.CostUSD)
+			}
+			got.CostUSD = tt.want.CostUSD
+			if got != tt.want {
+				t.Errorf("Measure() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsageReportMerge(t *testing.T) {
+	var first, second UsageReport
+	first.Record(StepAnalysis, "a", UsageTotals{Calls: 1, InputTokens: 10, CostUSD: 0.5})
+	second.Record(StepAnalysis, "b", UsageTotals{Calls: 1, InputTokens: 5, CostUSD: 0.25})
+	second.Record(StepCleanEnhancement, "b", UsageTotals{Calls: 1, Images: 1, CostUSD: 1})
+
+	first.Merge(second)
+	if first.Total.Calls != 3 || math.Abs(first.Total.CostUSD-1.75) > 1e-9 {
+		t.Errorf("total = %+v", first.Total)
+	}
+	if first.ByStep[StepAnalysis].InputTokens != 15 || first.ByModel["b"].Calls != 2 {
+		t.Errorf("breakdown = %+v / %+v", first.ByStep, first.ByModel)
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"google.golang.org/genai"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/prompts"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+)
+
+// decodeAnalysis parses a model response and checks it against the rubric.
+// A parse failure is reported as the only issue.
+func decodeAnalysis(settings locale.Settings, raw string, r rubric.Rubric) (*AnalysisResult, []string) {
+	var result AnalysisResult
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return nil, []string{settings.T("validate.parse", err.Error())}
+	}
+	return &result, validateAnalysis(settings, &result, r)
+}
+
+// validateAnalysis lists what is wrong with a parsed analysis: missing or
+// out-of-range category scores and empty required texts.
+func validateAnalysis(settings locale.Settings, result *AnalysisResult, r rubric.Rubric) []string {
+	var issues []string
+	for field, value := range map[string]string{
+		"photoSummary":   result.PhotoSummary,
+		"summary":        result.Summary,
+		"overallComment": result.OverallComment,
+	} {
+		if strings.TrimSpace(value) == "" {
+			issues = append(issues, settings.T("validate.empty", field))
+		}
+	}
+	for _, key := range r.Keys() {
+		score, ok := result.Scores[key]
+		if !ok {
+			issues = append(issues, settings.T("validate.missing", "scores."+key))
+			continue
+		}
+		if score.Score < r.Scale.Min || score.Score > r.Scale.Max {
+			issues = append(issues, settings.T("validate.range", "scores."+key+".score", score.Score, r.Scale.Min, r.Scale.Max))
+		}
+		if strings.TrimSpace(score.Comment) == "" {
+			issues = append(issues, settings.T("validate.empty", "scores."+key+".comment"))
+		}
+		if strings.TrimSpace(score.Improvement) == "" {
+			issues = append(issues, settings.T("validate.empty", "scores."+key+".improvement"))
+		}
+	}
+	sort.Strings(issues)
+	return issues
+}
+
+// sanitizeAnalysis keeps an analysis within its rubric: scores, the theme
+// score included, are clamped to the scale, region boxes are trimmed to the
+// frame and marks on unknown categories or outside the frame are dropped.
+func sanitizeAnalysis(result *AnalysisResult, r rubric.Rubric) {
+	for key, score := range result.Scores {
+		if _, ok := r.Category(key); !ok {
+			delete(result.Scores, key)
+			continue
+		}
+		score.Score = max(r.Scale.Min, min(r.Scale.Max, score.Score))
+		result.Scores[key] = score
+	}
+	keep := func(category string) bool {
+		_, ok := r.Category(category)
+		return ok
+	}
+	annotations := result.Annotations[:0]
+	for _, annotation := range result.Annotations {
+		if keep(annotation.Category) {
+			annotations = append(annotations, annotation)
+		}
+	}
+	result.Annotations = annotations
+	regions := result.Regions[:0]
+	for _, region := range result.Regions {
+		// Models overshoot the frame edges; boxes are trimmed to the photo.
+		region.Box = region.Box.Clamp()
+		if keep(region.Category) && region.Box.Validate() == nil {
+			regions = append(regions, region)
+		}
+	}
+	result.Regions = regions
+	if result.Theme != nil {
+		result.Theme.Score = max(r.Scale.Min, min(r.Scale.Max, result.Theme.Score))
+	}
+}
+
+// weightedOverallScore averages the category scores with the rubric
+// weights, which are configured in the rubric documents. ok is false when
+// no category was scored.
+func weightedOverallScore(result *AnalysisResult, r rubric.Rubric) (int, bool) {
+	sum, total := 0.0, 0.0
+	for _, key := range r.Keys() {
+		score, ok := result.Scores[key]
+		if !ok {
+			continue
+		}
+		weight := r.Weight(key)
+		sum += weight * float64(score.Score)
+		total += weight
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return int(math.Round(sum / total)), true
+}
+
+// repairAnalysis sends the model its own answer with the issues found and
+// asks for a corrected answer, once, on the model that served the analysis.
+func (g *GeminiClient) repairAnalysis(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig, raw string, issues []string) (string, error) {
+	repairPrompt, _, err := prompts.Default().RenderContext(ctx, prompts.AnalysisRepair, prompts.RepairData{Issues: issues})
+	if err != nil {
+		return "", err
+	}
+	conversation := append(append([]*genai.Content(nil), contents...),
+		genai.NewContentFromText(raw, genai.RoleModel),
+		genai.NewContentFromText(repairPrompt, genai.RoleUser),
+	)
+	response, err := g.generateContent(ctx, model, conversation, config)
+	if err != nil {
+		return "", err
+	}
+	trace := CallTraceFrom(ctx)
+	trace.RecordUsage(StepAnalysisRepair, model, Prices().Measure(model, response.UsageMetadata, 0))
+	trace.RecordModel(StepAnalysisRepair, model)
+	text := strings.TrimSpace(response.Text())
+	if text == "" {
+		return "", fmt.Errorf("empty repair response")
+	}
+	return text, nil
+}
+
+// finishAnalysis turns a raw model answer into a validated result. Invalid
+// answers get one targeted repair request; whatever is still wrong after
+// that is sanitized and listed on the result instead of failing the job.
+// Only an answer that cannot be parsed at all is an error.
+func (g *GeminiClient) finishAnalysis(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig, raw string, r rubric.Rubric) (*AnalysisResult, error) {
+	settings := locale.FromContext(ctx)
+	result, issues := decodeAnalysis(settings, raw, r)
+	repaired := false
+	if len(issues) > 0 {
+		log.Printf("WARN: Analysis response from %s has %d issues, requesting a repair: %s", model, len(issues), strings.Join(issues, "; "))
+		fixed, err := g.repairAnalysis(ctx, model, contents, config, raw, issues)
+		if err != nil {
+			log.Printf("WARN: Analysis repair failed: %v", err)
+		} else if fixedResult, fixedIssues := decodeAnalysis(settings, fixed, r); fixedResult != nil {
+			result, issues, repaired = fixedResult, fixedIssues, true
+		}
+	}
+	if result == nil {
+		return nil, fmt.Errorf("analysis response parse failed: %s", strings.Join(issues, "; "))
+	}
+	if len(issues) > 0 {
+		log.Printf("WARN: Accepting analysis with %d unresolved issues: %s", len(issues), strings.Join(issues, "; "))
+	}
+	sanitizeAnalysis(result, r)
+	result.Repaired = repaired
+	result.ValidationIssues = issues
+	return result, nil
+}
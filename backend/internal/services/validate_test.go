@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
+)
+
+// validAnswer returns a complete analysis answer for r with every category scored score.
+func validAnswer(r rubric.Rubric, score int) map[string]any {
+	scores := map[string]any{}
+	for _, key := range r.Keys() {
+		scores[key] = map[string]any{"score": score, "comment": "良い", "improvement": "もう少し"}
+	}
+	return map[string]any{
+		"photoSummary":   "街角",
+		"summary":        "まとまっている",
+		"overallComment": "良い写真です",
+		"overallScore":   9,
+		"scores":         scores,
+	}
+}
+
+func TestValidateAnalysis(t *testing.T) {
+	general := rubric.Default().ForGenre(rubric.General)
+	tests := []struct {
+		name       string
+		edit       func(map[string]any)
+		wantIssues []string
+	}{
+		{name: "valid", edit: func(map[string]any) {}},
+		{
+			name: "out of range",
+			edit: func(a map[string]any) {
+				a["scores"].(map[string]any)["focus"] = map[string]any{"score": 14, "comment": "x", "improvement": "y"}
+			},
+			wantIssues: []string{"scores.focus.score の値 14 が範囲外です(0〜10の整数)"},
+		},
+		{
+			name:       "missing category and empty comment",
+			edit:       func(a map[string]any) { delete(a["scores"].(map[string]any), "color"); a["overallComment"] = " " },
+			wantIssues: []string{"overallComment が空です", "scores.color がありません"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer := validAnswer(general, 6)
+			tt.edit(answer)
+			raw, _ := json.Marshal(answer)
+			result, issues := decodeAnalysis(locale.DefaultSettings(), string(raw), general)
+			if result == nil || strings.Join(issues, "|") != strings.Join(tt.wantIssues, "|") {
+				t.Fatalf("issues = %q, want %q", issues, tt.wantIssues)
+			}
+		})
+	}
+	if result, issues := decodeAnalysis(locale.DefaultSettings(), "{not json", general); result != nil || len(issues) != 1 {
+		t.Fatalf("unparseable answer: %v, %q", result, issues)
+	}
+}
+
+func TestParseRegionBoxes(t *testing.T) {
+	general := rubric.Default().ForGenre(rubric.General)
+	answer := validAnswer(general, 6)
+	answer["regions"] = []map[string]any{
+		{"box": map[string]any{"x": 0.1, "y": 0.2, "width": 0.3, "height": 0.4}, "category": "composition", "severity": "high", "comment": "inside"},
+		{"box": map[string]any{"x": -0.1, "y": 0.8, "width": 0.5, "height": 0.4}, "category": "exposure", "severity": "low", "comment": "overshoots"},
+		{"box": map[string]any{"x": 1.2, "y": 0.1, "width": 0.2, "height": 0.2}, "category": "color", "severity": "medium", "comment": "outside"},
+		{"box": map[string]any{"x": 0.1, "y": 0.1, "width": 0.2, "height": 0.2}, "category": "bokeh", "severity": "low", "comment": "unknown category"},
+	}
+	raw, _ := json.Marshal(answer)
+	result, issues := decodeAnalysis(locale.DefaultSettings(), string(raw), general)
+	if result == nil || len(issues) != 0 {
+		t.Fatalf("decode: %v, %q", result, issues)
+	}
+	sanitizeAnalysis(result, general)
+
+	want := []RegionCritique{
+		{Box: NormalizedBox{X: 0.1, Y: 0.2, Width: 0.3, Height: 0.4}, Category: "composition", Severity: SeverityHigh, Comment: "inside"},
+		{Box: NormalizedBox{X: 0, Y: 0.8, Width: 0.4, Height: 0.2}, Category: "exposure", Severity: SeverityLow, Comment: "overshoots"},
+	}
+	if len(result.Regions) != len(want) {
+		t.Fatalf("regions = %+v, want %+v", result.Regions, want)
+	}
+	for i, region := range result.Regions {
+		box, wantBox := region.Box, want[i].Box
+		near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+		if region.Category != want[i].Category || region.Severity != want[i].Severity || region.Comment != want[i].Comment ||
+			!near(box.X, wantBox.X) || !near(box.Y, wantBox.Y) || !near(box.Width, wantBox.Width) || !near(box.Height, wantBox.Height) {
+			t.Errorf("region %d = %+v, want %+v", i, region, want[i])
+		}
+	}
+}
+
+func TestWeightedOverallScore(t *testing.T) {
+	portrait := rubric.Default().ForGenre(rubric.Portrait)
+	result := &AnalysisResult{Scores: map[string]CategoryScore{}}
+	for _, key := range portrait.Keys() {
+		result.Scores[key] = CategoryScore{Score: 5}
+	}
+	// focus and lighting weigh 1.5, so raising them moves the score more than the others.
+	result.Scores["focus"] = CategoryScore{Score: 10}
+	result.Scores["lighting"] = CategoryScore{Score: 10}
+	result.OverallScore = 5
+	result.applyRubric(portrait, rubric.Portrait, true)
+	if result.ModelOverallScore != 5 || result.OverallScore != 6 {
+		t.Fatalf("overall = %d (model %d), want 6 (model 5)", result.OverallScore, result.ModelOverallScore)
+	}
+}
+
+func TestFinishAnalysisRepairsInvalidAnswer(t *testing.T) {
+	general := rubric.Default().ForGenre(rubric.General)
+	repaired, _ := json.Marshal(validAnswer(general, 7))
+	var repairRequest string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		repairRequest = string(body)
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []any{map[string]any{
+				"content": map[string]any{"role": "model", "parts": []any{map[string]any{"text": string(repaired)}}},
+			}},
+		})
+	}))
+	defer server.Close()
+
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:      "test",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &GeminiClient{client: client, resilience: NewResilience(DefaultResiliencePolicy())}
+	ctx, trace := WithCallTrace(context.Background())
+
+	first := validAnswer(general, 6)
+	first["scores"].(map[string]any)["exposure"] = map[string]any{"score": 42, "comment": "明るい", "improvement": ""}
+	raw, _ := json.Marshal(first)
+	result, err := g.finishAnalysis(ctx, "model-a", []*genai.Content{genai.NewContentFromText("analyze", genai.RoleUser)}, &genai.GenerateContentConfig{}, string(raw), general)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Repaired || len(result.ValidationIssues) != 0 || result.Scores["exposure"].Score != 7 {
+		t.Fatalf("result = %+v", result)
+	}
+	if !strings.Contains(repairRequest, "scores.exposure.score") || !strings.Contains(repairRequest, "scores.exposure.improvement") {
+		t.Errorf("repair request does not name the issues: %s", repairRequest)
+	}
+	if trace.Models()[StepAnalysisRepair] != "model-a" {
+		t.Errorf("repair model = %q", trace.Models()[StepAnalysisRepair])
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"iter"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -331,8 +332,23 @@ func (s *FirestoreService) AppendEvent(ctx context.Context, sess session.Session
 			}
 		}
 	}
+	// State-only events (tool state changes, handler state updates) have no content.
+	role := ""
+	if event.Content != nil {
+		role = event.Content.Role
+	}
 	log.Printf("INFO: AppendEvent called for session %s, author=%s, role=%s, content preview: %s",
-		sess.ID(), event.Author, event.Content.Role, contentPreview)
+		sess.ID(), event.Author, role, contentPreview)
+
+	// Apply the event's state delta; temp: keys live only for the invocation.
+	for key, value := range event.Actions.StateDelta {
+		if strings.HasPrefix(key, session.KeyPrefixTemp) {
+			continue
+		}
+		if err := sess.State().Set(key, value); err != nil {
+			return fmt.Errorf("failed to apply state delta key %s: %w", key, err)
+		}
+	}
 
 	// Get current state from the session
 	stateMap := make(map[string]any)
@@ -0,0 +1,86 @@
+// Package skill holds the photographer's skill level, which sets the tone,
+// the technical vocabulary and the strictness of critiques.
+package skill
+
+import (
+	"context"
+	"strings"
+)
+
+// Level is a photographer's skill level.
+type Level string
+
+const (
+	Beginner     Level = "beginner"
+	Intermediate Level = "intermediate"
+	Advanced     Level = "advanced"
+	Professional Level = "professional"
+)
+
+// Levels lists the skill levels from least to most experienced.
+var Levels = []Level{Beginner, Intermediate, Advanced, Professional}
+
+// Parse accepts a level name in any case.
+func Parse(raw string) (Level, bool) {
+	level := Level(strings.ToLower(strings.TrimSpace(raw)))
+	for _, known := range Levels {
+		if level == known {
+			return level, true
+		}
+	}
+	return "", false
+}
+
+// minInferenceHistory is the fewest analyses a level is inferred from.
+const minInferenceHistory = 3
+
+// maxInferenceHistory bounds the history to the most recent analyses, so
+// the level follows the photographer's progress.
+const maxInferenceHistory = 10
+
+// inferenceThresholds are the lowest mean score, as a share of the scale,
+// of each level above Beginner.
+var inferenceThresholds = []struct {
+	level Level
+	share float64
+}{
+	{Professional, 0.8},
+	{Advanced, 0.65},
+	{Intermediate, 0.5},
+}
+
+// Infer guesses the level from past overall scores given as shares of
+// their scale (score / max), newest first. ok is false when the history is
+// too short to tell.
+func Infer(history []float64) (Level, bool) {
+	if len(history) < minInferenceHistory {
+		return "", false
+	}
+	if len(history) > maxInferenceHistory {
+		history = history[:maxInferenceHistory]
+	}
+	mean := 0.0
+	for _, share := range history {
+		mean += share
+	}
+	mean /= float64(len(history))
+	for _, threshold := range inferenceThresholds {
+		if mean >= threshold.share {
+			return threshold.level, true
+		}
+	}
+	return Beginner, true
+}
+
+type levelKey struct{}
+
+// WithLevel makes critiques under ctx address a photographer of level.
+func WithLevel(ctx context.Context, level Level) context.Context {
+	return context.WithValue(ctx, levelKey{}, level)
+}
+
+// FromContext returns the level set on ctx, or "" when it is unknown.
+func FromContext(ctx context.Context) Level {
+	level, _ := ctx.Value(levelKey{}).(Level)
+	return level
+}
@@ -0,0 +1,54 @@
+package skill
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	if level, ok := Parse(" Advanced "); !ok || level != Advanced {
+		t.Errorf("Parse(Advanced) = %q, %v", level, ok)
+	}
+	if _, ok := Parse("expert"); ok {
+		t.Error("Parse accepted an unknown level")
+	}
+}
+
+func TestInfer(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []float64
+		want    Level
+		wantOK  bool
+	}{
+		{name: "too short", history: []float64{0.9, 0.9}},
+		{name: "beginner", history: []float64{0.4, 0.5, 0.3}, want: Beginner, wantOK: true},
+		{name: "intermediate", history: []float64{0.5, 0.6, 0.55}, want: Intermediate, wantOK: true},
+		{name: "advanced", history: []float64{0.7, 0.7, 0.6}, want: Advanced, wantOK: true},
+		{name: "professional", history: []float64{0.9, 0.8, 0.85}, want: Professional, wantOK: true},
+		{
+			// Only the ten newest scores count, so old beginner work is forgotten.
+			name:    "recent history",
+			history: []float64{0.85, 0.85, 0.85, 0.85, 0.85, 0.85, 0.85, 0.85, 0.85, 0.85, 0.1, 0.1, 0.1},
+			want:    Professional,
+			wantOK:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Infer(tt.history)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Infer = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	if level := FromContext(context.Background()); level != "" {
+		t.Errorf("empty context level = %q", level)
+	}
+	if level := FromContext(WithLevel(context.Background(), Beginner)); level != Beginner {
+		t.Errorf("level = %q, want beginner", level)
+	}
+}
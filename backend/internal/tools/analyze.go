@@ -4,70 +4,139 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"strings"
 	"time"
 
+	"google.golang.org/adk/session"
 	"google.golang.org/adk/tool"
 	"google.golang.org/adk/tool/functiontool"
 
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/purpose"
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/rubric"
 	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
 )
 
 type AnalyzePhotoArgs struct {
 	ImageURL string `json:"image_url" desc:"分析する画像のCloud Storage URL (gs://... 形式)"`
+	Genre    string `json:"genre,omitempty" desc:"ユーザーが指定したジャンル (portrait, landscape, street, macro, wildlife, night, product, architecture, general)。省略時は自動判定"`
+	Rubric   string `json:"rubric,omitempty" desc:"ユーザーが指定した採点ルーブリックのID。省略時はジャンルのルーブリック"`
+	Purpose  string `json:"purpose,omitempty" desc:"写真の用途 (contest, social, print, portfolio)。省略時は contest"`
+	Brief    string `json:"brief,omitempty" desc:"ユーザーが示したコンテストのテーマや応募規定の原文。省略時はテーマ評価なし"`
 }
 
-func analyzePhoto(tc tool.Context, args AnalyzePhotoArgs) (*services.AnalysisResult, error) {
+// analyzePhoto returns the analyze_photo tool function backed by analyzer.
+// Briefs are checked against the photo read back from storage.
+func analyzePhoto(analyzer services.Analyzer, storage services.ObjectStore) func(tool.Context, AnalyzePhotoArgs) (*services.AnalysisResult, error) {
+	return func(tc tool.Context, args AnalyzePhotoArgs) (*services.AnalysisResult, error) {
+		return runAnalyzePhoto(tc, analyzer, storage, tc.State(), args)
+	}
+}
+
+// loadPhoto reads the stored photo behind a gs:// URL.
+func loadPhoto(ctx context.Context, storage services.ObjectStore, imageURL string) ([]byte, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("no object store configured")
+	}
+	_, objectName, ok := strings.Cut(strings.TrimPrefix(imageURL, "gs://"), "/")
+	if !strings.HasPrefix(imageURL, "gs://") || !ok || objectName == "" {
+		return nil, fmt.Errorf("not a stored photo: %s", imageURL)
+	}
+	reader, _, _, err := storage.OpenObject(ctx, objectName)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func runAnalyzePhoto(ctx context.Context, analyzer services.Analyzer, storage services.ObjectStore, state session.State, args AnalyzePhotoArgs) (*services.AnalysisResult, error) {
 	log.Printf("DEBUG: analyzePhoto tool called with args: %+v", args)
-	ctx := context.Background()
-	geminiClient := services.NewGeminiClient()
-	result, err := geminiClient.AnalyzeImage(ctx, args.ImageURL)
+	if genre, ok := rubric.ParseGenre(args.Genre); ok {
+		ctx = rubric.WithGenre(ctx, genre)
+	}
+	if args.Rubric != "" {
+		ctx = rubric.WithID(ctx, args.Rubric)
+	}
+	if p, ok := purpose.Parse(args.Purpose); ok {
+		ctx = purpose.WithPurpose(ctx, p)
+	}
+	if args.Brief != "" {
+		ctx = services.WithBrief(ctx, args.Brief)
+	}
+	result, err := analyzer.AnalyzeImage(ctx, args.ImageURL)
 	if err != nil {
 		log.Printf("ERROR: analyzePhoto tool failed: %v", err)
-		return nil, fmt.Errorf("画像分析に失敗しました: %w", err)
+		return nil, fmt.Errorf("%s: %w", locale.FromContext(ctx).T("error.analysisFailed"), err)
+	}
+	if args.Brief != "" {
+		// Without the photo the measurable rules cannot be checked, and the
+		// model's verdicts stay marked unverified.
+		photo, err := loadPhoto(ctx, storage, args.ImageURL)
+		if err != nil {
+			log.Printf("WARN: analyzePhoto tool could not read the photo to check the brief: %v", err)
+		}
+		services.CheckBriefRules(ctx, result, photo)
 	}
 
 	resultJSON, _ := json.Marshal(result)
-	if err := tc.State().Set("analysis_result", string(resultJSON)); err != nil {
+	if err := state.Set("analysis_result", string(resultJSON)); err != nil {
 		log.Printf("ERROR: Failed to set analysis_result state: %v", err)
 		return nil, err
 	}
-	if err := tc.State().Set("original_image_url", args.ImageURL); err != nil {
+	if err := state.Set("original_image_url", args.ImageURL); err != nil {
 		log.Printf("ERROR: Failed to set original_image_url state: %v", err)
 		return nil, err
 	}
 
 	// Save metadata for session listing
 	now := time.Now()
-	if err := tc.State().Set("created_at", now.Format(time.RFC3339)); err != nil {
+	if err := state.Set("created_at", now.Format(time.RFC3339)); err != nil {
 		log.Printf("WARN: Failed to set created_at state: %v", err)
 	}
-	if err := tc.State().Set("title", formatSessionTitle(now)); err != nil {
+	if err := state.Set("title", locale.FromContext(ctx).FormatTitle(now)); err != nil {
 		log.Printf("WARN: Failed to set title state: %v", err)
 	}
-	if err := tc.State().Set("overall_score", result.OverallScore); err != nil {
+	if err := state.Set("overall_score", result.OverallScore); err != nil {
 		log.Printf("WARN: Failed to set overall_score state: %v", err)
 	}
+	if result.Purpose != "" {
+		if err := state.Set("purpose", result.Purpose); err != nil {
+			log.Printf("WARN: Failed to set purpose state: %v", err)
+		}
+	}
+	if result.Theme != nil {
+		if err := state.Set("theme_score", result.Theme.Score); err != nil {
+			log.Printf("WARN: Failed to set theme_score state: %v", err)
+		}
+	}
+	if result.Genre != "" {
+		if err := state.Set("genre", result.Genre); err != nil {
+			log.Printf("WARN: Failed to set genre state: %v", err)
+		}
+	}
+	if result.RubricID != "" {
+		if err := state.Set("rubric_id", result.RubricID); err != nil {
+			log.Printf("WARN: Failed to set rubric_id state: %v", err)
+		}
+		if err := state.Set("score_max", result.MaxScore()); err != nil {
+			log.Printf("WARN: Failed to set score_max state: %v", err)
+		}
+	}
 
 	return result, nil
 }
 
-func formatSessionTitle(t time.Time) string {
-	loc, _ := time.LoadLocation("Asia/Tokyo")
-	if loc != nil {
-		t = t.In(loc)
-	}
-	return t.Format("1月2日 15:04")
-}
-
-func NewAnalyzePhotoTool() (tool.Tool, error) {
+func NewAnalyzePhotoTool(analyzer services.Analyzer, storage services.ObjectStore) (tool.Tool, error) {
 	toolInstance, err := functiontool.New(
 		functiontool.Config{
 			Name: "analyze_photo",
-			Description: "写真を詳細に分析し、構図、露出、色彩、ライティング、ピント、現像、距離感、意図の明確さ、の8項目を評価します。" +
-				"各項目について10点満点でスコアリングし、改善点と総合コメントをJSONで返します。",
+			Description: "写真を詳細に分析し、採点ルーブリックの各項目(既定は構図、露出、色彩、ライティング、ピント、現像、距離感、意図の明確さ)を評価します。" +
+				"写真のジャンルを判定してジャンル別のルーブリックで採点し、項目ごとのスコア(scores)、改善点と総合コメントをJSONで返します。",
 		},
-		analyzePhoto,
+		analyzePhoto(analyzer, storage),
 	)
 	if err != nil {
 		return nil, err
@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"google.golang.org/adk/session"
 	"google.golang.org/adk/tool"
 	"google.golang.org/adk/tool/functiontool"
 
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/locale"
 	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
 )
 
@@ -21,12 +23,17 @@ type CompareAndAdviseResult struct {
 	Advice string `json:"advice"`
 }
 
-func compareAndAdvise(tc tool.Context, args CompareAndAdviseArgs) (*CompareAndAdviseResult, error) {
-	ctx := context.Background()
+// compareAndAdvise returns the compare_and_advise tool function backed by comparer.
+func compareAndAdvise(comparer services.Comparer) func(tool.Context, CompareAndAdviseArgs) (*CompareAndAdviseResult, error) {
+	return func(tc tool.Context, args CompareAndAdviseArgs) (*CompareAndAdviseResult, error) {
+		return runCompareAndAdvise(tc, comparer, tc.State(), args)
+	}
+}
 
+func runCompareAndAdvise(ctx context.Context, comparer services.Comparer, state session.State, args CompareAndAdviseArgs) (*CompareAndAdviseResult, error) {
 	analysis := args.AnalysisJSON
 	if analysis == "" {
-		stored, err := tc.State().Get("analysis_result")
+		stored, err := state.Get("analysis_result")
 		if err == nil {
 			if value, ok := stored.(string); ok {
 				analysis = value
@@ -34,25 +41,24 @@ func compareAndAdvise(tc tool.Context, args CompareAndAdviseArgs) (*CompareAndAd
 		}
 	}
 
-	service := services.NewGeminiClient()
-	advice, err := service.CompareAndAdvise(ctx, args.OriginalImageURL, args.TransformedImageURL, analysis)
+	advice, err := comparer.CompareAndAdvise(ctx, args.OriginalImageURL, args.TransformedImageURL, analysis)
 	if err != nil {
-		return nil, fmt.Errorf("比較アドバイスに失敗しました: %w", err)
+		return nil, fmt.Errorf("%s: %w", locale.FromContext(ctx).T("error.compareFailed"), err)
 	}
 
 	adviceJSON, _ := json.Marshal(advice)
-	_ = tc.State().Set("compare_advice", string(adviceJSON))
+	_ = state.Set("compare_advice", string(adviceJSON))
 
 	return &CompareAndAdviseResult{Advice: advice}, nil
 }
 
-func NewCompareAndAdviseTool() (tool.Tool, error) {
+func NewCompareAndAdviseTool(comparer services.Comparer) (tool.Tool, error) {
 	toolInstance, err := functiontool.New(
 		functiontool.Config{
 			Name:        "compare_and_advise",
 			Description: "元の写真と改善案を比較し、具体的な改善ポイントと撮影・現像のアドバイスを生成します。",
 		},
-		compareAndAdvise,
+		compareAndAdvise(comparer),
 	)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"google.golang.org/adk/session"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+)
+
+func newTestState(t *testing.T) session.State {
+	t.Helper()
+	response, err := session.InMemoryService().Create(context.Background(), &session.CreateRequest{
+		AppName: "photo_levelup",
+		UserID:  "user-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.Session.State()
+}
+
+func TestAnalyzePhotoStoresResultInState(t *testing.T) {
+	state := newTestState(t)
+	provider := services.NewFakeModelProvider(nil)
+
+	result, err := runAnalyzePhoto(context.Background(), provider, nil, state, AnalyzePhotoArgs{ImageURL: "gs://memory/uploads/photo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := state.Get("analysis_result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded services.AnalysisResult
+	if err := json.Unmarshal([]byte(stored.(string)), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.OverallScore != result.OverallScore {
+		t.Errorf("stored overall score = %d, want %d", decoded.OverallScore, result.OverallScore)
+	}
+	if url, _ := state.Get("original_image_url"); url != "gs://memory/uploads/photo" {
+		t.Errorf("original_image_url = %v", url)
+	}
+}
+
+func TestAnalyzePhotoChecksBrief(t *testing.T) {
+	ctx := context.Background()
+	store := services.NewMemoryObjectStore()
+	square := &bytes.Buffer{}
+	if err := jpeg.Encode(square, image.NewGray(image.Rect(0, 0, 400, 400)), nil); err != nil {
+		t.Fatal(err)
+	}
+	imageURL, _, err := store.UploadImageWithPrefix(ctx, square.Bytes(), "image/jpeg", "uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := AnalyzePhotoArgs{ImageURL: imageURL, Brief: "縦横比は3:2のみ"}
+
+	result, err := runAnalyzePhoto(ctx, services.NewFakeModelProvider(nil), store, newTestState(t), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	theme := result.Theme
+	if theme == nil || len(theme.LocalChecks) != 1 || len(theme.Violations) != 1 || !theme.Violations[0].Verified || theme.Violations[0].Kind != services.RuleAspectRatio {
+		t.Errorf("theme = %+v, want a verified aspect ratio violation", theme)
+	}
+
+	// Without storage the rules cannot be measured.
+	result, err = runAnalyzePhoto(ctx, services.NewFakeModelProvider(nil), nil, newTestState(t), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if theme := result.Theme; theme == nil || theme.Brief != args.Brief || len(theme.LocalChecks) != 0 {
+		t.Errorf("theme = %+v, want the brief without local checks", theme)
+	}
+	for _, violation := range result.Theme.Violations {
+		if violation.Verified {
+			t.Errorf("violation %+v marked verified without the photo", violation)
+		}
+	}
+}
+
+func TestCompareAndAdviseUsesStoredAnalysis(t *testing.T) {
+	state := newTestState(t)
+	provider := services.NewFakeModelProvider(nil)
+	if _, err := runAnalyzePhoto(context.Background(), provider, nil, state, AnalyzePhotoArgs{ImageURL: "gs://memory/uploads/photo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := runCompareAndAdvise(context.Background(), provider, state, CompareAndAdviseArgs{
+		OriginalImageURL:    "gs://memory/uploads/photo",
+		TransformedImageURL: "gs://memory/clean_enhanced/photo",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Advice == "" {
+		t.Error("advice is empty")
+	}
+	if _, err := state.Get("compare_advice"); err != nil {
+		t.Errorf("compare_advice not stored: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+)
+
+const ledgerCollection = "usage_ledger"
+
+// FirestoreLedger is a Ledger persisted in Cloud Firestore, one document per user.
+type FirestoreLedger struct {
+	client *firestore.Client
+}
+
+// NewFirestoreLedger creates a Firestore-backed ledger.
+func NewFirestoreLedger(ctx context.Context, projectID string) (*FirestoreLedger, error) {
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	return &FirestoreLedger{client: client}, nil
+}
+
+// Close closes the Firestore client.
+func (l *FirestoreLedger) Close() error {
+	return l.client.Close()
+}
+
+func (l *FirestoreLedger) Record(ctx context.Context, userID, activity string, report services.UsageReport) error {
+	docRef := l.client.Collection(ledgerCollection).Doc(userID)
+	return l.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		user := &UserUsage{UserID: userID}
+		doc, err := tx.Get(docRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return fmt.Errorf("failed to read usage ledger: %w", err)
+		}
+		if err == nil {
+			if err := doc.DataTo(user); err != nil {
+				return fmt.Errorf("failed to decode usage ledger: %w", err)
+			}
+		}
+		apply(user, activity, report, time.Now())
+		return tx.Set(docRef, user)
+	})
+}
+
+func (l *FirestoreLedger) Get(ctx context.Context, userID string) (*UserUsage, error) {
+	doc, err := l.client.Collection(ledgerCollection).Doc(userID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return &UserUsage{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+	user := &UserUsage{}
+	if err := doc.DataTo(user); err != nil {
+		return nil, fmt.Errorf("failed to decode usage ledger: %w", err)
+	}
+	return user, nil
+}
+
+func (l *FirestoreLedger) List(ctx context.Context) ([]*UserUsage, error) {
+	iter := l.client.Collection(ledgerCollection).OrderBy("total.CostUSD", firestore.Desc).Documents(ctx)
+	defer iter.Stop()
+
+	var users []*UserUsage
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list usage ledger: %w", err)
+		}
+		user := &UserUsage{}
+		if err := doc.DataTo(user); err != nil {
+			return nil, fmt.Errorf("failed to decode usage ledger: %w", err)
+		}
+		users = append(users, user)
+	}
+	sortByCost(users)
+	return users, nil
+}
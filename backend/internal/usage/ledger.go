@@ -0,0 +1,153 @@
+// Package usage keeps a per-user ledger of model token usage and cost.
+package usage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/matsuvr/photo_levelup_agent/backend/internal/services"
+)
+
+// Activities recorded in the ledger.
+const (
+	ActivityAnalysis = "analysis"
+	ActivityChat     = "chat"
+)
+
+// dayFormat keys daily totals; days are in UTC.
+const dayFormat = "2006-01-02"
+
+// ActivityUsage is the usage of one kind of user action, with how often it ran.
+type ActivityUsage struct {
+	Count  int                  `json:"count" firestore:"count"`
+	Totals services.UsageTotals `json:"totals" firestore:"totals"`
+}
+
+// AverageCostUSD is the mean cost of one run of the activity.
+func (a ActivityUsage) AverageCostUSD() float64 {
+	if a.Count == 0 {
+		return 0
+	}
+	return a.Totals.CostUSD / float64(a.Count)
+}
+
+// UserUsage is everything a user has spent.
+type UserUsage struct {
+	UserID     string                          `json:"userId" firestore:"userId"`
+	Total      services.UsageTotals            `json:"total" firestore:"total"`
+	ByActivity map[string]ActivityUsage        `json:"byActivity,omitempty" firestore:"byActivity"`
+	ByStep     map[string]services.UsageTotals `json:"byStep,omitempty" firestore:"byStep"`
+	ByModel    map[string]services.UsageTotals `json:"byModel,omitempty" firestore:"byModel"`
+	ByDay      map[string]services.UsageTotals `json:"byDay,omitempty" firestore:"byDay"`
+	UpdatedAt  time.Time                       `json:"updatedAt" firestore:"updatedAt"`
+}
+
+// Ledger accumulates usage per user.
+type Ledger interface {
+	// Record adds the usage of one activity run by userID.
+	Record(ctx context.Context, userID, activity string, report services.UsageReport) error
+	// Get returns the user's usage; users without usage get an empty record.
+	Get(ctx context.Context, userID string) (*UserUsage, error)
+	// List returns every user's usage, highest cost first.
+	List(ctx context.Context) ([]*UserUsage, error)
+}
+
+// apply adds one activity run to u.
+func apply(u *UserUsage, activity string, report services.UsageReport, now time.Time) {
+	u.Total.Add(report.Total)
+
+	if u.ByActivity == nil {
+		u.ByActivity = map[string]ActivityUsage{}
+	}
+	activityUsage := u.ByActivity[activity]
+	activityUsage.Count++
+	activityUsage.Totals.Add(report.Total)
+	u.ByActivity[activity] = activityUsage
+
+	u.ByStep = addTotals(u.ByStep, report.ByStep)
+	u.ByModel = addTotals(u.ByModel, report.ByModel)
+	u.ByDay = addTotals(u.ByDay, map[string]services.UsageTotals{now.UTC().Format(dayFormat): report.Total})
+	u.UpdatedAt = now
+}
+
+func addTotals(into, from map[string]services.UsageTotals) map[string]services.UsageTotals {
+	if len(from) == 0 {
+		return into
+	}
+	if into == nil {
+		into = map[string]services.UsageTotals{}
+	}
+	for key, totals := range from {
+		merged := into[key]
+		merged.Add(totals)
+		into[key] = merged
+	}
+	return into
+}
+
+func sortByCost(users []*UserUsage) {
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Total.CostUSD > users[j].Total.CostUSD
+	})
+}
+
+// MemoryLedger is an in-process Ledger.
+type MemoryLedger struct {
+	mu    sync.Mutex
+	users map[string]*UserUsage
+}
+
+// NewMemoryLedger creates an empty in-memory ledger.
+func NewMemoryLedger() *MemoryLedger {
+	return &MemoryLedger{users: map[string]*UserUsage{}}
+}
+
+func (l *MemoryLedger) Record(ctx context.Context, userID, activity string, report services.UsageReport) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	user, ok := l.users[userID]
+	if !ok {
+		user = &UserUsage{UserID: userID}
+		l.users[userID] = user
+	}
+	apply(user, activity, report, time.Now())
+	return nil
+}
+
+func (l *MemoryLedger) Get(ctx context.Context, userID string) (*UserUsage, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	user, ok := l.users[userID]
+	if !ok {
+		return &UserUsage{UserID: userID}, nil
+	}
+	copied := &UserUsage{UserID: userID}
+	*copied = *user
+	copied.ByActivity = map[string]ActivityUsage{}
+	for key, value := range user.ByActivity {
+		copied.ByActivity[key] = value
+	}
+	copied.ByStep = addTotals(nil, user.ByStep)
+	copied.ByModel = addTotals(nil, user.ByModel)
+	copied.ByDay = addTotals(nil, user.ByDay)
+	return copied, nil
+}
+
+func (l *MemoryLedger) List(ctx context.Context) ([]*UserUsage, error) {
+	l.mu.Lock()
+	userIDs := make([]string, 0, len(l.users))
+	for userID := range l.users {
+		userIDs = append(userIDs, userID)
+	}
+	l.mu.Unlock()
+
+	users := make([]*UserUsage, 0, len(userIDs))
+	for _, userID := range userIDs {
+		user, _ := l.Get(ctx, userID)
+		users = append(users, user)
+	}
+	sortByCost(users)
+	return users, nil
+}